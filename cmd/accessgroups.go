@@ -7,6 +7,8 @@
 package cmd
 
 import (
+	"fmt"
+	"net/url"
 	"strings"
 
 	"github.com/SSHcom/privx-sdk-go/api/authorizer"
@@ -17,6 +19,7 @@ type accessGroupOptions struct {
 	accessGroupID string
 	sortkey       string
 	sortdir       string
+	caType        string
 	offset        int
 	limit         int
 }
@@ -53,10 +56,13 @@ func accessGroupListCmd() *cobra.Command {
 	flags.StringVar(&options.sortkey, "sortkey", "", "sort by specific object property")
 	flags.StringVar(&options.sortdir, "sortdir", "", "sort direction, ASC or DESC")
 
+	cmd.AddCommand(accessGroupListSubCmd())
 	cmd.AddCommand(accessGroupCreateCmd())
 	cmd.AddCommand(accessGroupSearchCmd())
 	cmd.AddCommand(accessGroupShowCmd())
 	cmd.AddCommand(accessGroupUpdateCmd())
+	cmd.AddCommand(accessGroupDeleteCmd())
+	cmd.AddCommand(accessGroupCAListCmd())
 
 	return cmd
 }
@@ -73,6 +79,33 @@ func accessGroupList(options accessGroupOptions) error {
 	return stdout(groups)
 }
 
+//
+//
+func accessGroupListSubCmd() *cobra.Command {
+	options := accessGroupOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List access groups",
+		Long:  `List access groups`,
+		Example: `
+	privx-cli access-groups list [access flags] --limit <LIMIT> --offset <OFFSET>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return accessGroupList(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.IntVar(&options.offset, "offset", 0, "where to start fetching the items")
+	flags.IntVar(&options.limit, "limit", 50, "number of items to return")
+	flags.StringVar(&options.sortkey, "sortkey", "", "sort by specific object property")
+	flags.StringVar(&options.sortdir, "sortdir", "", "sort direction, ASC or DESC")
+
+	return cmd
+}
+
 //
 //
 func accessGroupCreateCmd() *cobra.Command {
@@ -90,7 +123,7 @@ func accessGroupCreateCmd() *cobra.Command {
 		},
 	}
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func accessGroupCreate(cmd *cobra.Command, args []string) error {
@@ -218,7 +251,7 @@ func accessGroupUpdateCmd() *cobra.Command {
 	flags.StringVar(&options.accessGroupID, "id", "", "access group ID")
 	cmd.MarkFlagRequired("id")
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func accessGroupUpdate(options accessGroupOptions, args []string) error {
@@ -230,6 +263,15 @@ func accessGroupUpdate(options accessGroupOptions, args []string) error {
 		return err
 	}
 
+	current, err := api.AccessGroup(options.accessGroupID)
+	if err != nil {
+		return err
+	}
+
+	if err := confirmUpdate(current, &updateAccessGroup); err != nil {
+		return err
+	}
+
 	err = api.UpdateAccessGroup(options.accessGroupID, &updateAccessGroup)
 	if err != nil {
 		return err
@@ -237,3 +279,93 @@ func accessGroupUpdate(options accessGroupOptions, args []string) error {
 
 	return nil
 }
+
+//
+//
+func accessGroupDeleteCmd() *cobra.Command {
+	options := accessGroupOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete access group",
+		Long:  `Delete access group. Access group ID's are separated by commas when using multiple values, see example`,
+		Example: `
+	privx-cli access-groups delete [access flags] --id <ACCESS-GROUP-ID>,<ACCESS-GROUP-ID>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return accessGroupDelete(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.accessGroupID, "id", "", "access group ID")
+	cmd.MarkFlagRequired("id")
+
+	return cmd
+}
+
+func accessGroupDelete(options accessGroupOptions) error {
+	for _, id := range strings.Split(options.accessGroupID, ",") {
+		_, err := curl().
+			URL("/authorizer/api/v1/accessgroups/" + url.PathEscape(id)).
+			Delete()
+		if err != nil {
+			return apiUnsupported(err, "access group delete")
+		}
+	}
+
+	return nil
+}
+
+//
+//
+func accessGroupCAListCmd() *cobra.Command {
+	options := accessGroupOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "cas",
+		Short: "List CA keys of access group",
+		Long:  `List CA keys of access group, by default the CAs of target host certificates`,
+		Example: `
+	privx-cli access-groups cas [access flags] --id <ACCESS-GROUP-ID>
+	privx-cli access-groups cas [access flags] --id <ACCESS-GROUP-ID> --type extender | webproxy
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return accessGroupCAList(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.accessGroupID, "id", "", "access group ID")
+	flags.StringVar(&options.caType, "type", "", "CA type, extender or webproxy")
+	cmd.MarkFlagRequired("id")
+
+	return cmd
+}
+
+func accessGroupCAList(options accessGroupOptions) error {
+	api := authorizer.New(curl())
+
+	var (
+		cas interface{}
+		err error
+	)
+
+	switch options.caType {
+	case "":
+		cas, err = api.CACertificates(options.accessGroupID)
+	case "extender":
+		cas, err = api.ExtenderCACertificates(options.accessGroupID)
+	case "webproxy":
+		cas, err = api.WebProxyCACertificates(options.accessGroupID)
+	default:
+		return fmt.Errorf("CA type does not exist: %s", options.caType)
+	}
+	if err != nil {
+		return err
+	}
+
+	return stdout(cas)
+}
@@ -7,7 +7,10 @@
 package cmd
 
 import (
+	"crypto/rand"
+	"encoding/base64"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/SSHcom/privx-sdk-go/api/userstore"
@@ -18,6 +21,8 @@ type apiClientOptions struct {
 	clientID       string
 	apiClientRoles string
 	name           string
+	secretOut      string
+	since          string
 }
 
 func init() {
@@ -38,10 +43,13 @@ func apiClientListCmd() *cobra.Command {
 		RunE:         apiClientList,
 	}
 
+	cmd.AddCommand(apiClientListSubCmd())
 	cmd.AddCommand(apiClientCreateCmd())
 	cmd.AddCommand(apiClientShowCmd())
 	cmd.AddCommand(apiClientDeleteCmd())
 	cmd.AddCommand(apiClientUpdateCmd())
+	cmd.AddCommand(apiClientRotateSecretCmd())
+	cmd.AddCommand(apiClientUsageCmd())
 
 	return cmd
 }
@@ -57,6 +65,23 @@ func apiClientList(cmd *cobra.Command, args []string) error {
 	return stdout(clients)
 }
 
+//
+//
+func apiClientListSubCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List API clients",
+		Long:  `List API clients`,
+		Example: `
+	privx-cli api-clients list [access flags]
+		`,
+		SilenceUsage: true,
+		RunE:         apiClientList,
+	}
+
+	return cmd
+}
+
 //
 //
 func apiClientCreateCmd() *cobra.Command {
@@ -197,7 +222,7 @@ func apiClientUpdateCmd() *cobra.Command {
 	flags.StringVar(&options.clientID, "id", "", "API client ID")
 	cmd.MarkFlagRequired("id")
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func apiClientUpdate(options apiClientOptions, args []string) error {
@@ -216,3 +241,177 @@ func apiClientUpdate(options apiClientOptions, args []string) error {
 
 	return nil
 }
+
+//
+//
+func apiClientRotateSecretCmd() *cobra.Command {
+	options := apiClientOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "rotate-secret",
+		Short: "Rotate secret of API client",
+		Long: `Rotate secret of API client. New secret is generated and printed once, the previous
+secret stops working immediately. Use --secret-out to write the secret to file.`,
+		Example: `
+	privx-cli api-clients rotate-secret [access flags] --id <API-CLIENT-ID>
+	privx-cli api-clients rotate-secret [access flags] --id <API-CLIENT-ID> --secret-out client.secret
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return apiClientRotateSecret(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.clientID, "id", "", "API client ID")
+	flags.StringVar(&options.secretOut, "secret-out", "-", "write new secret to file, - for stdout")
+	cmd.MarkFlagRequired("id")
+
+	return cmd
+}
+
+func apiClientRotateSecret(options apiClientOptions) error {
+	api := userstore.New(curl())
+
+	client, err := api.APIClient(options.clientID)
+	if err != nil {
+		return err
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(random)
+
+	var definition map[string]interface{}
+	if err := remarshal(client, &definition); err != nil {
+		return err
+	}
+	definition["secret"] = secret
+
+	var rotated userstore.APIClient
+	if err := remarshal(definition, &rotated); err != nil {
+		return err
+	}
+
+	if err := api.UpdateAPIClient(options.clientID, &rotated); err != nil {
+		return err
+	}
+
+	// PrivX may ignore the secret of update request
+	updated, err := api.APIClient(options.clientID)
+	if err != nil {
+		return err
+	}
+
+	var view struct {
+		Secret string `json:"secret"`
+	}
+	if err := remarshal(updated, &view); err != nil {
+		return err
+	}
+	if view.Secret != secret {
+		return fmt.Errorf("secret rotation of API client is not supported by this PrivX server")
+	}
+
+	return writeSecret(options.secretOut, []byte(secret+"\n"))
+}
+
+//
+//
+func apiClientUsageCmd() *cobra.Command {
+	options := apiClientOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Show usage of API clients",
+		Long: `Show last use and number of audit events of each API client within the period.
+Clients without events are listed first, they are candidates for revocation.`,
+		Example: `
+	privx-cli api-clients usage [access flags]
+	privx-cli api-clients usage [access flags] --since 90d
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return apiClientUsage(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.since, "since", "30d", "count events after timestamp (RFC3339) or duration ago (e.g. 24h, 7d)")
+
+	return cmd
+}
+
+// apiClientActivity is activity of API client in audit events
+type apiClientActivity struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	LastUsed string `json:"last_used,omitempty"`
+	Calls    int    `json:"calls"`
+	Unused   bool   `json:"unused"`
+}
+
+func apiClientUsage(options apiClientOptions) error {
+	since, err := parseTimeFlag(options.since)
+	if err != nil {
+		return err
+	}
+
+	clients, err := userstore.New(curl()).APIClients()
+	if err != nil {
+		return err
+	}
+
+	var views []struct {
+		ID            string `json:"id"`
+		Name          string `json:"name"`
+		OAuthClientID string `json:"oauth_client_id"`
+	}
+	if err := remarshal(clients, &views); err != nil {
+		return err
+	}
+
+	// events refer to API client either by ID or by OAuth client ID
+	usage := []*apiClientActivity{}
+	byID := map[string]*apiClientActivity{}
+	for _, client := range views {
+		u := &apiClientActivity{ID: client.ID, Name: client.Name}
+		usage = append(usage, u)
+		byID[client.ID] = u
+		if client.OAuthClientID != "" {
+			byID[client.OAuthClientID] = u
+		}
+	}
+
+	events, err := searchEvents(eventFilter{since: since}, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		for _, key := range []string{"user_id", "client_id"} {
+			u, ok := byID[fmt.Sprint(event[key])]
+			if !ok {
+				continue
+			}
+			u.Calls++
+			// events are in chronological order
+			u.LastUsed = fmt.Sprint(event["timestamp"])
+			break
+		}
+	}
+
+	for _, u := range usage {
+		u.Unused = u.Calls == 0
+	}
+	sort.SliceStable(usage, func(i, j int) bool {
+		if usage[i].Unused != usage[j].Unused {
+			return usage[i].Unused
+		}
+		return usage[i].LastUsed < usage[j].LastUsed
+	})
+
+	return stdout(usage)
+}
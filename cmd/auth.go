@@ -0,0 +1,333 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// REST API of auth service, the SDK has no client for IdP clients and
+// session storage yet
+const (
+	idpClientsPath  = "/auth/api/v1/idp/clients"
+	sessionsPath    = "/auth/api/v1/sessionstorage/sessions"
+	userSessionPath = "/auth/api/v1/sessionstorage/users"
+)
+
+type authOptions struct {
+	idpID     string
+	userID    string
+	sessionID string
+	offset    int
+	limit     int
+}
+
+func init() {
+	rootCmd.AddCommand(authCmd())
+}
+
+//
+//
+func authCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "auth",
+		Short:        "Manage identity providers and user sessions",
+		Long:         `Manage identity provider clients and sessions of users of the auth service`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(idpsCmd())
+	cmd.AddCommand(sessionsCmd())
+
+	return cmd
+}
+
+//
+//
+func idpsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "idps",
+		Short:        "Manage identity provider clients",
+		Long:         `Manage identity provider clients, e.g. OIDC integrations of external IdPs`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(idpListCmd())
+	cmd.AddCommand(idpShowCmd())
+	cmd.AddCommand(idpCreateCmd())
+	cmd.AddCommand(idpDeleteCmd())
+
+	return cmd
+}
+
+//
+//
+func idpListCmd() *cobra.Command {
+	options := authOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List identity provider clients",
+		Long:  `List identity provider clients`,
+		Example: `
+	privx-cli auth idps list [access flags] --offset <OFFSET> --limit <LIMIT>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return idpList(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.IntVar(&options.offset, "offset", 0, "where to start fetching the items")
+	flags.IntVar(&options.limit, "limit", 50, "number of items to return")
+
+	return cmd
+}
+
+func idpList(options authOptions) error {
+	var page struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+
+	_, err := curl().URL(idpClientsPath).
+		Query(pageQuery{Offset: options.offset, Limit: options.limit}).
+		Get(&page)
+	if err != nil {
+		return apiUnsupported(err, "identity provider clients")
+	}
+
+	return stdout(page.Items)
+}
+
+//
+//
+func idpShowCmd() *cobra.Command {
+	options := authOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Get identity provider client by ID",
+		Long:  `Get identity provider client by ID. IdP client ID's are separated by commas when using multiple values, see example`,
+		Example: `
+	privx-cli auth idps show [access flags] --id <IDP-CLIENT-ID>,<IDP-CLIENT-ID>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return idpShow(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.idpID, "id", "", "identity provider client ID")
+	cmd.MarkFlagRequired("id")
+
+	return cmd
+}
+
+func idpShow(options authOptions) error {
+	clients := []map[string]interface{}{}
+
+	for _, id := range strings.Split(options.idpID, ",") {
+		client := map[string]interface{}{}
+		_, err := curl().URL(idpClientsPath + "/" + url.PathEscape(id)).Get(&client)
+		if err != nil {
+			return apiUnsupported(err, "identity provider clients")
+		}
+		clients = append(clients, client)
+	}
+
+	return stdout(clients)
+}
+
+//
+//
+func idpCreateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create new identity provider client",
+		Long:  `Create new identity provider client`,
+		Example: `
+	privx-cli auth idps create [access flags] JSON-FILE
+		`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return idpCreate(args)
+		},
+	}
+
+	return bulkCmd(cmd)
+}
+
+func idpCreate(args []string) error {
+	var client map[string]interface{}
+
+	err := decodeJSON(args[0], &client)
+	if err != nil {
+		return err
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	_, err = curl().URL(idpClientsPath).Post(client, &created)
+	if err != nil {
+		return apiUnsupported(err, "identity provider clients")
+	}
+
+	return stdout(created.ID)
+}
+
+//
+//
+func idpDeleteCmd() *cobra.Command {
+	options := authOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete identity provider client",
+		Long:  `Delete identity provider client. IdP client ID's are separated by commas when using multiple values, see example`,
+		Example: `
+	privx-cli auth idps delete [access flags] --id <IDP-CLIENT-ID>,<IDP-CLIENT-ID>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return idpDelete(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.idpID, "id", "", "identity provider client ID")
+	cmd.MarkFlagRequired("id")
+
+	return cmd
+}
+
+func idpDelete(options authOptions) error {
+	for _, id := range strings.Split(options.idpID, ",") {
+		_, err := curl().URL(idpClientsPath + "/" + url.PathEscape(id)).Delete()
+		if err != nil {
+			return apiUnsupported(err, "identity provider clients")
+		}
+		info("%s", id)
+	}
+
+	return nil
+}
+
+//
+//
+func sessionsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "sessions",
+		Short:        "List and terminate sessions of users",
+		Long:         `List and terminate sessions of users, e.g. stuck sessions of the web UI`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(sessionListCmd())
+	cmd.AddCommand(sessionTerminateCmd())
+
+	return cmd
+}
+
+//
+//
+func sessionListCmd() *cobra.Command {
+	options := authOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List sessions of user",
+		Long:  `List sessions of user`,
+		Example: `
+	privx-cli auth sessions list [access flags] --user <USER-ID>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return sessionList(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.userID, "user", "", "user ID")
+	flags.IntVar(&options.offset, "offset", 0, "where to start fetching the items")
+	flags.IntVar(&options.limit, "limit", 50, "number of items to return")
+	cmd.MarkFlagRequired("user")
+
+	return cmd
+}
+
+func sessionList(options authOptions) error {
+	var page struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+
+	_, err := curl().URL(userSessionPath + "/" + url.PathEscape(options.userID) + "/sessions").
+		Query(pageQuery{Offset: options.offset, Limit: options.limit}).
+		Get(&page)
+	if err != nil {
+		return apiUnsupported(err, "session storage")
+	}
+
+	return stdout(page.Items)
+}
+
+//
+//
+func sessionTerminateCmd() *cobra.Command {
+	options := authOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "terminate",
+		Short: "Terminate sessions of user",
+		Long: `Terminate all sessions of user, or only the sessions given with --session-id.
+Session ID's are separated by commas when using multiple values, see example`,
+		Example: `
+	privx-cli auth sessions terminate [access flags] --user <USER-ID>
+	privx-cli auth sessions terminate [access flags] --session-id <SESSION-ID>,<SESSION-ID>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return sessionTerminate(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.userID, "user", "", "user ID, terminates all sessions of the user")
+	flags.StringVar(&options.sessionID, "session-id", "", "session ID")
+
+	return cmd
+}
+
+func sessionTerminate(options authOptions) error {
+	switch {
+	case options.sessionID != "":
+		for _, id := range strings.Split(options.sessionID, ",") {
+			_, err := curl().URL(sessionsPath + "/" + url.PathEscape(id) + "/terminate").Post(nil)
+			if err != nil {
+				return apiUnsupported(err, "session storage")
+			}
+			info("%s", id)
+		}
+	case options.userID != "":
+		_, err := curl().URL(userSessionPath + "/" + url.PathEscape(options.userID) + "/sessions/terminate").Post(nil)
+		if err != nil {
+			return apiUnsupported(err, "session storage")
+		}
+		info("%s", options.userID)
+	default:
+		return fmt.Errorf("--user or --session-id is required")
+	}
+
+	return nil
+}
@@ -130,7 +130,7 @@ func authorizedkeyCreateCmd() *cobra.Command {
 	flags.StringVar(&options.userID, "user-id", "", "user ID")
 	cmd.MarkFlagRequired("user-id")
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func authorizedkeyCreate(options authorizedkeyOptions, args []string) error {
@@ -175,7 +175,7 @@ func authorizedkeyUpdateCmd() *cobra.Command {
 	cmd.MarkFlagRequired("user-id")
 	cmd.MarkFlagRequired("id")
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func authorizedkeyUpdate(options authorizedkeyOptions, args []string) error {
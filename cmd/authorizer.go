@@ -224,7 +224,9 @@ func deploymentScriptDownload(options authorizerOptions) error {
 		return err
 	}
 
-	err = api.DownloadDeployScript(options.trustedClientID, handler.SessionID, options.fileName)
+	err = downloadFile(options.fileName, func() error {
+		return api.DownloadDeployScript(options.trustedClientID, handler.SessionID, options.fileName)
+	})
 	if err != nil {
 		return err
 	}
@@ -260,7 +262,9 @@ func principalCommandScriptDownloadCmd() *cobra.Command {
 func principalCommandScriptDownload(options authorizerOptions) error {
 	api := authorizer.New(curl())
 
-	err := api.DownloadPrincipalCommandScript(options.fileName)
+	err := downloadFile(options.fileName, func() error {
+		return api.DownloadPrincipalCommandScript(options.fileName)
+	})
 	if err != nil {
 		return err
 	}
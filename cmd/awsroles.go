@@ -163,7 +163,7 @@ func awsRoleUpdateCmd() *cobra.Command {
 	flags.StringVar(&options.awsRoleID, "id", "", "AWS role ID")
 	cmd.MarkFlagRequired("id")
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func awsRoleUpdate(options awsRoleOptions, args []string) error {
@@ -0,0 +1,426 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/connectionmanager"
+	"github.com/SSHcom/privx-sdk-go/api/hoststore"
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/SSHcom/privx-sdk-go/api/userstore"
+	"github.com/spf13/cobra"
+)
+
+// browseConnections limits connections listed by browse, the newest first
+const browseConnections = 200
+
+// browseItem is a row of browsed resource list
+type browseItem struct {
+	ID     string
+	Title  string
+	Detail interface{}
+}
+
+// browseAction is a quick action of resource bound to control key
+type browseAction struct {
+	key   byte
+	name  string
+	apply func(id string) error
+}
+
+type browseKind struct {
+	name    string
+	list    func() ([]browseItem, error)
+	actions []browseAction
+}
+
+// browser is state of the terminal UI
+type browser struct {
+	kinds   []browseKind
+	items   map[int][]browseItem
+	kind    int
+	cursor  int
+	query   string
+	status  string
+	detail  bool
+	confirm *browseAction
+	rows    int
+	cols    int
+}
+
+func init() {
+	rootCmd.AddCommand(browseCmd())
+}
+
+//
+//
+func browseCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "browse",
+		Short: "Browse roles, hosts, users and connections in terminal UI",
+		Long: `Browse roles, hosts, users and connections in terminal UI. Typing filters the list
+incrementally, Enter shows details of the selected resource and control keys run
+quick actions, e.g. Ctrl-D deletes the resource after confirmation. The terminal
+UI uses stty and is available on Unix terminals.
+
+Keys: Up/Down/PgUp/PgDn move, Tab/Shift-Tab switch resource type, Enter toggles
+details, Backspace edits the filter, Ctrl-R reloads, Esc clears the filter or quits.`,
+		Example: `
+	privx-cli browse [access flags]
+		`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return browse()
+		},
+	}
+
+	return cmd
+}
+
+func browse() error {
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		return errors.New("browse requires interactive terminal")
+	}
+
+	restore, err := rawTerminal()
+	if err != nil {
+		return fmt.Errorf("browse is not supported by this terminal: %w", err)
+	}
+	defer restore()
+
+	b := &browser{kinds: browseKinds(), items: map[int][]browseItem{}}
+	b.load()
+
+	key := make([]byte, 16)
+	for {
+		b.rows, b.cols = terminalSize()
+		b.render()
+
+		n, err := os.Stdin.Read(key)
+		if err != nil {
+			return err
+		}
+		if !b.handle(key[:n]) {
+			return nil
+		}
+	}
+}
+
+func browseKinds() []browseKind {
+	return []browseKind{
+		{
+			name: "roles",
+			list: func() ([]browseItem, error) {
+				roles, err := rolestore.New(curl()).Roles()
+				if err != nil {
+					return nil, err
+				}
+				return browseItems(roles, "name", "comment")
+			},
+			actions: []browseAction{
+				{key: 'D' - '@', name: "delete", apply: rolestore.New(curl()).DeleteRole},
+			},
+		},
+		{
+			name: "hosts",
+			list: func() ([]browseItem, error) {
+				api := hoststore.New(curl())
+				hosts, err := allPages(func(offset, limit int) (interface{}, error) {
+					return api.Hosts(offset, limit, "", "", "")
+				})
+				if err != nil {
+					return nil, err
+				}
+				return browseItems(hosts, "common_name", "addresses")
+			},
+			actions: []browseAction{
+				{key: 'D' - '@', name: "delete", apply: hoststore.New(curl()).DeleteHost},
+				{key: 'X' - '@', name: "disable", apply: func(id string) error {
+					return hoststore.New(curl()).UpdateDisabledHostStatus(id, true)
+				}},
+			},
+		},
+		{
+			name: "users",
+			list: func() ([]browseItem, error) {
+				users, err := rolestore.New(curl()).SearchUsers("", "")
+				if err != nil {
+					return nil, err
+				}
+				return browseItems(users, "username", "full_name", "email")
+			},
+			actions: []browseAction{
+				{key: 'D' - '@', name: "delete local user", apply: userstore.New(curl()).DeleteLocalUser},
+			},
+		},
+		{
+			name: "connections",
+			list: func() ([]browseItem, error) {
+				conns, err := connectionmanager.New(curl()).Connections(0, browseConnections, "created", "DESC")
+				if err != nil {
+					return nil, err
+				}
+				return browseItems(conns, "user.display_name", "target_host_address", "status", "created")
+			},
+			actions: []browseAction{
+				{key: 'T' - '@', name: "terminate", apply: connectionmanager.New(curl()).TerminateConnection},
+			},
+		},
+	}
+}
+
+// browseItems builds rows of resources, title is composed of the fields
+func browseItems(data interface{}, fields ...string) ([]browseItem, error) {
+	var objects []map[string]interface{}
+	if err := remarshal(data, &objects); err != nil {
+		return nil, err
+	}
+
+	items := []browseItem{}
+	for _, object := range objects {
+		title := []string{}
+		for _, field := range fields {
+			if value := jsonPath(object, field); value != nil && value != "" {
+				title = append(title, fmt.Sprint(value))
+			}
+		}
+
+		id, _ := object["id"].(string)
+		items = append(items, browseItem{ID: id, Title: strings.Join(title, "  "), Detail: object})
+	}
+
+	return items, nil
+}
+
+func (b *browser) load() {
+	items, err := b.kinds[b.kind].list()
+	if err != nil {
+		b.status = "error: " + err.Error()
+		items = []browseItem{}
+	}
+	b.items[b.kind] = items
+}
+
+// visible are items of the current kind matching the filter
+func (b *browser) visible() []browseItem {
+	query := strings.ToLower(b.query)
+	items := []browseItem{}
+
+	for _, item := range b.items[b.kind] {
+		if strings.Contains(strings.ToLower(item.Title), query) || strings.Contains(item.ID, query) {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// handle applies key press, false quits the browser
+func (b *browser) handle(key []byte) bool {
+	items := b.visible()
+
+	if b.confirm != nil {
+		action := b.confirm
+		b.confirm = nil
+		if key[0] != 'y' && key[0] != 'Y' {
+			b.status = action.name + " cancelled"
+			return true
+		}
+		b.apply(action, items)
+		return true
+	}
+
+	b.status = ""
+
+	switch {
+	case key[0] == 3:
+		return false
+	case len(key) == 1 && key[0] == 27:
+		if b.query == "" {
+			return false
+		}
+		b.query, b.cursor = "", 0
+	case bytes.Equal(key, []byte("\x1b[A")):
+		b.cursor--
+	case bytes.Equal(key, []byte("\x1b[B")):
+		b.cursor++
+	case bytes.Equal(key, []byte("\x1b[5~")):
+		b.cursor -= b.rows / 2
+	case bytes.Equal(key, []byte("\x1b[6~")):
+		b.cursor += b.rows / 2
+	case key[0] == '\t' || bytes.Equal(key, []byte("\x1b[Z")):
+		step := 1
+		if key[0] != '\t' {
+			step = len(b.kinds) - 1
+		}
+		b.kind = (b.kind + step) % len(b.kinds)
+		b.cursor, b.query, b.detail = 0, "", false
+		if _, ok := b.items[b.kind]; !ok {
+			b.load()
+		}
+	case key[0] == '\r' || key[0] == '\n':
+		b.detail = !b.detail
+	case key[0] == 127 || key[0] == 8:
+		if q := []rune(b.query); len(q) > 0 {
+			b.query, b.cursor = string(q[:len(q)-1]), 0
+		}
+	case key[0] == 'R'-'@':
+		b.load()
+		b.status = "reloaded"
+	case key[0] < 32:
+		for i, action := range b.kinds[b.kind].actions {
+			if action.key == key[0] && len(items) > 0 {
+				b.confirm = &b.kinds[b.kind].actions[i]
+			}
+		}
+	case key[0] >= 32 && key[0] != 27:
+		b.query += string(key)
+		b.cursor = 0
+	}
+
+	if b.cursor >= len(items) {
+		b.cursor = len(items) - 1
+	}
+	if b.cursor < 0 {
+		b.cursor = 0
+	}
+
+	return true
+}
+
+func (b *browser) apply(action *browseAction, items []browseItem) {
+	if b.cursor >= len(items) {
+		return
+	}
+	item := items[b.cursor]
+
+	if err := action.apply(item.ID); err != nil {
+		b.status = fmt.Sprintf("%s %s failed: %s", action.name, item.ID, err)
+		return
+	}
+
+	b.status = fmt.Sprintf("%s %s done", action.name, item.ID)
+	b.load()
+}
+
+func (b *browser) render() {
+	items := b.visible()
+	lines := []string{}
+
+	tabs := []string{}
+	for i, kind := range b.kinds {
+		if i == b.kind {
+			tabs = append(tabs, "\x1b[7m "+kind.name+" \x1b[0m")
+		} else {
+			tabs = append(tabs, " "+kind.name+" ")
+		}
+	}
+	lines = append(lines, strings.Join(tabs, "|"))
+	lines = append(lines, fmt.Sprintf("Filter: %s  (%d/%d)", b.query, len(items), len(b.items[b.kind])))
+
+	listRows := b.rows - 3
+	var detail []string
+	if b.detail && b.cursor < len(items) {
+		data, _ := json.MarshalIndent(items[b.cursor].Detail, "", "  ")
+		detail = strings.Split(string(data), "\n")
+		listRows = listRows / 3
+	}
+
+	top := 0
+	if b.cursor >= listRows {
+		top = b.cursor - listRows + 1
+	}
+	for i := top; i < len(items) && i < top+listRows; i++ {
+		line := clip(fmt.Sprintf("%-36s  %s", items[i].ID, items[i].Title), b.cols)
+		if i == b.cursor {
+			line = "\x1b[7m" + line + "\x1b[0m"
+		}
+		lines = append(lines, line)
+	}
+
+	if detail != nil {
+		lines = append(lines, strings.Repeat("-", b.cols))
+		for _, line := range detail {
+			if len(lines) >= b.rows-1 {
+				break
+			}
+			lines = append(lines, clip(line, b.cols))
+		}
+	}
+
+	for len(lines) < b.rows-1 {
+		lines = append(lines, "")
+	}
+
+	footer := b.status
+	switch {
+	case b.confirm != nil:
+		footer = b.confirm.name + " selected " + b.kinds[b.kind].name + "? y/N"
+	case footer == "":
+		keys := []string{"Tab type", "Enter details", "^R reload"}
+		for _, action := range b.kinds[b.kind].actions {
+			keys = append(keys, fmt.Sprintf("^%c %s", action.key+'@', action.name))
+		}
+		footer = strings.Join(append(keys, "Esc quit"), "  ")
+	}
+	lines = append(lines, "\x1b[1m"+clip(footer, b.cols)+"\x1b[0m")
+
+	// raw terminal does not translate newlines
+	os.Stdout.WriteString("\x1b[H\x1b[2J" + strings.Join(lines, "\r\n"))
+}
+
+// clip cuts line to the terminal width
+func clip(line string, width int) string {
+	if runes := []rune(line); width > 0 && len(runes) > width {
+		return string(runes[:width])
+	}
+	return line
+}
+
+// rawTerminal switches terminal to raw mode with stty, the returned
+// function restores the original mode
+func rawTerminal() (func(), error) {
+	state, err := stty("-g")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+	os.Stdout.WriteString("\x1b[?1049h\x1b[?25l")
+
+	return func() {
+		os.Stdout.WriteString("\x1b[?25h\x1b[?1049l")
+		stty(strings.TrimSpace(state))
+	}, nil
+}
+
+func terminalSize() (rows, cols int) {
+	size, err := stty("size")
+	if err == nil {
+		if _, err := fmt.Sscan(size, &rows, &cols); err == nil && rows > 0 && cols > 0 {
+			return rows, cols
+		}
+	}
+	return 24, 80
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+
+	out, err := cmd.Output()
+	return string(out), err
+}
@@ -0,0 +1,156 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/SSHcom/privx-cli/pkg/privxops"
+	"github.com/spf13/cobra"
+)
+
+// bulkCmd enables bulk mode of create and update commands. The command
+// is applied to each JSON file of --dir, to each file matching a glob
+// pattern or to each file given as argument. Update commands read the
+// resource ID from "id" of the file unless --id is given. Outcome and
+// output of each file are printed as one list.
+func bulkCmd(cmd *cobra.Command) *cobra.Command {
+	var dir string
+
+	run := cmd.RunE
+	preRun := cmd.PreRunE
+	validateArgs := cmd.Args
+	idRequired := false
+	if id := cmd.Flags().Lookup("id"); id != nil {
+		required := id.Annotations[cobra.BashCompOneRequiredFlag]
+		idRequired = len(required) > 0 && required[0] == "true"
+	}
+
+	cmd.Flags().StringVar(&dir, "dir", "", "apply each JSON file of the directory")
+
+	cmd.Args = func(cmd *cobra.Command, args []string) error {
+		if isBulk(dir, args) || validateArgs == nil {
+			return nil
+		}
+		return validateArgs(cmd, args)
+	}
+
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if id := cmd.Flags().Lookup("id"); id != nil && isBulk(dir, args) {
+			delete(id.Annotations, cobra.BashCompOneRequiredFlag)
+		}
+		if preRun != nil {
+			return preRun(cmd, args)
+		}
+		return nil
+	}
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if !isBulk(dir, args) {
+			return run(cmd, args)
+		}
+
+		files, err := privxops.BulkFiles(dir, args)
+		if err != nil {
+			return err
+		}
+
+		id := cmd.Flags().Lookup("id")
+		perFileID := id != nil && !id.Changed
+
+		results, failed := privxops.RunBulk(runContext, files, func(file string) (interface{}, error) {
+			if perFileID {
+				var resource struct {
+					ID string `json:"id"`
+				}
+				data, err := ioutil.ReadFile(file)
+				if err != nil {
+					return nil, err
+				}
+				if err := json.Unmarshal(stripBOM(data), &resource); err != nil {
+					return nil, err
+				}
+				if resource.ID == "" && idRequired {
+					return nil, fmt.Errorf("resource ID is missing, add id to the file or use --id")
+				}
+				if err := id.Value.Set(resource.ID); err != nil {
+					return nil, err
+				}
+			}
+			return bulkOutput(func() error {
+				return dryRunResult(run(cmd, []string{file}))
+			})
+		})
+
+		if err := stdout(results); err != nil {
+			return err
+		}
+
+		if err := interrupted(); err != nil {
+			return err
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d of %d files failed", failed, len(files))
+		}
+
+		return nil
+	}
+
+	return cmd
+}
+
+// bulkOutput captures output of the command applied to one file
+func bulkOutput(run func() error) (interface{}, error) {
+	output := []interface{}{}
+	captured = &output
+	defer func() { captured = nil }()
+
+	err := run()
+	switch len(output) {
+	case 0:
+		return nil, err
+	case 1:
+		return output[0], err
+	default:
+		return output, err
+	}
+}
+
+func isBulk(dir string, args []string) bool {
+	return dir != "" || len(args) > 1 ||
+		(len(args) == 1 && strings.ContainsAny(args[0], "*?["))
+}
+
+// bulkDelete deletes resources with bounded number of concurrent calls.
+// Failures do not stop the deletion, outcome of each ID is printed and
+// any failure makes the command fail.
+func bulkDelete(ids []string, parallel int, remove func(id string) error) error {
+	if parallel < 1 {
+		return fmt.Errorf("invalid --parallel: %d", parallel)
+	}
+
+	results, failed := privxops.RunParallel(runContext, ids, parallel, func(id string) error {
+		return dryRunResult(remove(id))
+	})
+	if err := stdout(results); err != nil {
+		return err
+	}
+
+	if err := interrupted(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d deletions failed", failed, len(ids))
+	}
+
+	return nil
+}
@@ -0,0 +1,178 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"net/url"
+
+	"github.com/spf13/cobra"
+)
+
+type carrierPolicyOptions struct {
+	hostID string
+}
+
+func init() {
+	rootCmd.AddCommand(carrierPolicyCmd())
+}
+
+//
+//
+func carrierPolicyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "carrier-policies",
+		Short: "Show and manage carrier web target policies",
+		Long: `Show and manage carrier web target policies (clipboard, file transfer, watermarking, ...).
+Policies are managed globally as default web service options or per target host.`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(carrierPolicyShowCmd())
+	cmd.AddCommand(carrierPolicyUpdateCmd())
+
+	return cmd
+}
+
+//
+//
+func carrierPolicyShowCmd() *cobra.Command {
+	options := carrierPolicyOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Get carrier web target policy",
+		Long:  `Get carrier web target policy, global default policy is returned unless --host-id is given`,
+		Example: `
+	privx-cli carrier-policies show [access flags]
+	privx-cli carrier-policies show [access flags] --host-id <HOST-ID> > policy.json
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return carrierPolicyShow(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.hostID, "host-id", "", "web target host ID")
+
+	return cmd
+}
+
+func carrierPolicyShow(options carrierPolicyOptions) error {
+	if options.hostID == "" {
+		defaults, err := defaultServiceOptions()
+		if err != nil {
+			return err
+		}
+
+		return stdout(defaults["web"])
+	}
+
+	host, err := rawHost(options.hostID)
+	if err != nil {
+		return err
+	}
+
+	policy := map[string]interface{}{}
+	if opts, ok := host["service_options"].(map[string]interface{}); ok && opts["web"] != nil {
+		return stdout(opts["web"])
+	}
+
+	return stdout(policy)
+}
+
+//
+//
+func carrierPolicyUpdateCmd() *cobra.Command {
+	options := carrierPolicyOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "Update carrier web target policy",
+		Long:  `Update carrier web target policy, global default policy is updated unless --host-id is given`,
+		Example: `
+	privx-cli carrier-policies update [access flags] JSON-FILE
+	privx-cli carrier-policies update [access flags] --host-id <HOST-ID> JSON-FILE
+		`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return carrierPolicyUpdate(options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.hostID, "host-id", "", "web target host ID")
+
+	return bulkCmd(cmd)
+}
+
+func carrierPolicyUpdate(options carrierPolicyOptions, args []string) error {
+	policy := map[string]interface{}{}
+	err := decodeJSON(args[0], &policy)
+	if err != nil {
+		return err
+	}
+
+	if options.hostID == "" {
+		defaults, err := defaultServiceOptions()
+		if err != nil {
+			return err
+		}
+		if err := confirmUpdate(defaults["web"], policy); err != nil {
+			return err
+		}
+		defaults["web"] = policy
+
+		_, err = curl().
+			URL("/host-store/api/v1/settings/default_service_options").
+			Put(defaults)
+		return err
+	}
+
+	host, err := rawHost(options.hostID)
+	if err != nil {
+		return err
+	}
+
+	opts, ok := host["service_options"].(map[string]interface{})
+	if !ok {
+		opts = map[string]interface{}{}
+	}
+	if err := confirmUpdate(opts["web"], policy); err != nil {
+		return err
+	}
+	opts["web"] = policy
+	host["service_options"] = opts
+
+	_, err = curl().
+		URL("/host-store/api/v1/hosts/" + url.PathEscape(options.hostID)).
+		Put(host)
+	return err
+}
+
+func defaultServiceOptions() (map[string]interface{}, error) {
+	defaults := map[string]interface{}{}
+
+	_, err := curl().
+		URL("/host-store/api/v1/settings/default_service_options").
+		Get(&defaults)
+
+	return defaults, err
+}
+
+// rawHost fetches host as generic JSON document so that update
+// preserves attributes unknown to the SDK
+func rawHost(id string) (map[string]interface{}, error) {
+	host := map[string]interface{}{}
+
+	_, err := curl().
+		URL("/host-store/api/v1/hosts/" + url.PathEscape(id)).
+		Get(&host)
+
+	return host, err
+}
@@ -0,0 +1,460 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/api/authorizer"
+	"github.com/spf13/cobra"
+)
+
+type certOptions struct {
+	accessGroupID string
+	serial        string
+	hostID        string
+	reason        string
+	crlDir        string
+	offline       bool
+}
+
+type certInspection struct {
+	Format        string            `json:"format"`
+	Type          string            `json:"type,omitempty"`
+	Serial        string            `json:"serial"`
+	KeyID         string            `json:"key_id,omitempty"`
+	Subject       string            `json:"subject,omitempty"`
+	Issuer        string            `json:"issuer,omitempty"`
+	Principals    []string          `json:"principals"`
+	ValidAfter    time.Time         `json:"valid_after"`
+	ValidBefore   *time.Time        `json:"valid_before"`
+	Forever       bool              `json:"forever,omitempty"`
+	Expired       bool              `json:"expired"`
+	Options       map[string]string `json:"critical_options,omitempty"`
+	Extensions    map[string]string `json:"extensions,omitempty"`
+	CAFingerprint string            `json:"ca_fingerprint,omitempty"`
+	CA            *certAuthority    `json:"ca,omitempty"`
+}
+
+// certAuthority is authorizer.CA with name of its access group
+type certAuthority struct {
+	ID              string `json:"id"`
+	Type            string `json:"type,omitempty"`
+	AccessGroupID   string `json:"group_id"`
+	AccessGroupName string `json:"access_group_name,omitempty"`
+	PublicKeyString string `json:"public_key,omitempty"`
+	X509Certificate string `json:"x509_certificate,omitempty"`
+}
+
+func init() {
+	rootCmd.AddCommand(certCmd())
+}
+
+//
+//
+func certCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "cert",
+		Aliases:      []string{"certs"},
+		Short:        "Inspect and revoke PrivX issued certificates",
+		Long:         `Inspect and revoke PrivX issued SSH and X.509 certificates`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(certInspectCmd())
+	cmd.AddCommand(certRevokeCmd())
+
+	return cmd
+}
+
+//
+//
+func certInspectCmd() *cobra.Command {
+	options := certOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "inspect",
+		Short: "Decode certificate and resolve its signing CA",
+		Long: `Decode a PrivX issued SSH or X.509 certificate locally and print principals,
+validity, extensions and the signing CA. The CA is cross-referenced against the
+CAs of the connected instance, unless --offline is given.`,
+		Example: `
+	privx-cli cert inspect [access flags] id_ed25519-cert.pub
+	privx-cli cert inspect [access flags] --group-id <ACCESS-GROUP-ID> cert.pem
+	privx-cli cert inspect --offline id_ed25519-cert.pub
+		`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return certInspect(options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.accessGroupID, "group-id", "", "access group ID filter")
+	flags.BoolVar(&options.offline, "offline", false, "decode certificate without contacting PrivX")
+
+	return cmd
+}
+
+func certInspect(options certOptions, args []string) error {
+	data, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	var cert *certInspection
+	var ca *x509.Certificate
+	if block, _ := pem.Decode(data); block != nil {
+		cert, ca, err = inspectX509(block.Bytes)
+	} else {
+		cert, err = inspectSSH(data)
+	}
+	if err != nil {
+		return err
+	}
+
+	if !options.offline {
+		cert.CA, err = findCertAuthority(options.accessGroupID, cert.CAFingerprint, ca)
+		if err != nil {
+			return err
+		}
+	}
+
+	// fingerprint of X.509 CA is known only from the issuing CA certificate
+	if cert.Format == "x509" && cert.CA != nil {
+		if block, _ := pem.Decode([]byte(cert.CA.X509Certificate)); block != nil {
+			cert.CAFingerprint = sha256Fingerprint(block.Bytes)
+		}
+	}
+
+	return stdout(cert)
+}
+
+//
+//
+func certRevokeCmd() *cobra.Command {
+	options := certOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "revoke",
+		Short: "Revoke certificates",
+		Long: `Revoke certificates by serial number or all certificates of target host. Serial
+numbers are separated by commas when using multiple values. Revocation lists of
+extenders and web-proxies are downloaded to --crl-dir afterwards. Requires PrivX
+with certificate revocation support.`,
+		Example: `
+	privx-cli certs revoke [access flags] --serial <SERIAL>,<SERIAL> --reason keyCompromise
+	privx-cli certs revoke [access flags] --host-id <HOST-ID> --crl-dir /etc/privx/crls
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return certRevoke(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.serial, "serial", "", "certificate serial number")
+	flags.StringVar(&options.hostID, "host-id", "", "revoke all certificates of target host")
+	flags.StringVar(&options.reason, "reason", "unspecified", "revocation reason")
+	flags.StringVar(&options.crlDir, "crl-dir", "", "download revocation lists to directory (default ~/.privx-cli/crls)")
+
+	return cmd
+}
+
+// certRevocation is outcome of revoke command
+type certRevocation struct {
+	Revoked []string      `json:"revoked"`
+	CRLs    []crlDownload `json:"crls"`
+}
+
+func certRevoke(options certOptions) error {
+	if (options.serial == "") == (options.hostID == "") {
+		return errors.New("either --serial or --host-id is required")
+	}
+
+	if options.crlDir == "" {
+		dir, err := stateDir()
+		if err != nil {
+			return err
+		}
+		options.crlDir = filepath.Join(dir, "crls")
+	}
+
+	targets := []map[string]string{}
+	if options.hostID != "" {
+		targets = append(targets, map[string]string{"host_id": options.hostID})
+	}
+	if options.serial != "" {
+		for _, serial := range strings.Split(options.serial, ",") {
+			targets = append(targets, map[string]string{"serial": serial})
+		}
+	}
+
+	result := certRevocation{Revoked: []string{}}
+	for _, target := range targets {
+		target["reason"] = options.reason
+
+		_, err := curl().
+			URL("/authorizer/api/v1/cert/revoke").
+			Post(target)
+		if err != nil {
+			return apiUnsupported(err, "certificate revocation")
+		}
+		result.Revoked = append(result.Revoked, target["serial"]+target["host_id"])
+	}
+
+	downloads, err := downloadCRLs(options.crlDir, 4)
+	if err != nil {
+		return err
+	}
+	result.CRLs = downloads
+
+	if err := stdout(result); err != nil {
+		return err
+	}
+
+	return failedCRLs(downloads)
+}
+
+func inspectX509(der []byte) (*certInspection, *x509.Certificate, error) {
+	crt, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	principals := append([]string{}, crt.DNSNames...)
+	principals = append(principals, crt.EmailAddresses...)
+
+	extensions := map[string]string{}
+	for _, ext := range crt.Extensions {
+		extensions[ext.Id.String()] = fmt.Sprintf("critical=%v", ext.Critical)
+	}
+
+	cert := &certInspection{
+		Format:      "x509",
+		Serial:      crt.SerialNumber.String(),
+		Subject:     crt.Subject.String(),
+		Issuer:      crt.Issuer.String(),
+		Principals:  principals,
+		ValidAfter:  crt.NotBefore,
+		ValidBefore: &crt.NotAfter,
+		Expired:     time.Now().After(crt.NotAfter),
+		Extensions:  extensions,
+	}
+
+	return cert, crt, nil
+}
+
+// inspectSSH decodes OpenSSH certificate (PROTOCOL.certkeys) without
+// verifying the signature, verification is done by the target host.
+func inspectSSH(data []byte) (*certInspection, error) {
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 || !strings.Contains(fields[0], "-cert-v01@openssh.com") {
+		return nil, errors.New("file is neither PEM encoded X.509 nor OpenSSH certificate")
+	}
+
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return nil, err
+	}
+
+	r := sshReader{data: blob}
+	keyType := string(r.bytes())
+	r.bytes() // nonce
+
+	// skip key type specific public key material
+	skip := map[string]int{
+		"ssh-rsa-cert-v01@openssh.com":     2,
+		"ssh-dss-cert-v01@openssh.com":     4,
+		"ssh-ed25519-cert-v01@openssh.com": 1,
+	}
+	n, ok := skip[keyType]
+	if !ok && strings.HasPrefix(keyType, "ecdsa-sha2-") {
+		n, ok = 2, true
+	}
+	if !ok {
+		return nil, fmt.Errorf("unsupported certificate key type: %s", keyType)
+	}
+	for i := 0; i < n; i++ {
+		r.bytes()
+	}
+
+	serial := r.uint64()
+	certType := "user"
+	if r.uint32() == 2 {
+		certType = "host"
+	}
+	keyID := string(r.bytes())
+
+	principals := []string{}
+	for p := (sshReader{data: r.bytes()}); p.more(); {
+		principals = append(principals, string(p.bytes()))
+	}
+
+	after := time.Unix(int64(r.uint64()), 0)
+	before := r.uint64()
+	options := sshTuples(r.bytes())
+	extensions := sshTuples(r.bytes())
+	r.bytes() // reserved
+	signer := r.bytes()
+
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	cert := &certInspection{
+		Format:        "openssh",
+		Type:          certType,
+		Serial:        fmt.Sprintf("%d", serial),
+		KeyID:         keyID,
+		Principals:    principals,
+		ValidAfter:    after,
+		Options:       options,
+		Extensions:    extensions,
+		CAFingerprint: sha256Fingerprint(signer),
+	}
+
+	// valid before of 2^64-1 is "forever", beyond the range of JSON time
+	if before >= 1<<63-1 {
+		cert.Forever = true
+	} else {
+		validBefore := time.Unix(int64(before), 0)
+		cert.ValidBefore = &validBefore
+		cert.Expired = time.Now().After(validBefore)
+	}
+
+	return cert, nil
+}
+
+// findCertAuthority looks up the CA that signed the certificate from
+// the instance's CAs, nil is returned if the CA is unknown to PrivX.
+func findCertAuthority(accessGroupID, fingerprint string, crt *x509.Certificate) (*certAuthority, error) {
+	certificates, err := authorizer.New(curl()).CACertificates(accessGroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	cas := []certAuthority{}
+	if err := remarshal(certificates, &cas); err != nil {
+		return nil, err
+	}
+
+	for _, ca := range cas {
+		if !certAuthorityMatch(ca, fingerprint, crt) {
+			continue
+		}
+
+		var group struct {
+			Name string `json:"name"`
+		}
+		if _, err := curl().URL("/authorizer/api/v1/accessgroups/" + ca.AccessGroupID).Get(&group); err == nil {
+			ca.AccessGroupName = group.Name
+		}
+
+		return &ca, nil
+	}
+
+	return nil, nil
+}
+
+func certAuthorityMatch(ca certAuthority, fingerprint string, crt *x509.Certificate) bool {
+	if crt != nil {
+		block, _ := pem.Decode([]byte(ca.X509Certificate))
+		if block == nil {
+			return false
+		}
+		parent, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return false
+		}
+		return crt.CheckSignatureFrom(parent) == nil
+	}
+
+	fields := strings.Fields(ca.PublicKeyString)
+	if len(fields) < 2 {
+		return false
+	}
+	blob, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return false
+	}
+	return fingerprint == sha256Fingerprint(blob)
+}
+
+// sha256Fingerprint is OpenSSH style fingerprint of key or certificate
+func sha256Fingerprint(data []byte) string {
+	digest := sha256.Sum256(data)
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(digest[:])
+}
+
+// sshReader decodes SSH wire format, the first error is sticky
+type sshReader struct {
+	data []byte
+	err  error
+}
+
+func (r *sshReader) more() bool {
+	return r.err == nil && len(r.data) > 0
+}
+
+func (r *sshReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.data) < n {
+		r.err = errors.New("malformed certificate")
+		return nil
+	}
+	v := r.data[:n]
+	r.data = r.data[n:]
+	return v
+}
+
+func (r *sshReader) uint32() uint32 {
+	if v := r.next(4); v != nil {
+		return binary.BigEndian.Uint32(v)
+	}
+	return 0
+}
+
+func (r *sshReader) uint64() uint64 {
+	if v := r.next(8); v != nil {
+		return binary.BigEndian.Uint64(v)
+	}
+	return 0
+}
+
+func (r *sshReader) bytes() []byte {
+	return r.next(int(r.uint32()))
+}
+
+func sshTuples(data []byte) map[string]string {
+	tuples := map[string]string{}
+
+	for r := (sshReader{data: data}); r.more(); {
+		name := string(r.bytes())
+		value := r.bytes()
+		if len(value) > 4 {
+			value = bytes.TrimSpace(value[4:])
+		}
+		tuples[name] = string(value)
+	}
+
+	return tuples
+}
@@ -0,0 +1,336 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/authorizer"
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/spf13/cobra"
+)
+
+type certAuthOptions struct {
+	accessGroupID string
+	caID          string
+	host          string
+	port          int
+	principal     string
+	publicKey     string
+	format        string
+	out           string
+}
+
+// hostPrincipal is a target account the user gets certificate for
+type hostPrincipal struct {
+	Principal string   `json:"principal"`
+	Roles     []string `json:"roles"`
+}
+
+func init() {
+	rootCmd.AddCommand(certAuthCmd())
+}
+
+//
+//
+func certAuthCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "cert-auth",
+		Short:        "Target host certificate authorities and certificates",
+		Long:         `Fetch CAs of target host certificates, principals and sign certificates`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(certAuthListCAsCmd())
+	cmd.AddCommand(certAuthShowCACmd())
+	cmd.AddCommand(certAuthPrincipalsCmd())
+	cmd.AddCommand(certAuthSignCmd())
+
+	return cmd
+}
+
+//
+//
+func certAuthListCAsCmd() *cobra.Command {
+	options := certAuthOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "list-cas",
+		Short: "List CAs of target host certificates",
+		Long: `List CAs of target host certificates. With --format openssh the public keys are
+printed in format of sshd TrustedUserCAKeys file.`,
+		Example: `
+	privx-cli cert-auth list-cas [access flags]
+	privx-cli cert-auth list-cas [access flags] --access-group-id <ACCESS-GROUP-ID> --format openssh > /etc/ssh/privx_ca.pub
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return certAuthListCAs(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.accessGroupID, "access-group-id", "", "access group ID filter")
+	flags.StringVar(&options.format, "format", "json", "output format, json or openssh")
+
+	return cmd
+}
+
+func certAuthListCAs(options certAuthOptions) error {
+	cas := []certAuthority{}
+
+	_, err := curl().
+		URL("/authorizer/api/v1/cas").
+		Query(struct {
+			AccessGroupID string `json:"access_group_id,omitempty"`
+		}{options.accessGroupID}).
+		Get(&cas)
+	if err != nil {
+		return err
+	}
+
+	return writeCertAuthorities(cas, options.format)
+}
+
+//
+//
+func certAuthShowCACmd() *cobra.Command {
+	options := certAuthOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "show-ca",
+		Short: "Get CA of target host certificates",
+		Long:  `Get CA of target host certificates. CA ID's are separated by commas when using multiple values, see example`,
+		Example: `
+	privx-cli cert-auth show-ca [access flags] --id <CA-ID>,<CA-ID>
+	privx-cli cert-auth show-ca [access flags] --id <CA-ID> --format openssh
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return certAuthShowCA(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.caID, "id", "", "CA ID")
+	flags.StringVar(&options.format, "format", "json", "output format, json or openssh")
+	cmd.MarkFlagRequired("id")
+
+	return cmd
+}
+
+func certAuthShowCA(options certAuthOptions) error {
+	cas := []certAuthority{}
+
+	for _, id := range strings.Split(options.caID, ",") {
+		var ca certAuthority
+
+		_, err := curl().
+			URL("/authorizer/api/v1/cas/" + url.PathEscape(id)).
+			Get(&ca)
+		if err != nil {
+			return err
+		}
+		cas = append(cas, ca)
+	}
+
+	return writeCertAuthorities(cas, options.format)
+}
+
+func writeCertAuthorities(cas []certAuthority, format string) error {
+	switch format {
+	case "json":
+		return stdout(cas)
+	case "openssh":
+		for _, ca := range cas {
+			if ca.PublicKeyString == "" {
+				continue
+			}
+			fmt.Println(strings.TrimSpace(ca.PublicKeyString))
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unsupported format: %s", format)
+}
+
+//
+//
+func certAuthPrincipalsCmd() *cobra.Command {
+	options := certAuthOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "principals",
+		Short: "List principals of target host",
+		Long: `List principals (target accounts) of the host the authenticated user gets
+certificates for, together with the roles that grant the principal.`,
+		Example: `
+	privx-cli cert-auth principals [access flags] --host <HOST-ID>
+	privx-cli cert-auth principals [access flags] --host web01.example.com
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return certAuthPrincipals(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.host, "host", "", "host ID or common name")
+	cmd.MarkFlagRequired("host")
+
+	return cmd
+}
+
+func certAuthPrincipals(options certAuthOptions) error {
+	uid, err := currentUserID()
+	if err != nil {
+		return err
+	}
+
+	user, err := rolestore.New(curl()).User(uid)
+	if err != nil {
+		return err
+	}
+
+	var userView struct {
+		Roles []rolestore.RoleRef `json:"roles"`
+	}
+	if err := remarshal(user, &userView); err != nil {
+		return err
+	}
+
+	roles := map[string]string{}
+	for _, role := range userView.Roles {
+		roles[role.ID] = role.Name
+	}
+
+	hosts, err := allHosts()
+	if err != nil {
+		return err
+	}
+
+	for _, host := range hosts {
+		if host.ID != options.host && !strings.EqualFold(host.CommonName, options.host) {
+			continue
+		}
+
+		principals := []hostPrincipal{}
+		for _, principal := range host.Principals {
+			granted := hostPrincipal{Principal: principal.Principal, Roles: []string{}}
+			for _, ref := range principal.Roles {
+				if name, ok := roles[ref.ID]; ok {
+					granted.Roles = append(granted.Roles, name)
+				}
+			}
+			if len(granted.Roles) > 0 {
+				principals = append(principals, granted)
+			}
+		}
+
+		return stdout(principals)
+	}
+
+	return fmt.Errorf("host not found: %s", options.host)
+}
+
+//
+//
+func certAuthSignCmd() *cobra.Command {
+	options := certAuthOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "sign",
+		Short: "Sign public key for target host",
+		Long: `Get certificate of the public key for authenticating to target host as the principal.
+With --out the OpenSSH certificate is written to file, e.g. next to the private key.`,
+		Example: `
+	privx-cli cert-auth sign [access flags] --public-key ~/.ssh/id_ed25519.pub --host web01.example.com --principal deploy
+	privx-cli cert-auth sign [access flags] --public-key ~/.ssh/id_ed25519.pub --host 10.0.0.5 --principal deploy --out ~/.ssh/id_ed25519-cert.pub
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return certAuthSign(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.publicKey, "public-key", "", "OpenSSH public key file")
+	flags.StringVar(&options.host, "host", "", "target host address")
+	flags.IntVar(&options.port, "port", 22, "target host port")
+	flags.StringVar(&options.principal, "principal", "", "target host principal")
+	flags.StringVar(&options.out, "out", "", "write OpenSSH certificate to file")
+	cmd.MarkFlagRequired("public-key")
+	cmd.MarkFlagRequired("host")
+	cmd.MarkFlagRequired("principal")
+
+	return cmd
+}
+
+func certAuthSign(options certAuthOptions) error {
+	key, err := ioutil.ReadFile(options.publicKey)
+	if err != nil {
+		return err
+	}
+
+	credentials, err := targetHostCredentials(string(key), options.host, options.port, options.principal)
+	if err != nil {
+		return err
+	}
+
+	if options.out == "" {
+		return stdout(credentials)
+	}
+
+	cert, err := openSSHCertificate(credentials, options.host, options.principal)
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(options.out, []byte(cert+"\n")); err != nil {
+		return err
+	}
+
+	return os.Chmod(options.out, 0644)
+}
+
+func targetHostCredentials(publicKey, host string, port int, principal string) (interface{}, error) {
+	var request authorizer.AuthorizationRequest
+	err := remarshal(map[string]interface{}{
+		"public_key":            strings.TrimSpace(publicKey),
+		"target_host_address":   host,
+		"target_host_port":      port,
+		"target_host_principal": principal,
+	}, &request)
+	if err != nil {
+		return nil, err
+	}
+
+	return authorizer.New(curl()).TargetHostCredentials(&request)
+}
+
+// openSSHCertificate picks OpenSSH certificate from target host credentials
+func openSSHCertificate(credentials interface{}, host, principal string) (string, error) {
+	var certs []struct {
+		Type       string `json:"type"`
+		DataString string `json:"data_string"`
+	}
+	if err := remarshal(credentials, &certs); err != nil {
+		return "", err
+	}
+
+	for _, cert := range certs {
+		if cert.DataString != "" {
+			return cert.DataString, nil
+		}
+	}
+
+	return "", fmt.Errorf("PrivX did not return certificate for %s@%s", principal, host)
+}
@@ -16,6 +16,7 @@ import (
 
 type clientOptions struct {
 	trustedClientID string
+	secretOut       string
 }
 
 func init() {
@@ -60,24 +61,31 @@ func trustedClients() error {
 //
 //
 func clientCreateCmd() *cobra.Command {
+	options := clientOptions{}
+
 	cmd := &cobra.Command{
 		Use:   "create",
 		Short: "Create new trusted-client",
-		Long:  `Create new trusted client`,
+		Long: `Create new trusted client. The registration secret is never printed, use
+--secret-out to save it to file (mode 0600) or to print it alone with "-".`,
 		Example: `
 	privx-cli clients create [access flags] JSON-FILE
+	privx-cli clients create [access flags] --secret-out client.secret JSON-FILE
 		`,
 		Args:         cobra.ExactArgs(1),
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return clientCreate(args)
+			return clientCreate(options, args)
 		},
 	}
 
-	return cmd
+	flags := cmd.Flags()
+	flags.StringVar(&options.secretOut, "secret-out", "", "write registration secret to file, - for stdout")
+
+	return bulkCmd(cmd)
 }
 
-func clientCreate(args []string) error {
+func clientCreate(options clientOptions, args []string) error {
 	var trustedClient userstore.TrustedClient
 	api := userstore.New(curl())
 
@@ -91,9 +99,42 @@ func clientCreate(args []string) error {
 		return err
 	}
 
+	if options.secretOut != "" {
+		secret, err := trustedClientSecret(id)
+		if err != nil {
+			return err
+		}
+		if err := writeSecret(options.secretOut, []byte(secret+"\n")); err != nil {
+			return err
+		}
+		if options.secretOut == "-" {
+			return nil
+		}
+	}
+
 	return stdout(id)
 }
 
+// trustedClientSecret fetches registration secret of trusted client
+func trustedClientSecret(id string) (string, error) {
+	client, err := userstore.New(curl()).TrustedClient(id)
+	if err != nil {
+		return "", err
+	}
+
+	var view struct {
+		Secret string `json:"secret"`
+	}
+	if err := remarshal(client, &view); err != nil {
+		return "", err
+	}
+	if view.Secret == "" {
+		return "", fmt.Errorf("trusted client %s has no registration secret", id)
+	}
+
+	return view.Secret, nil
+}
+
 //
 //
 func clientShowCmd() *cobra.Command {
@@ -197,7 +238,7 @@ func clientUpdateCmd() *cobra.Command {
 	flags.StringVar(&options.trustedClientID, "id", "", "trusted client ID")
 	cmd.MarkFlagRequired("id")
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func clientUpdate(options clientOptions, args []string) error {
@@ -74,7 +74,7 @@ func collectorCreateCmd() *cobra.Command {
 		},
 	}
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func collectorCreate(args []string) error {
@@ -153,7 +153,7 @@ func collectorUpdateCmd() *cobra.Command {
 	flags.StringVar(&options.collectorID, "collector-id", "", "collector ID")
 	cmd.MarkFlagRequired("collector-id")
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func collectorUpdate(options collectorOptions, args []string) error {
@@ -0,0 +1,202 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/SSHcom/privx-sdk-go/api/userstore"
+	"github.com/spf13/cobra"
+)
+
+// completionMaxAge is a period when completion candidates are reused
+const completionMaxAge = time.Minute
+
+// idCompletion lists "ID<TAB>description" candidates of resource
+type idCompletion struct {
+	flags []string
+	list  func() ([]string, error)
+}
+
+// idCompletions are dynamic completions of ID flags by command group
+var idCompletions = map[string]idCompletion{
+	"roles":           {flags: []string{"id"}, list: completeRoles},
+	"hosts":           {flags: []string{"id"}, list: completeHosts},
+	"clients":         {flags: []string{"id"}, list: completeTrustedClients},
+	"trusted-clients": {flags: []string{"client-id"}, list: completeTrustedClients},
+}
+
+func init() {
+	rootCmd.AddCommand(completionCmd())
+}
+
+//
+//
+func completionCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "completion bash|zsh|fish|powershell",
+		Short: "Generate shell completion script",
+		Long: `Generate shell completion script. ID flags of roles, hosts and trusted clients
+are completed by querying PrivX, the results are cached for a minute.`,
+		Example: `
+	source <(privx-cli completion bash)
+	privx-cli completion zsh > "${fpath[1]}/_privx-cli"
+	privx-cli completion fish > ~/.config/fish/completions/privx-cli.fish
+	privx-cli completion powershell | Out-String | Invoke-Expression
+		`,
+		ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+		Args:                  cobra.ExactValidArgs(1),
+		DisableFlagsInUseLine: true,
+		SilenceUsage:          true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return completion(cmd.Root(), args[0])
+		},
+	}
+
+	return cmd
+}
+
+func completion(root *cobra.Command, shell string) error {
+	switch shell {
+	case "bash":
+		return root.GenBashCompletionV2(os.Stdout, true)
+	case "zsh":
+		return root.GenZshCompletion(os.Stdout)
+	case "fish":
+		return root.GenFishCompletion(os.Stdout, true)
+	case "powershell":
+		return root.GenPowerShellCompletionWithDesc(os.Stdout)
+	}
+
+	return fmt.Errorf("unsupported shell: %s", shell)
+}
+
+// registerIDCompletions wires dynamic ID completion to commands of the
+// groups, it is called once all commands are added
+func registerIDCompletions(root *cobra.Command) {
+	for _, group := range root.Commands() {
+		if ids, ok := idCompletions[group.Name()]; ok {
+			registerIDCompletion(group, group.Name(), ids)
+		}
+	}
+}
+
+func registerIDCompletion(cmd *cobra.Command, kind string, ids idCompletion) {
+	for _, name := range ids.flags {
+		if cmd.Flags().Lookup(name) == nil {
+			continue
+		}
+
+		cmd.RegisterFlagCompletionFunc(name, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			candidates, err := cachedCompletion(kind, ids.list)
+			if err != nil {
+				return nil, cobra.ShellCompDirectiveError
+			}
+
+			// comma separated lists complete the last ID
+			prefix := ""
+			if i := strings.LastIndex(toComplete, ","); i >= 0 {
+				prefix = toComplete[:i+1]
+			}
+
+			matches := []string{}
+			for _, candidate := range candidates {
+				matches = append(matches, prefix+candidate)
+			}
+
+			return matches, cobra.ShellCompDirectiveNoFileComp
+		})
+	}
+
+	for _, sub := range cmd.Commands() {
+		registerIDCompletion(sub, kind, ids)
+	}
+}
+
+// cachedCompletion returns candidates of resource kind, cached per
+// principal so that repeated tab presses do not query PrivX
+func cachedCompletion(kind string, list func() ([]string, error)) ([]string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return list()
+	}
+
+	hash := sha256.Sum256([]byte(os.Getenv("PRIVX_API_BASE_URL") + "\x00" + access +
+		"\x00" + config + "\x00" + profile + "\x00" + kind))
+	file := filepath.Join(dir, "completion", hex.EncodeToString(hash[:]))
+
+	if info, err := os.Stat(file); err == nil && time.Since(info.ModTime()) < completionMaxAge {
+		var candidates []string
+		if data, err := ioutil.ReadFile(file); err == nil && json.Unmarshal(data, &candidates) == nil {
+			return candidates, nil
+		}
+	}
+
+	candidates, err := list()
+	if err != nil {
+		return nil, err
+	}
+
+	if data, err := json.Marshal(candidates); err == nil {
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err == nil {
+			writeFileAtomic(file, data)
+		}
+	}
+
+	return candidates, nil
+}
+
+func completeRoles() ([]string, error) {
+	roles, err := rolestore.New(curl()).Roles()
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []string{}
+	for _, role := range roles {
+		candidates = append(candidates, role.ID+"\t"+role.Name)
+	}
+
+	return candidates, nil
+}
+
+func completeHosts() ([]string, error) {
+	hosts, err := allHosts()
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []string{}
+	for _, host := range hosts {
+		candidates = append(candidates, host.ID+"\t"+host.CommonName)
+	}
+
+	return candidates, nil
+}
+
+func completeTrustedClients() ([]string, error) {
+	clients, err := userstore.New(curl()).TrustedClients()
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []string{}
+	for _, client := range clients {
+		candidates = append(candidates, client.ID+"\t"+client.Name)
+	}
+
+	return candidates, nil
+}
@@ -0,0 +1,305 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/api/hoststore"
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+type complianceOptions struct {
+	rulesFile string
+}
+
+// complianceRule asserts a condition over a resource type. Field is a dot
+// separated path into the JSON document of the resource.
+type complianceRule struct {
+	Name     string                 `yaml:"name"`
+	Resource string                 `yaml:"resource"`
+	Role     string                 `yaml:"role"`
+	Where    map[string]interface{} `yaml:"where"`
+	Field    string                 `yaml:"field"`
+	Require  interface{}            `yaml:"require"`
+	Forbid   interface{}            `yaml:"forbid"`
+	NotEmpty bool                   `yaml:"not_empty"`
+	MaxAge   string                 `yaml:"max_age"`
+}
+
+type complianceFinding struct {
+	Rule     string      `json:"rule"`
+	Resource string      `json:"resource"`
+	ID       string      `json:"id"`
+	Field    string      `json:"field"`
+	Value    interface{} `json:"value"`
+}
+
+func init() {
+	rootCmd.AddCommand(complianceCmd())
+}
+
+//
+//
+func complianceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "compliance",
+		Short:        "Evaluate compliance rules against PrivX",
+		Long:         `Evaluate compliance rules against PrivX`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(complianceCheckCmd())
+
+	return cmd
+}
+
+//
+//
+func complianceCheckCmd() *cobra.Command {
+	options := complianceOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Check compliance rules, exit non-zero on findings",
+		Long: `Check compliance rules against live data and exit non-zero on findings.
+Supported resources are roles, hosts, secrets and role-members (requires role).
+Each rule asserts field with require (equals), forbid (not equals), not_empty or max_age.
+
+rules:
+  - name: secrets are rotated
+    resource: secrets
+    field: updated
+    max_age: 90d
+  - name: no permanent grant to prod-admin
+    resource: role-members
+    role: prod-admin
+    field: role.grant_end
+    not_empty: true
+  - name: roles are restricted to access group
+    resource: roles
+    where: {permit_agent: true}
+    field: access_group_id
+    not_empty: true`,
+		Example: `
+	privx-cli compliance check [access flags] --rules rules.yaml
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return complianceCheck(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.rulesFile, "rules", "", "YAML file of compliance rules")
+	cmd.MarkFlagRequired("rules")
+
+	return cmd
+}
+
+func complianceCheck(options complianceOptions) error {
+	data, err := ioutil.ReadFile(options.rulesFile)
+	if err != nil {
+		return err
+	}
+
+	var spec struct {
+		Rules []complianceRule `yaml:"rules"`
+	}
+	if err := yaml.Unmarshal(stripBOM(data), &spec); err != nil {
+		return err
+	}
+
+	findings := []complianceFinding{}
+	cache := map[string][]map[string]interface{}{}
+
+	for _, rule := range spec.Rules {
+		key := rule.Resource + "/" + rule.Role
+		objects, ok := cache[key]
+		if !ok {
+			objects, err = complianceResources(rule)
+			if err != nil {
+				return fmt.Errorf("rule %s: %w", rule.Name, err)
+			}
+			cache[key] = objects
+		}
+
+		for _, object := range objects {
+			if !complianceWhere(rule.Where, object) {
+				continue
+			}
+
+			value := jsonPath(object, rule.Field)
+			violation, err := rule.violated(value)
+			if err != nil {
+				return fmt.Errorf("rule %s: %w", rule.Name, err)
+			}
+
+			if violation {
+				findings = append(findings, complianceFinding{
+					Rule:     rule.Name,
+					Resource: rule.Resource,
+					ID:       fmt.Sprint(firstOf(object, "id", "name")),
+					Field:    rule.Field,
+					Value:    value,
+				})
+			}
+		}
+	}
+
+	if err := stdout(findings); err != nil {
+		return err
+	}
+
+	if len(findings) > 0 {
+		return fmt.Errorf("%d compliance finding(s)", len(findings))
+	}
+
+	return nil
+}
+
+func (rule complianceRule) violated(value interface{}) (bool, error) {
+	empty := value == nil || fmt.Sprint(value) == ""
+
+	switch {
+	case rule.NotEmpty:
+		return empty, nil
+	case rule.Require != nil:
+		return fmt.Sprint(value) != fmt.Sprint(rule.Require), nil
+	case rule.Forbid != nil:
+		return fmt.Sprint(value) == fmt.Sprint(rule.Forbid), nil
+	case rule.MaxAge != "":
+		if empty {
+			return true, nil
+		}
+		limit, err := parseTimeFlag(rule.MaxAge)
+		if err != nil {
+			return false, err
+		}
+		ts, err := time.Parse(time.RFC3339, fmt.Sprint(value))
+		if err != nil {
+			return false, err
+		}
+		return ts.Before(limit), nil
+	}
+
+	return false, fmt.Errorf("rule defines no assertion")
+}
+
+func complianceResources(rule complianceRule) ([]map[string]interface{}, error) {
+	var objects []map[string]interface{}
+
+	switch rule.Resource {
+	case "roles":
+		roles, err := rolestore.New(curl()).Roles()
+		if err != nil {
+			return nil, err
+		}
+		err = remarshal(roles, &objects)
+		return objects, err
+
+	case "hosts":
+		// rules assert any attribute, full host documents are evaluated
+		api := hoststore.New(curl())
+		hosts, err := allPages(func(offset, limit int) (interface{}, error) {
+			return api.Hosts(offset, limit, "", "", "")
+		})
+		if err != nil {
+			return nil, err
+		}
+		err = remarshal(hosts, &objects)
+		return objects, err
+
+	case "secrets":
+		secrets, err := allSecrets()
+		if err != nil {
+			return nil, err
+		}
+		err = remarshal(secrets, &objects)
+		return objects, err
+
+	case "role-members":
+		return complianceRoleMembers(rule.Role)
+	}
+
+	return nil, fmt.Errorf("resource does not exist: %s", rule.Resource)
+}
+
+// complianceRoleMembers flattens members of the role, membership of the
+// role is available as "role" attribute of each member
+func complianceRoleMembers(role string) ([]map[string]interface{}, error) {
+	api := rolestore.New(curl())
+
+	ids, err := api.ResolveRoles([]string{role})
+	if err == nil {
+		var refs []rolestore.RoleRef
+		if remarshal(ids, &refs) == nil && len(refs) == 1 {
+			role = refs[0].ID
+		}
+	}
+
+	members, err := api.GetRoleMembers(role)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []map[string]interface{}
+	if err := remarshal(members, &objects); err != nil {
+		return nil, err
+	}
+
+	for _, object := range objects {
+		roles, _ := object["roles"].([]interface{})
+		for _, ref := range roles {
+			if ref, ok := ref.(map[string]interface{}); ok && ref["id"] == role {
+				object["role"] = ref
+			}
+		}
+	}
+
+	return objects, nil
+}
+
+func complianceWhere(where map[string]interface{}, object map[string]interface{}) bool {
+	for path, expected := range where {
+		if fmt.Sprint(jsonPath(object, path)) != fmt.Sprint(expected) {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonPath resolves dot separated path from generic JSON document
+func jsonPath(object interface{}, path string) interface{} {
+	if path == "" {
+		return object
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		node, ok := object.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		object = node[key]
+	}
+
+	return object
+}
+
+func firstOf(object map[string]interface{}, keys ...string) interface{} {
+	for _, key := range keys {
+		if value, ok := object[key]; ok && value != "" {
+			return value
+		}
+	}
+	return nil
+}
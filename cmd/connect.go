@@ -0,0 +1,245 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/hoststore"
+	"github.com/spf13/cobra"
+)
+
+type connectOptions struct {
+	proxy string
+}
+
+// connectTarget is an account on accessible host
+type connectTarget struct {
+	HostID   string
+	Host     string
+	Address  string
+	Account  string
+	Protocol string
+}
+
+func (t connectTarget) String() string {
+	return fmt.Sprintf("%s@%s (%s)", t.Account, t.Host, t.Protocol)
+}
+
+func init() {
+	rootCmd.AddCommand(connectCmd())
+}
+
+//
+//
+func connectCmd() *cobra.Command {
+	options := connectOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "connect",
+		Short: "Pick accessible host account and connect to it",
+		Long: `Pick accessible host account with fuzzy search and launch SSH or RDP session through PrivX.
+The optional query narrows the candidates, a single match is connected directly.
+Sessions are routed via PrivX proxy given by --proxy or PRIVX_PROXY_ADDRESS, which is required.`,
+		Example: `
+	privx-cli connect [access flags]
+	privx-cli connect [access flags] --proxy privx.example.com web01
+		`,
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return connect(options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.proxy, "proxy", os.Getenv("PRIVX_PROXY_ADDRESS"), "PrivX proxy address")
+
+	return cmd
+}
+
+func connect(options connectOptions, args []string) error {
+	if options.proxy == "" {
+		return errors.New("PrivX proxy address is required, use --proxy or PRIVX_PROXY_ADDRESS")
+	}
+
+	targets, err := connectTargets()
+	if err != nil {
+		return err
+	}
+
+	query := ""
+	if len(args) == 1 {
+		query = args[0]
+	}
+
+	target, err := pickTarget(fuzzyFilter(targets, query))
+	if err != nil {
+		return err
+	}
+
+	return launchSession(options, target)
+}
+
+func connectTargets() ([]connectTarget, error) {
+	api := hoststore.New(curl())
+	limit := 100
+	targets := []connectTarget{}
+
+	for offset := 0; ; offset += limit {
+		page, err := api.Hosts(offset, limit, "", "", "accessible")
+		if err != nil {
+			return nil, err
+		}
+
+		var view []struct {
+			ID         string `json:"id"`
+			CommonName string `json:"common_name"`
+			Principals []struct {
+				Principal string `json:"principal"`
+			} `json:"principals"`
+			Services []struct {
+				Service string `json:"service"`
+				Address string `json:"address"`
+			} `json:"services"`
+		}
+		if err := remarshal(page, &view); err != nil {
+			return nil, err
+		}
+
+		for _, host := range view {
+			for _, service := range host.Services {
+				if service.Service != "SSH" && service.Service != "RDP" {
+					continue
+				}
+				for _, principal := range host.Principals {
+					targets = append(targets, connectTarget{
+						HostID:   host.ID,
+						Host:     host.CommonName,
+						Address:  service.Address,
+						Account:  principal.Principal,
+						Protocol: service.Service,
+					})
+				}
+			}
+		}
+
+		if len(view) < limit {
+			return targets, nil
+		}
+	}
+}
+
+// fuzzyFilter keeps targets containing query as subsequence,
+// the most compact matches are ranked first
+func fuzzyFilter(targets []connectTarget, query string) []connectTarget {
+	type ranked struct {
+		target connectTarget
+		score  int
+	}
+
+	query = strings.ToLower(query)
+	matches := []ranked{}
+	for _, target := range targets {
+		if score, ok := fuzzyScore(strings.ToLower(target.String()), query); ok {
+			matches = append(matches, ranked{target, score})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score < matches[j].score
+	})
+
+	result := make([]connectTarget, len(matches))
+	for i, match := range matches {
+		result[i] = match.target
+	}
+
+	return result
+}
+
+func fuzzyScore(text, query string) (int, bool) {
+	start, pos := -1, 0
+	for i := 0; i < len(text) && pos < len(query); i++ {
+		if text[i] == query[pos] {
+			if start < 0 {
+				start = i
+			}
+			pos++
+			if pos == len(query) {
+				return i - start, true
+			}
+		}
+	}
+
+	return 0, query == ""
+}
+
+func pickTarget(targets []connectTarget) (connectTarget, error) {
+	switch len(targets) {
+	case 0:
+		return connectTarget{}, errors.New("no accessible host account matches the query")
+	case 1:
+		return targets[0], nil
+	}
+
+	for i, target := range targets {
+		fmt.Fprintf(os.Stderr, "%3d) %s\n", i+1, target)
+	}
+
+	in := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Fprint(os.Stderr, "Select target (number or filter): ")
+		line, err := in.ReadString('\n')
+		if err != nil {
+			return connectTarget{}, err
+		}
+
+		line = strings.TrimSpace(line)
+		if n, err := strconv.Atoi(line); err == nil && n > 0 && n <= len(targets) {
+			return targets[n-1], nil
+		}
+
+		if line == "" {
+			continue
+		}
+
+		filtered := fuzzyFilter(targets, line)
+		if len(filtered) == 0 {
+			fmt.Fprintf(os.Stderr, "No account matches %q\n", line)
+			continue
+		}
+		return pickTarget(filtered)
+	}
+}
+
+func launchSession(options connectOptions, target connectTarget) error {
+	var session *exec.Cmd
+
+	switch target.Protocol {
+	case "SSH":
+		session = exec.Command("ssh", "-t", "-l", target.Account+"@"+target.Address, options.proxy)
+	case "RDP":
+		session = exec.Command("xfreerdp", "/v:"+options.proxy, "/u:"+target.Account+"@"+target.Address)
+	default:
+		return fmt.Errorf("protocol is not supported: %s", target.Protocol)
+	}
+
+	info("Connecting %s", target)
+	session.Stdin = os.Stdin
+	session.Stdout = os.Stdout
+	session.Stderr = os.Stderr
+
+	return session.Run()
+}
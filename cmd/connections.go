@@ -9,7 +9,9 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/SSHcom/privx-sdk-go/api/connectionmanager"
 	"github.com/spf13/cobra"
@@ -27,9 +29,20 @@ type connectionOptions struct {
 	sortdir  string
 	format   string
 	filter   string
+	protocol string
+	since    string
+	until    string
 	offset   int
 	limit    int
 	force    bool
+	mine     bool
+	all      bool
+	wait     waiting
+}
+
+func (m connectionOptions) filtered() bool {
+	return m.mine || m.userID != "" || m.hostID != "" ||
+		m.protocol != "" || m.since != "" || m.until != ""
 }
 
 func init() {
@@ -47,6 +60,9 @@ func connectionListCmd() *cobra.Command {
 		Long:  `List and manage connections`,
 		Example: `
 	privx-cli connections [access flags] --offset <OFFSET> --sortkey <SORTKEY>
+	privx-cli connections [access flags] --mine --since 24h
+	privx-cli connections [access flags] --user <USER-ID> --host <HOST-ID> --protocol SSH
+	privx-cli connections [access flags] --since 2021-09-01T00:00:00Z --until 2021-09-02T00:00:00Z
 		`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -54,12 +70,9 @@ func connectionListCmd() *cobra.Command {
 		},
 	}
 
-	flags := cmd.Flags()
-	flags.IntVar(&options.offset, "offset", 0, "where to start fetching the items")
-	flags.IntVar(&options.limit, "limit", 50, "number of items to return")
-	flags.StringVar(&options.sortkey, "sortkey", "", "sort by specific object property")
-	flags.StringVar(&options.sortdir, "sortdir", "", "sort direction, ASC or DESC (default ASC)")
+	connectionListFlags(cmd, &options)
 
+	cmd.AddCommand(connectionListSubCmd())
 	cmd.AddCommand(connectionSearchCmd())
 	cmd.AddCommand(connectionShowCmd())
 	cmd.AddCommand(storedFileDownloadCmd())
@@ -72,11 +85,73 @@ func connectionListCmd() *cobra.Command {
 	return cmd
 }
 
+//
+//
+func connectionListSubCmd() *cobra.Command {
+	options := connectionOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List connections",
+		Long:  `List historical and active connections, optionally filtered by user, host, protocol and time`,
+		Example: `
+	privx-cli connections list [access flags] --protocol SSH --since 7d
+	privx-cli connections list [access flags] --user <USER-ID> --until 2021-09-02T00:00:00Z
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return connectionList(options)
+		},
+	}
+
+	connectionListFlags(cmd, &options)
+
+	return cmd
+}
+
+func connectionListFlags(cmd *cobra.Command, options *connectionOptions) {
+	flags := cmd.Flags()
+	flags.IntVar(&options.offset, "offset", 0, "where to start fetching the items")
+	flags.IntVar(&options.limit, "limit", 50, "number of items to return")
+	flags.StringVar(&options.sortkey, "sortkey", "", "sort by specific object property")
+	flags.StringVar(&options.sortdir, "sortdir", "", "sort direction, ASC or DESC (default ASC)")
+	flags.BoolVar(&options.all, "all", false, "return all connections, walking all pages")
+	flags.BoolVar(&options.mine, "mine", false, "list connections of the authenticated user")
+	flags.StringVar(&options.userID, "user", "", "filter connections by user ID")
+	flags.StringVar(&options.hostID, "host", "", "filter connections by target host ID")
+	flags.StringVar(&options.protocol, "protocol", "", "filter connections by protocol, e.g. SSH, RDP, WEB")
+	flags.StringVar(&options.since, "since", "", "connected after timestamp (RFC3339) or duration ago (e.g. 24h, 7d)")
+	flags.StringVar(&options.until, "until", "", "connected before timestamp (RFC3339) or duration ago (e.g. 24h, 7d)")
+}
+
 func connectionList(options connectionOptions) error {
 	api := connectionmanager.New(curl())
 
-	conn, err := api.Connections(options.offset, options.limit,
-		options.sortkey, options.sortdir)
+	fetch := func(offset, limit int) (interface{}, error) {
+		return api.Connections(offset, limit, options.sortkey, options.sortdir)
+	}
+
+	if options.filtered() {
+		searchObject, err := connectionFilter(options)
+		if err != nil {
+			return err
+		}
+
+		fetch = func(offset, limit int) (interface{}, error) {
+			return api.SearchConnections(offset, limit,
+				strings.ToUpper(options.sortdir), options.sortkey, searchObject)
+		}
+	}
+
+	if options.all {
+		conn, err := allPages(fetch)
+		if err != nil {
+			return err
+		}
+		return stdout(conn)
+	}
+
+	conn, err := fetch(options.offset, options.limit)
 	if err != nil {
 		return err
 	}
@@ -84,6 +159,87 @@ func connectionList(options connectionOptions) error {
 	return stdout(conn)
 }
 
+// connectionFilter maps list filters to connection search object
+func connectionFilter(options connectionOptions) (connectionmanager.ConnectionSearch, error) {
+	var searchObject connectionmanager.ConnectionSearch
+	search := map[string]interface{}{}
+
+	if options.mine {
+		uid, err := currentUserID()
+		if err != nil {
+			return searchObject, err
+		}
+		search["user_id"] = []string{uid}
+	}
+
+	if options.userID != "" {
+		search["user_id"] = strings.Split(options.userID, ",")
+	}
+
+	if options.hostID != "" {
+		search["target_host_id"] = strings.Split(options.hostID, ",")
+	}
+
+	if options.protocol != "" {
+		search["type"] = strings.Split(strings.ToUpper(options.protocol), ",")
+	}
+
+	if options.since != "" || options.until != "" {
+		connected := map[string]time.Time{}
+		if options.since != "" {
+			since, err := parseTimeFlag(options.since)
+			if err != nil {
+				return searchObject, err
+			}
+			connected["start"] = since
+		}
+		if options.until != "" {
+			until, err := parseTimeFlag(options.until)
+			if err != nil {
+				return searchObject, err
+			}
+			connected["end"] = until
+		}
+		search["connected"] = connected
+	}
+
+	err := remarshal(search, &searchObject)
+	return searchObject, err
+}
+
+// parseTimeFlag accepts either RFC3339 timestamp or duration ago.
+// Durations support Go syntax (90m, 24h) and days (7d).
+func parseTimeFlag(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	ago, err := parseDurationFlag(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time: %s", value)
+	}
+
+	return time.Now().Add(-ago), nil
+}
+
+// parseDurationFlag accepts Go duration syntax (90m, 24h) and days (7d)
+func parseDurationFlag(value string) (time.Duration, error) {
+	if strings.HasSuffix(value, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(value, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration: %s", value)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration: %s", value)
+	}
+
+	return d, nil
+}
+
 //
 //
 func connectionSearchCmd() *cobra.Command {
@@ -181,9 +337,11 @@ func storedFileDownloadCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "download-file",
 		Short: "Download trail stored file",
-		Long:  `Download trail stored file`,
+		Long: `Download trail stored file. Files of ongoing connections become available once
+the trail is processed, with --wait the download is retried until then.`,
 		Example: `
 	privx-cli connections download-file [access flags] --conn-id <CONN-ID> --file-id <FILE-ID> --channel-id <CHANNEL-ID> --name <FILE-NAME>
+	privx-cli connections download-file [access flags] --conn-id <CONN-ID> --file-id <FILE-ID> --channel-id <CHANNEL-ID> --name <FILE-NAME> --wait
 		`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -196,6 +354,7 @@ func storedFileDownloadCmd() *cobra.Command {
 	flags.StringVar(&options.channID, "channel-id", "", "channel ID")
 	flags.StringVar(&options.fileID, "file-id", "", "file ID")
 	flags.StringVar(&options.fileName, "name", "", "file name")
+	options.wait.register(cmd, "the file is available")
 	cmd.MarkFlagRequired("conn-id")
 	cmd.MarkFlagRequired("channel-id")
 	cmd.MarkFlagRequired("file-id")
@@ -207,13 +366,19 @@ func storedFileDownloadCmd() *cobra.Command {
 func storedFileDownload(options connectionOptions) error {
 	api := connectionmanager.New(curl())
 
-	sessionID, err := api.CreateSessionIDFileDownload(options.connID, options.channID, options.fileID)
+	var sessionID string
+	err := trailAvailable(options.wait, "stored file "+options.fileID, func() (err error) {
+		sessionID, err = api.CreateSessionIDFileDownload(options.connID, options.channID, options.fileID)
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
-	err = api.DownloadStoredFile(options.connID, options.channID, options.fileID,
-		sessionID, options.fileName)
+	err = downloadFile(options.fileName, func() error {
+		return api.DownloadStoredFile(options.connID, options.channID, options.fileID,
+			sessionID, options.fileName)
+	})
 	if err != nil {
 		return err
 	}
@@ -227,11 +392,15 @@ func trailLogDownloadCmd() *cobra.Command {
 	options := connectionOptions{}
 
 	cmd := &cobra.Command{
-		Use:   "download-log",
-		Short: "Download trail log",
-		Long:  `Download trail log`,
+		Use:     "download-log",
+		Aliases: []string{"trail-download"},
+		Short:   "Download trail log",
+		Long: `Download trail log. Trail of ongoing connection becomes available once it is
+processed, with --wait the download is retried until then.`,
 		Example: `
 	privx-cli connections download-log [access flags] --conn-id <CONN-ID> --channel-id <CHANNEL-ID> --sid <SESSION-ID> --name <FILE-NAME>
+	privx-cli connections trail-download [access flags] --conn-id <CONN-ID> --channel-id <CHANNEL-ID> --name <FILE-NAME> --format json
+	privx-cli connections trail-download [access flags] --conn-id <CONN-ID> --channel-id <CHANNEL-ID> --name <FILE-NAME> --wait --timeout 30m
 		`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -245,6 +414,7 @@ func trailLogDownloadCmd() *cobra.Command {
 	flags.StringVar(&options.fileName, "name", "", "file name")
 	flags.StringVar(&options.format, "format", "", "trail log format, json or hex")
 	flags.StringVar(&options.filter, "filter", "", "trail log event filter")
+	options.wait.register(cmd, "the trail is available")
 	cmd.MarkFlagRequired("conn-id")
 	cmd.MarkFlagRequired("channel-id")
 	cmd.MarkFlagRequired("name")
@@ -255,13 +425,19 @@ func trailLogDownloadCmd() *cobra.Command {
 func trailLogDownload(options connectionOptions) error {
 	api := connectionmanager.New(curl())
 
-	sessionID, err := api.CreateSessionIDTrailLog(options.connID, options.channID)
+	var sessionID string
+	err := trailAvailable(options.wait, "trail of channel "+options.channID, func() (err error) {
+		sessionID, err = api.CreateSessionIDTrailLog(options.connID, options.channID)
+		return err
+	})
 	if err != nil {
 		return err
 	}
 
-	err = api.DownloadTrailLog(options.connID, options.channID, sessionID,
-		options.format, options.filter, options.fileName)
+	err = downloadFile(options.fileName, func() error {
+		return api.DownloadTrailLog(options.connID, options.channID, sessionID,
+			options.format, options.filter, options.fileName)
+	})
 	if err != nil {
 		return err
 	}
@@ -269,6 +445,29 @@ func trailLogDownload(options connectionOptions) error {
 	return nil
 }
 
+// trailAvailable requests download session of trail. PrivX does not tell
+// processed trail from failure, with --wait pending errors are retried
+// until the time is up and the last one is reported.
+func trailAvailable(wait waiting, trail string, session func() error) error {
+	var last error
+
+	err := wait.poll("download of "+trail, func() (bool, error) {
+		last = session()
+		if last == nil && dryRun {
+			return false, errDryRun
+		}
+		if last != nil && wait.enabled && pending(last) {
+			return false, nil
+		}
+		return true, last
+	})
+	if last != nil && err != last {
+		info("%s is not available: %v", trail, last)
+	}
+
+	return err
+}
+
 //
 //
 func accessRoleListCmd() *cobra.Command {
@@ -421,7 +620,7 @@ func connectionTerminateCmd() *cobra.Command {
 
 func connectionTerminate(options connectionOptions) error {
 	if (options == connectionOptions{}) {
-		fmt.Println("Specify at least one flag for the termination type of the connection: --conn-id, --by-target or --by-user")
+		return fmt.Errorf("specify at least one flag for the termination type of the connection: --conn-id, --by-target or --by-user")
 	} else if options.hostID != "" {
 		terminateConnectionByTargerHost(options)
 	} else if options.userID != "" {
@@ -0,0 +1,349 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/restapi"
+)
+
+var (
+	refresh bool
+	headers []string
+
+	// requestID correlates all API calls of the CLI invocation
+	requestID = newRequestID()
+
+	// cacheable endpoints are read often and change rarely
+	cacheable = []string{"/role-store/", "/host-store/", "/settings/"}
+
+	// credentials are never cached, e.g. AWS tokens of role-store
+	uncacheable = []string{"token", "secret", "credential", "password"}
+
+	// cachePrincipalID is the authenticated principal of cached responses
+	cachePrincipalID   string
+	cachePrincipalOnce sync.Once
+
+	// cacheMaxAge is a period when cached response is used without revalidation
+	cacheMaxAge = 30 * time.Second
+)
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&refresh, "refresh", false, "revalidate cached responses with PrivX")
+	rootCmd.PersistentFlags().StringArrayVar(&headers, "header", []string{}, "custom HTTP header KEY=VALUE added to all API calls (repeatable)")
+}
+
+// connector decorates SDK connector with CLI wide HTTP behavior.
+// All SDK clients build requests through URL, so every API call
+// of the command passes through the request wrapper.
+type connector struct {
+	restapi.Connector
+	uncached bool
+}
+
+func (c connector) URL(path string, args ...interface{}) restapi.CURL {
+	r := &request{
+		CURL:     c.Connector.URL(path, args...),
+		path:     path,
+		args:     args,
+		headers:  http.Header{},
+		uncached: c.uncached,
+	}
+
+	r.Header("X-Request-ID", requestID)
+	for _, header := range headers {
+		if kv := strings.SplitN(header, "=", 2); len(kv) == 2 {
+			r.Header(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+		}
+	}
+
+	return r
+}
+
+// request decorates SDK request builder
+type request struct {
+	restapi.CURL
+	path     string
+	args     []interface{}
+	query    interface{}
+	headers  http.Header
+	uncached bool
+}
+
+// cachedResponse is a GET response persisted with its ETag
+type cachedResponse struct {
+	ETag    string          `json:"etag"`
+	Body    json.RawMessage `json:"body"`
+	Fetched time.Time       `json:"fetched"`
+}
+
+func (r *request) Query(query interface{}) restapi.CURL {
+	r.CURL = r.CURL.Query(query)
+	r.query = query
+	return r
+}
+
+func (r *request) Header(key, value string) restapi.CURL {
+	r.CURL = r.CURL.Header(key, value)
+	r.headers.Set(key, value)
+	return r
+}
+
+func (r *request) Get(eg interface{}) (http.Header, error) {
+	if err := interrupted(); err != nil {
+		return nil, err
+	}
+
+	if !r.cacheable() || r.uncached {
+		head, err := r.retry(http.MethodGet, r.traced(http.MethodGet, nil, []interface{}{eg}, func() (http.Header, error) {
+			return r.CURL.Get(eg)
+		}))
+		return r.done(http.MethodGet, head, err)
+	}
+
+	file := r.cacheFile()
+	cached := readCachedResponse(file)
+	if cached != nil {
+		if !refresh && time.Since(cached.Fetched) < cacheMaxAge {
+			return nil, json.Unmarshal(cached.Body, eg)
+		}
+		r.Header("If-None-Match", cached.ETag)
+	}
+
+	var body json.RawMessage
+	head, err := r.retry(http.MethodGet, r.traced(http.MethodGet, nil, []interface{}{&body}, func() (http.Header, error) {
+		return r.CURL.Get(&body)
+	}))
+	if err != nil && cached != nil && notModified(err) {
+		cached.Fetched = time.Now()
+		writeCachedResponse(file, cached)
+		return head, json.Unmarshal(cached.Body, eg)
+	}
+	if err != nil {
+		return r.done(http.MethodGet, head, err)
+	}
+
+	if etag := head.Get("ETag"); etag != "" {
+		writeCachedResponse(file, &cachedResponse{ETag: etag, Body: body, Fetched: time.Now()})
+	}
+
+	if err := json.Unmarshal(body, eg); err != nil {
+		return head, err
+	}
+
+	return r.done(http.MethodGet, head, nil)
+}
+
+func (r *request) Put(in interface{}, eg ...interface{}) (http.Header, error) {
+	if err := interrupted(); err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return nil, r.dryRun(http.MethodPut, in)
+	}
+
+	head, err := r.retry(http.MethodPut, r.traced(http.MethodPut, in, eg, func() (http.Header, error) {
+		return r.CURL.Put(in, eg...)
+	}))
+	return r.done(http.MethodPut, head, err)
+}
+
+func (r *request) Post(in interface{}, eg ...interface{}) (http.Header, error) {
+	if err := interrupted(); err != nil {
+		return nil, err
+	}
+
+	if dryRun && !r.search() {
+		return nil, r.dryRun(http.MethodPost, in)
+	}
+
+	head, err := r.retry(http.MethodPost, r.traced(http.MethodPost, in, eg, func() (http.Header, error) {
+		return r.CURL.Post(in, eg...)
+	}))
+	return r.done(http.MethodPost, head, err)
+}
+
+// search tells if POST is a read-only search, which is sent in dry run
+func (r *request) search() bool {
+	return strings.HasSuffix(strings.TrimSuffix(r.path, "/"), "/search")
+}
+
+func (r *request) Delete(eg ...interface{}) (http.Header, error) {
+	if err := interrupted(); err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		return nil, r.dryRun(http.MethodDelete, nil)
+	}
+
+	head, err := r.retry(http.MethodDelete, r.traced(http.MethodDelete, nil, eg, func() (http.Header, error) {
+		return r.CURL.Delete(eg...)
+	}))
+	return r.done(http.MethodDelete, head, err)
+}
+
+func (r *request) done(method string, head http.Header, err error) (http.Header, error) {
+	deprecation(method, r.path, head)
+
+	// any change invalidates cached responses
+	if method != http.MethodGet && r.cacheable() {
+		if dir, err := stateDir(); err == nil {
+			os.RemoveAll(filepath.Join(dir, "cache"))
+		}
+	}
+
+	return head, newAPIError(method, r.path, err)
+}
+
+func (r *request) cacheable() bool {
+	path := strings.ToLower(r.path)
+	for _, word := range uncacheable {
+		if strings.Contains(path, word) {
+			return false
+		}
+	}
+
+	for _, prefix := range cacheable {
+		if strings.HasPrefix(r.path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// cachePrincipal identifies the principal by subject of its access token,
+// whichever way it authenticated. Responses are not cached if the
+// principal is unknown.
+func cachePrincipal() string {
+	cachePrincipalOnce.Do(func() {
+		if claims, err := tokenClaims(); err == nil {
+			cachePrincipalID, _ = claims["sub"].(string)
+		}
+	})
+
+	return cachePrincipalID
+}
+
+// cacheFile is unique per instance, principal, profile, endpoint and query
+func (r *request) cacheFile() string {
+	principal := cachePrincipal()
+	if principal == "" {
+		return ""
+	}
+
+	dir, err := stateDir()
+	if err != nil {
+		return ""
+	}
+
+	args, _ := json.Marshal(r.args)
+	query, _ := json.Marshal(r.query)
+	hash := sha256.Sum256([]byte(strings.Join(baseURLs(), ",") + "\x00" + endpoint() +
+		"\x00" + principal + "\x00" + profile + "\x00" + config + "\x00" + r.path +
+		"\x00" + string(args) + "\x00" + string(query)))
+
+	return filepath.Join(dir, "cache", hex.EncodeToString(hash[:]))
+}
+
+func readCachedResponse(file string) *cachedResponse {
+	if file == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+
+	var cached cachedResponse
+	if err := json.Unmarshal(data, &cached); err != nil || cached.ETag == "" {
+		return nil
+	}
+
+	return &cached
+}
+
+func writeCachedResponse(file string, cached *cachedResponse) {
+	if file == "" {
+		return
+	}
+
+	data, err := json.Marshal(cached)
+	if err != nil {
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return
+	}
+
+	ioutil.WriteFile(file, data, 0600)
+}
+
+// notModified tells if revalidated GET got 304 Not Modified. The SDK
+// returns it as success with empty body, which fails JSON decoding.
+func notModified(err error) bool {
+	var syntax *json.SyntaxError
+	return errors.As(err, &syntax) && syntax.Offset == 0
+}
+
+// apiUnsupported explains missing endpoint of older or restricted PrivX
+func apiUnsupported(err error, feature string) error {
+	if status := statusCode(err); status == http.StatusNotFound || status == http.StatusMethodNotAllowed {
+		return fmt.Errorf("%s is not supported by this PrivX server: %w", feature, err)
+	}
+	return err
+}
+
+// newRequestID generates random UUID v4
+func newRequestID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return ""
+	}
+	id[6] = (id[6] & 0x0f) | 0x40
+	id[8] = (id[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:])
+}
+
+// stateDir returns directory for CLI state files, it is created on demand.
+// Windows uses %APPDATA%\privx-cli unless ~/.privx-cli already exists.
+func stateDir() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(home, ".privx-cli")
+	if _, err := os.Stat(dir); os.IsNotExist(err) && runtime.GOOS == "windows" {
+		if appData, err := os.UserConfigDir(); err == nil {
+			dir = filepath.Join(appData, "privx-cli")
+		}
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	return dir, nil
+}
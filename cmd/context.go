@@ -0,0 +1,121 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// runContext is cancelled when the user interrupts the command. The SDK
+// does not accept context, API calls check it through request wrapper.
+var runContext = context.Background()
+
+// interruptGrace is a time given to in-flight API call to complete
+// after interrupt before the process exits
+const interruptGrace = 3 * time.Second
+
+var (
+	partialMu    sync.Mutex
+	partialFiles = map[string]int{}
+)
+
+// handleSignals cancels runContext on SIGINT or SIGTERM. Second signal,
+// or expiry of grace period, removes partial downloads and exits.
+func handleSignals() (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	runContext = ctx
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-signals:
+		case <-done:
+			return
+		}
+
+		cancel()
+		info("interrupted, stopping...")
+
+		select {
+		case <-signals:
+		case <-time.After(interruptGrace):
+		case <-done:
+			return
+		}
+
+		removePartialFiles()
+		os.Exit(130)
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+		cancel()
+	}
+}
+
+// interrupted returns error if the command is cancelled
+func interrupted() error {
+	return runContext.Err()
+}
+
+// downloadFile tracks file written by fn as partial until fn completes,
+// the file is removed if fn fails or the command is interrupted. SDK
+// downloads to temporary file next to it, which is removed as well.
+func downloadFile(file string, fn func() error) error {
+	if err := interrupted(); err != nil {
+		return err
+	}
+
+	untrack := trackPartial(file)
+	err := fn()
+	untrack()
+
+	if err != nil {
+		removePartial(file)
+	}
+
+	return err
+}
+
+// trackPartial marks file to be removed if the process exits on interrupt
+func trackPartial(file string) (untrack func()) {
+	partialMu.Lock()
+	partialFiles[file]++
+	partialMu.Unlock()
+
+	return func() {
+		partialMu.Lock()
+		if partialFiles[file]--; partialFiles[file] == 0 {
+			delete(partialFiles, file)
+		}
+		partialMu.Unlock()
+	}
+}
+
+func removePartialFiles() {
+	partialMu.Lock()
+	defer partialMu.Unlock()
+
+	for file := range partialFiles {
+		removePartial(file)
+	}
+}
+
+// removePartial removes the file and temporary file of SDK download
+func removePartial(file string) {
+	os.Remove(file)
+	os.Remove(file + ".tmp")
+}
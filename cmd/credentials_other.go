@@ -0,0 +1,22 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+//go:build !windows
+// +build !windows
+
+package cmd
+
+func readCachedToken() (string, error) {
+	return readTokenFile()
+}
+
+func writeCachedToken(token string) error {
+	return writeTokenFile(token)
+}
+
+func removeCachedToken() error {
+	return removeTokenFile()
+}
@@ -0,0 +1,130 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// Windows Credential Manager keeps cached tokens of the user, encrypted
+// by the OS. Tokens larger than a generic credential fall back to files.
+const (
+	credTypeGeneric        = 1
+	credPersistLocal       = 2
+	credMaxBlobSize        = 5 * 512
+	errorNotFound          = syscall.Errno(1168)
+	consoleUTF8            = 65001
+	credentialTargetPrefix = "privx-cli:"
+)
+
+var (
+	advapi32    = syscall.NewLazyDLL("advapi32.dll")
+	kernel32    = syscall.NewLazyDLL("kernel32.dll")
+	credRead    = advapi32.NewProc("CredReadW")
+	credWrite   = advapi32.NewProc("CredWriteW")
+	credDelete  = advapi32.NewProc("CredDeleteW")
+	credFree    = advapi32.NewProc("CredFree")
+	setOutputCP = kernel32.NewProc("SetConsoleOutputCP")
+)
+
+// credential is CREDENTIALW of wincred.h
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+func init() {
+	// PowerShell decodes output of native commands with the console code
+	// page, UTF-8 keeps non-ASCII names of PrivX objects intact
+	if isTerminal(os.Stdout) {
+		setOutputCP.Call(consoleUTF8)
+	}
+}
+
+func credentialTarget() (*uint16, error) {
+	file, err := tokenFile()
+	if err != nil {
+		return nil, err
+	}
+
+	return syscall.UTF16PtrFromString(credentialTargetPrefix + filepath.Base(file))
+}
+
+func readCachedToken() (string, error) {
+	target, err := credentialTarget()
+	if err != nil {
+		return "", err
+	}
+
+	var cred *credential
+	ret, _, err := credRead.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if ret == 0 {
+		if err != errorNotFound {
+			return "", err
+		}
+		return readTokenFile()
+	}
+	defer credFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	blob := (*[credMaxBlobSize]byte)(unsafe.Pointer(cred.CredentialBlob))[:cred.CredentialBlobSize:cred.CredentialBlobSize]
+
+	return string(blob), nil
+}
+
+func writeCachedToken(token string) error {
+	if len(token) > credMaxBlobSize {
+		return writeTokenFile(token)
+	}
+
+	target, err := credentialTarget()
+	if err != nil {
+		return err
+	}
+
+	blob := []byte(token)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		CredentialBlob:     &blob[0],
+		Persist:            credPersistLocal,
+	}
+
+	if ret, _, err := credWrite.Call(uintptr(unsafe.Pointer(&cred)), 0); ret == 0 {
+		return err
+	}
+
+	// token of earlier login may be cached to file
+	return removeTokenFile()
+}
+
+func removeCachedToken() error {
+	target, err := credentialTarget()
+	if err != nil {
+		return err
+	}
+
+	ret, _, err := credDelete.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0)
+	if ret == 0 && err != errorNotFound {
+		return err
+	}
+
+	return removeTokenFile()
+}
@@ -0,0 +1,238 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// REST API of DB proxy, the SDK has no client for it yet
+const (
+	dbProxyConfPath   = "/db-proxy/api/v1/conf"
+	dbProxyStatusPath = "/db-proxy/api/v1/status"
+	dbProxyCAPath     = "/authorizer/api/v1/dbproxy/cas"
+)
+
+type dbProxyOptions struct {
+	caID     string
+	fileName string
+}
+
+func init() {
+	rootCmd.AddCommand(dbProxyCmd())
+}
+
+//
+//
+func dbProxyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "db-proxy",
+		Short:        "Manage DB proxy configuration",
+		Long:         `Manage configuration of database access proxy, download its CA certificate and check service status`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(dbProxyConfigCmd())
+	cmd.AddCommand(dbProxyCACmd())
+	cmd.AddCommand(dbProxyStatusCmd())
+
+	return cmd
+}
+
+//
+//
+func dbProxyConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "config",
+		Short:        "Show and update DB proxy configuration",
+		Long:         `Show and update DB proxy configuration`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(dbProxyConfigShowCmd())
+	cmd.AddCommand(dbProxyConfigUpdateCmd())
+
+	return cmd
+}
+
+//
+//
+func dbProxyConfigShowCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Get DB proxy configuration",
+		Long:  `Get DB proxy configuration`,
+		Example: `
+	privx-cli db-proxy config show [access flags]
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return dbProxyConfigShow()
+		},
+	}
+
+	return cmd
+}
+
+func dbProxyConfigShow() error {
+	conf, err := dbProxyConfig()
+	if err != nil {
+		return err
+	}
+
+	return stdout(conf)
+}
+
+func dbProxyConfig() (map[string]interface{}, error) {
+	conf := map[string]interface{}{}
+
+	_, err := curl().URL(dbProxyConfPath).Get(&conf)
+	if err != nil {
+		return nil, apiUnsupported(err, "DB proxy")
+	}
+
+	return conf, nil
+}
+
+//
+//
+func dbProxyConfigUpdateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "Update DB proxy configuration",
+		Long:  `Update DB proxy configuration`,
+		Example: `
+	privx-cli db-proxy config update [access flags] JSON-FILE
+		`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return dbProxyConfigUpdate(args)
+		},
+	}
+
+	return cmd
+}
+
+func dbProxyConfigUpdate(args []string) error {
+	var conf map[string]interface{}
+
+	err := decodeJSON(args[0], &conf)
+	if err != nil {
+		return err
+	}
+
+	current, err := dbProxyConfig()
+	if err != nil {
+		return err
+	}
+
+	if err := confirmUpdate(current, conf); err != nil {
+		return err
+	}
+
+	_, err = curl().URL(dbProxyConfPath).Put(conf)
+	return err
+}
+
+//
+//
+func dbProxyCACmd() *cobra.Command {
+	options := dbProxyOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "ca",
+		Short: "List or download CA certificates of DB proxy",
+		Long: `List CA certificates of DB proxy. With --file the PEM certificate is written to
+the file, database clients trust the proxy with it.`,
+		Example: `
+	privx-cli db-proxy ca [access flags]
+	privx-cli db-proxy ca [access flags] --id <CA-ID> --file db-proxy-ca.pem
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return dbProxyCA(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.caID, "id", "", "CA certificate ID, the first one if omitted")
+	flags.StringVar(&options.fileName, "file", "", "write PEM certificate to file, - for stdout")
+
+	return cmd
+}
+
+func dbProxyCA(options dbProxyOptions) error {
+	cas := []map[string]interface{}{}
+
+	if options.caID != "" {
+		ca := map[string]interface{}{}
+		_, err := curl().URL(dbProxyCAPath + "/" + url.PathEscape(options.caID)).Get(&ca)
+		if err != nil {
+			return apiUnsupported(err, "DB proxy")
+		}
+		cas = append(cas, ca)
+	} else if _, err := curl().URL(dbProxyCAPath).Get(&cas); err != nil {
+		return apiUnsupported(err, "DB proxy")
+	}
+
+	if options.fileName == "" {
+		return stdout(cas)
+	}
+
+	if len(cas) == 0 {
+		return fmt.Errorf("DB proxy has no CA certificates")
+	}
+
+	pem, _ := cas[0]["x509_certificate"].(string)
+	if pem == "" {
+		return fmt.Errorf("CA certificate %v has no PEM certificate", cas[0]["id"])
+	}
+	if !strings.HasSuffix(pem, "\n") {
+		pem += "\n"
+	}
+
+	if options.fileName == "-" {
+		return writeOutput([]byte(pem))
+	}
+
+	return writeFileAtomic(options.fileName, []byte(pem))
+}
+
+//
+//
+func dbProxyStatusCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "status",
+		Short: "Get DB proxy service status",
+		Long:  `Get DB proxy service status`,
+		Example: `
+	privx-cli db-proxy status [access flags]
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return dbProxyStatus()
+		},
+	}
+
+	return cmd
+}
+
+func dbProxyStatus() error {
+	status := map[string]interface{}{}
+
+	_, err := curl().URL(dbProxyStatusPath).Get(&status)
+	if err != nil {
+		return apiUnsupported(err, "DB proxy")
+	}
+
+	return stdout(status)
+}
@@ -0,0 +1,149 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	debug     bool
+	debugBody bool
+)
+
+// sensitiveHeaders are redacted from traces, matched by substring
+var sensitiveHeaders = []string{"authorization", "cookie", "token", "secret", "password", "api-key"}
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "trace API calls to stderr: method, URL, headers, status and timing")
+	rootCmd.PersistentFlags().BoolVar(&debug, "verbose", false, "alias of --debug")
+	rootCmd.PersistentFlags().BoolVar(&debugBody, "debug-body", false, "trace also request and response payloads, which may contain secrets")
+}
+
+// traced wraps API call of the request with a trace written to stderr.
+// Each attempt of retried call is traced separately.
+func (r *request) traced(method string, in interface{}, out []interface{}, call func() (http.Header, error)) func() (http.Header, error) {
+	if !debug && !debugBody {
+		return call
+	}
+
+	return func() (http.Header, error) {
+		trace := []string{fmt.Sprintf("> %s %s", method, r.traceURL())}
+		trace = append(trace, traceHeaders(">", r.headers)...)
+		if debugBody && in != nil {
+			trace = append(trace, tracePayload(">", in))
+		}
+
+		started := time.Now()
+		head, err := call()
+		elapsed := time.Since(started).Round(time.Millisecond)
+
+		status := "OK"
+		if err != nil {
+			status = "failed: " + err.Error()
+			var api *apiError
+			if errors.As(newAPIError(method, r.path, err), &api) && api.Status != 0 {
+				status = fmt.Sprintf("%d %s", api.Status, http.StatusText(api.Status))
+			}
+		}
+		trace = append(trace, fmt.Sprintf("< %s (%s)", status, elapsed))
+		trace = append(trace, traceHeaders("<", head)...)
+		if debugBody && err == nil {
+			for _, body := range out {
+				trace = append(trace, tracePayload("<", body))
+			}
+		}
+
+		fmt.Fprintln(os.Stderr, strings.Join(trace, "\n"))
+		return head, err
+	}
+}
+
+// traceURL renders the request URL as the SDK builds it
+func (r *request) traceURL() string {
+	path := r.path
+	if len(r.args) > 0 {
+		path = fmt.Sprintf(path, r.args...)
+	}
+
+	base := endpoint()
+	if urls := baseURLs(); base == "" && len(urls) > 0 {
+		base = urls[0]
+	}
+
+	if query := traceQuery(r.query); query != "" {
+		path += "?" + query
+	}
+
+	return base + path
+}
+
+// traceQuery encodes query struct by url tags of its fields
+func traceQuery(query interface{}) string {
+	values := url.Values{}
+
+	v := reflect.Indirect(reflect.ValueOf(query))
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			tag := strings.Split(v.Type().Field(i).Tag.Get("url"), ",")
+			if tag[0] == "" || tag[0] == "-" {
+				continue
+			}
+			field := v.Field(i)
+			if len(tag) > 1 && tag[1] == "omitempty" && field.IsZero() {
+				continue
+			}
+			values.Set(tag[0], fmt.Sprint(field.Interface()))
+		}
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			values.Set(fmt.Sprint(key.Interface()), fmt.Sprint(v.MapIndex(key).Interface()))
+		}
+	}
+
+	return values.Encode()
+}
+
+func traceHeaders(prefix string, head http.Header) []string {
+	keys := []string{}
+	for key := range head {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lines := []string{}
+	for _, key := range keys {
+		value := strings.Join(head[key], ", ")
+		for _, sensitive := range sensitiveHeaders {
+			if strings.Contains(strings.ToLower(key), sensitive) {
+				value = "REDACTED"
+			}
+		}
+		lines = append(lines, fmt.Sprintf("%s %s: %s", prefix, key, value))
+	}
+
+	return lines
+}
+
+func tracePayload(prefix string, body interface{}) string {
+	data, err := json.MarshalIndent(body, prefix+" ", "  ")
+	if err != nil {
+		return fmt.Sprintf("%s %v", prefix, body)
+	}
+
+	return prefix + " " + string(data)
+}
@@ -0,0 +1,158 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var noDeprecationWarnings bool
+
+// deprecatedEndpoint is an API endpoint flagged deprecated by PrivX
+type deprecatedEndpoint struct {
+	Method      string    `json:"method"`
+	Path        string    `json:"path"`
+	Command     string    `json:"command"`
+	Deprecation string    `json:"deprecation,omitempty"`
+	Sunset      string    `json:"sunset,omitempty"`
+	Link        string    `json:"link,omitempty"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&noDeprecationWarnings, "no-deprecation-warnings", false, "suppress warnings about deprecated API endpoints")
+	rootCmd.AddCommand(deprecationListCmd())
+}
+
+//
+//
+func deprecationListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "deprecations",
+		Short: "List deprecated API endpoints used by the client",
+		Long: `List deprecated API endpoints used by the client. The list is collected locally
+from Deprecation and Sunset headers of PrivX responses.`,
+		Example: `
+	privx-cli deprecations
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return deprecationList()
+		},
+	}
+
+	return cmd
+}
+
+func deprecationList() error {
+	seen, err := readDeprecations()
+	if err != nil {
+		return err
+	}
+
+	endpoints := []deprecatedEndpoint{}
+	for _, endpoint := range seen {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].Path < endpoints[j].Path
+	})
+
+	return stdout(endpoints)
+}
+
+// deprecation warns once per endpoint about deprecated API and records
+// the endpoint to the local list
+func deprecation(method, path string, head http.Header) {
+	if head == nil || (head.Get("Deprecation") == "" && head.Get("Sunset") == "") {
+		return
+	}
+
+	endpoint := deprecatedEndpoint{
+		Method:      method,
+		Path:        path,
+		Command:     rootCmd.Name(),
+		Deprecation: head.Get("Deprecation"),
+		Sunset:      head.Get("Sunset"),
+		Link:        head.Get("Link"),
+		LastSeen:    time.Now().UTC(),
+	}
+	if cmd, _, err := rootCmd.Find(os.Args[1:]); err == nil {
+		endpoint.Command = cmd.CommandPath()
+	}
+
+	seen, err := readDeprecations()
+	if err != nil {
+		seen = map[string]deprecatedEndpoint{}
+	}
+	key := method + " " + path
+	_, known := seen[key]
+	seen[key] = endpoint
+	writeDeprecations(seen)
+
+	if known || noDeprecationWarnings {
+		return
+	}
+
+	warning := fmt.Sprintf("Warning: %s relies on deprecated API %s", endpoint.Command, key)
+	if endpoint.Sunset != "" {
+		warning += fmt.Sprintf(", removal scheduled at %s", endpoint.Sunset)
+	}
+	info("%s", warning)
+}
+
+func deprecationsFile() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "deprecations.json"), nil
+}
+
+func readDeprecations() (map[string]deprecatedEndpoint, error) {
+	seen := map[string]deprecatedEndpoint{}
+
+	file, err := deprecationsFile()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return seen, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, &seen)
+	return seen, err
+}
+
+func writeDeprecations(seen map[string]deprecatedEndpoint) error {
+	file, err := deprecationsFile()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(seen)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(file, data, 0600)
+}
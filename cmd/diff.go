@@ -0,0 +1,346 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/SSHcom/privx-cli/pkg/privxops"
+	"github.com/SSHcom/privx-sdk-go/api/settings"
+	"github.com/spf13/cobra"
+)
+
+var (
+	noDiff    bool
+	assumeYes bool
+)
+
+type diffOptions struct {
+	format string
+}
+
+// resourceDiff is difference of live resource and local definition
+type resourceDiff struct {
+	Type   string        `json:"type"`
+	Name   string        `json:"name"`
+	File   string        `json:"file,omitempty"`
+	Status string        `json:"status"`
+	Patch  []jsonPatchOp `json:"patch,omitempty"`
+	hunks  []privxops.DiffHunk
+}
+
+// jsonPatchOp is RFC 6902 operation
+type jsonPatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&noDiff, "no-diff", false, "do not print diff of changes made by update commands")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "apply changes of update commands without confirmation")
+	rootCmd.AddCommand(diffCmd())
+}
+
+//
+//
+func diffCmd() *cobra.Command {
+	options := diffOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "diff TYPE DIR",
+		Short: "Compare live resources against local definitions",
+		Long: `Compare live resources against local definitions in directory written by export,
+so that the changes are reviewed before import. Resources are matched by name, fields
+maintained by PrivX are ignored. The types are roles, hosts, sources, access-groups
+and settings. The diff is printed in unified format or as JSON patch with --format patch.`,
+		Example: `
+	privx-cli diff [access flags] roles ./privx/roles
+	privx-cli diff [access flags] settings ./privx/settings
+	privx-cli diff [access flags] hosts ./privx/hosts --format patch
+		`,
+		Args:         cobra.ExactArgs(2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return diffResources(options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.format, "format", "unified", "diff format: unified or patch")
+
+	return cmd
+}
+
+func diffResources(options diffOptions, args []string) error {
+	if options.format != "unified" && options.format != "patch" {
+		return fmt.Errorf("unsupported format: %s", options.format)
+	}
+
+	kind, dir := args[0], args[1]
+	files, err := exportFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	var diffs []resourceDiff
+	if kind == exportSettings {
+		diffs, err = diffSettings(files)
+	} else {
+		diffs, err = diffKind(kind, files)
+	}
+	if err != nil {
+		return err
+	}
+
+	if options.format == "patch" {
+		return stdout(diffs)
+	}
+
+	var out bytes.Buffer
+	color := outFile == "" && isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""
+	for _, diff := range diffs {
+		switch diff.Status {
+		case "unchanged":
+			continue
+		case "only in PrivX":
+			fmt.Fprintf(&out, "only in PrivX: %s/%s\n", diff.Type, diff.Name)
+			continue
+		}
+		fmt.Fprintf(&out, "diff %s/%s %s\n", diff.Type, diff.Name, diff.File)
+		privxops.WriteDiff(&out, diff.hunks, color)
+	}
+
+	return writeOutput(out.Bytes())
+}
+
+func diffKind(name string, files []string) ([]resourceDiff, error) {
+	var kind *exportKind
+	for i := range exportKinds {
+		if exportKinds[i].name == name {
+			kind = &exportKinds[i]
+		}
+	}
+	if kind == nil {
+		return nil, fmt.Errorf("unsupported resource type: %s", name)
+	}
+
+	items, err := exportList(*kind)
+	if err != nil {
+		return nil, err
+	}
+
+	live := map[string]map[string]interface{}{}
+	for _, item := range items {
+		live[fmt.Sprint(item[kind.nameField])] = item
+	}
+
+	diffs := []resourceDiff{}
+	for _, file := range files {
+		local, err := readExport(file)
+		if err != nil {
+			return nil, err
+		}
+
+		name := fmt.Sprint(local[kind.nameField])
+		current, ok := live[name]
+		delete(live, name)
+
+		var before interface{}
+		if ok {
+			before = current
+		}
+		diff, err := diffResource(kind.name, name, file, before, local)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, diff)
+	}
+
+	extra := []string{}
+	for name := range live {
+		extra = append(extra, name)
+	}
+	sort.Strings(extra)
+	for _, name := range extra {
+		diffs = append(diffs, resourceDiff{Type: kind.name, Name: name, Status: "only in PrivX"})
+	}
+
+	return diffs, nil
+}
+
+func diffSettings(files []string) ([]resourceDiff, error) {
+	api := settings.New(curl())
+	diffs := []resourceDiff{}
+
+	for _, file := range files {
+		local, err := readExport(file)
+		if err != nil {
+			return nil, err
+		}
+
+		scope := strings.ToUpper(strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)))
+		current, err := api.ScopeSettings(scope, "")
+		if err != nil {
+			return nil, err
+		}
+
+		diff, err := diffResource(exportSettings, scope, file, current, local)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, diff)
+	}
+
+	return diffs, nil
+}
+
+// diffResource compares live resource with local definition, nil live
+// resource is created by import
+func diffResource(kind, name, file string, live interface{}, local map[string]interface{}) (resourceDiff, error) {
+	diff := resourceDiff{Type: kind, Name: name, File: file}
+
+	var before, after map[string]interface{}
+	if live != nil {
+		if err := remarshal(live, &before); err != nil {
+			return diff, err
+		}
+	}
+	if err := remarshal(local, &after); err != nil {
+		return diff, err
+	}
+	for _, field := range importReadOnly {
+		delete(before, field)
+		delete(after, field)
+	}
+
+	var a []string
+	if before != nil {
+		var err error
+		if a, err = privxops.DiffLines(before); err != nil {
+			return diff, err
+		}
+	}
+	b, err := privxops.DiffLines(after)
+	if err != nil {
+		return diff, err
+	}
+
+	diff.hunks = privxops.UnifiedDiff(a, b, 3)
+	diff.Patch = jsonPatch("", before, after)
+
+	switch {
+	case before == nil:
+		diff.Status = "create"
+	case len(diff.Patch) == 0:
+		diff.Status = "unchanged"
+	default:
+		diff.Status = "update"
+	}
+
+	return diff, nil
+}
+
+// jsonPatch computes operations changing a to b, arrays are replaced as
+// a whole
+func jsonPatch(path string, a, b interface{}) []jsonPatchOp {
+	if reflect.DeepEqual(a, b) {
+		return nil
+	}
+
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if !aok || !bok {
+		if path == "" && a == nil {
+			return []jsonPatchOp{{Op: "add", Path: "", Value: b}}
+		}
+		return []jsonPatchOp{{Op: "replace", Path: path, Value: b}}
+	}
+
+	keys := []string{}
+	for key := range am {
+		keys = append(keys, key)
+	}
+	for key := range bm {
+		if _, ok := am[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	ops := []jsonPatchOp{}
+	for _, key := range keys {
+		pointer := path + "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+		av, inA := am[key]
+		bv, inB := bm[key]
+		switch {
+		case !inB:
+			ops = append(ops, jsonPatchOp{Op: "remove", Path: pointer})
+		case !inA:
+			ops = append(ops, jsonPatchOp{Op: "add", Path: pointer, Value: bv})
+		default:
+			ops = append(ops, jsonPatch(pointer, av, bv)...)
+		}
+	}
+
+	return ops
+}
+
+// confirmUpdate prints unified diff of object before and after update to
+// stderr. Interactive users are asked to confirm the change unless --yes
+// is given.
+func confirmUpdate(before, after interface{}) error {
+	// dry run shows the diff of the request instead
+	if noDiff || dryRun || quiet && (assumeYes || !isTerminal(os.Stdin)) {
+		return nil
+	}
+
+	a, err := privxops.DiffLines(before)
+	if err != nil {
+		return err
+	}
+
+	b, err := privxops.DiffLines(after)
+	if err != nil {
+		return err
+	}
+
+	hunks := privxops.UnifiedDiff(a, b, 3)
+	if len(hunks) == 0 {
+		info("no changes")
+		return nil
+	}
+
+	color := isTerminal(os.Stderr) && os.Getenv("NO_COLOR") == ""
+	privxops.WriteDiff(os.Stderr, hunks, color)
+
+	if assumeYes || !isTerminal(os.Stdin) {
+		return nil
+	}
+
+	fmt.Fprint(os.Stderr, "Apply changes? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	}
+
+	return errors.New("update aborted")
+}
+
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,127 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/SSHcom/privx-cli/pkg/privxops"
+)
+
+var (
+	dryRun bool
+
+	// dryRunChanges counts changes shown by dry run, the lock keeps
+	// output of concurrent calls apart
+	dryRunChanges int
+	dryRunLock    sync.Mutex
+)
+
+// errDryRun stops the command at a step which needs response of a change
+// that was not sent, it is not a failure
+var errDryRun = privxops.ErrDryRun
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "validate input and show the change without sending it to PrivX")
+}
+
+// dryRun prints the request of change to stderr instead of sending it and
+// records it, so that the command continues and shows all of its changes.
+// The payload is shown as diff against the current resource when the
+// resource exists.
+func (r *request) dryRun(method string, in interface{}) error {
+	endpoint := r.path
+	if len(r.args) > 0 {
+		endpoint = fmt.Sprintf(r.path, r.args...)
+	}
+	if r.query != nil {
+		if query, err := json.Marshal(r.query); err == nil && string(query) != "{}" {
+			endpoint += " " + string(query)
+		}
+	}
+
+	var current interface{}
+	if method != http.MethodPost {
+		if _, err := r.CURL.Get(&current); err != nil {
+			current = nil
+		}
+	}
+
+	var change []privxops.DiffHunk
+	if current != nil {
+		a, err := privxops.DiffLines(current)
+		if err != nil {
+			return err
+		}
+
+		b, err := privxops.DiffLines(in)
+		if err != nil {
+			return err
+		}
+		change = privxops.UnifiedDiff(a, b, 3)
+	}
+
+	dryRunLock.Lock()
+	defer dryRunLock.Unlock()
+
+	fmt.Fprintf(os.Stderr, "%s %s\n", method, endpoint)
+	switch {
+	case current == nil:
+		if in != nil {
+			data, err := json.MarshalIndent(in, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, string(data))
+		}
+	case len(change) == 0:
+		fmt.Fprintln(os.Stderr, "no changes")
+		return nil
+	default:
+		color := isTerminal(os.Stderr) && os.Getenv("NO_COLOR") == ""
+		privxops.WriteDiff(os.Stderr, change, color)
+	}
+
+	dryRunChanges++
+	return nil
+}
+
+// dryRunResult marks outcome of bulk item which was only shown by dry run
+func dryRunResult(err error) error {
+	if err == nil && dryRun {
+		return errDryRun
+	}
+	return err
+}
+
+// dryRunSummary tells how many changes dry run did not send
+func dryRunSummary() {
+	dryRunLock.Lock()
+	defer dryRunLock.Unlock()
+
+	if dryRun && dryRunChanges > 0 {
+		info("dry run, %d change(s) were not sent to PrivX", dryRunChanges)
+	}
+}
+
+// strictJSON decodes payload rejecting fields unknown to the SDK type,
+// so that typos are reported by dry run instead of silently dropped
+func strictJSON(data []byte, object interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(object); err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+
+	return nil
+}
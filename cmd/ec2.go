@@ -0,0 +1,132 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// ec2Instance is a minimal view of EC2 instance
+type ec2Instance struct {
+	ID        string `json:"instance_id"`
+	Name      string `json:"name"`
+	State     string `json:"state"`
+	PrivateIP string `json:"private_ip"`
+	PublicIP  string `json:"public_ip,omitempty"`
+}
+
+// awsEnvCredentials reads AWS credentials from standard environment variables
+func awsEnvCredentials() (awsCredentials, error) {
+	creds := awsCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return creds, errors.New("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are required")
+	}
+
+	return creds, nil
+}
+
+// describeInstances lists EC2 instances of the region, terminated
+// instances are excluded
+func describeInstances(creds awsCredentials, region string) ([]ec2Instance, error) {
+	host := "ec2." + region + ".amazonaws.com"
+	instances := []ec2Instance{}
+	token := ""
+
+	for {
+		query := url.Values{}
+		query.Set("Action", "DescribeInstances")
+		query.Set("Version", "2016-11-15")
+		query.Set("MaxResults", "1000")
+		if token != "" {
+			query.Set("NextToken", token)
+		}
+
+		req, err := http.NewRequestWithContext(runContext, http.MethodGet, "https://"+host+"/?"+awsQuery(query), nil)
+		if err != nil {
+			return nil, err
+		}
+		signAWSv4(req, creds, region, "ec2", time.Now().UTC())
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			var failure struct {
+				Code    string `xml:"Errors>Error>Code"`
+				Message string `xml:"Errors>Error>Message"`
+			}
+			xml.Unmarshal(body, &failure)
+			return nil, fmt.Errorf("ec2:DescribeInstances failed: %s %s", failure.Code, failure.Message)
+		}
+
+		var result struct {
+			NextToken    string `xml:"nextToken"`
+			Reservations []struct {
+				Instances []struct {
+					ID        string `xml:"instanceId"`
+					State     string `xml:"instanceState>name"`
+					PrivateIP string `xml:"privateIpAddress"`
+					PublicIP  string `xml:"ipAddress"`
+					Tags      []struct {
+						Key   string `xml:"key"`
+						Value string `xml:"value"`
+					} `xml:"tagSet>item"`
+				} `xml:"instancesSet>item"`
+			} `xml:"reservationSet>item"`
+		}
+		if err := xml.Unmarshal(body, &result); err != nil {
+			return nil, err
+		}
+
+		for _, reservation := range result.Reservations {
+			for _, instance := range reservation.Instances {
+				if instance.State == "terminated" || instance.State == "shutting-down" {
+					continue
+				}
+
+				name := instance.ID
+				for _, tag := range instance.Tags {
+					if tag.Key == "Name" && tag.Value != "" {
+						name = tag.Value
+					}
+				}
+
+				instances = append(instances, ec2Instance{
+					ID:        instance.ID,
+					Name:      name,
+					State:     instance.State,
+					PrivateIP: instance.PrivateIP,
+					PublicIP:  instance.PublicIP,
+				})
+			}
+		}
+
+		if result.NextToken == "" {
+			return instances, nil
+		}
+		token = result.NextToken
+	}
+}
@@ -0,0 +1,143 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var errorFormat string
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&errorFormat, "error-format", "text", "format of errors written to stderr: text or json")
+
+	// errors are written once by WriteError in the selected format
+	rootCmd.SilenceErrors = true
+}
+
+// apiError is a failed API call of the command. Error message is the one
+// of the SDK, HTTP status and PrivX error code are recovered from it.
+type apiError struct {
+	err    error
+	Method string
+	Path   string
+	Status int
+	Code   string
+	Detail string
+}
+
+// errorReport is machine readable error written with --error-format json
+type errorReport struct {
+	Message   string `json:"message"`
+	ExitCode  int    `json:"exit_code"`
+	Status    int    `json:"status,omitempty"`
+	Code      string `json:"code,omitempty"`
+	Method    string `json:"method,omitempty"`
+	Path      string `json:"path,omitempty"`
+	RequestID string `json:"request_id"`
+}
+
+var (
+	// sdkHTTPError is the SDK error of response without PrivX error body
+	sdkHTTPError = regexp.MustCompile(`^HTTP error: ([1-5][0-9][0-9])\b`)
+
+	// sdkErrorResponse is the SDK error rendered from PrivX error body,
+	// e.g. "error: CODE, message: MESSAGE, property: NAME"
+	sdkErrorResponse = regexp.MustCompile(`^error: ([^,]*)(?:, message: (.*?))?(?:, property: .*|, \{error: .*)?$`)
+
+	// sdkUnauthorized is the SDK error after repeated 401 responses
+	sdkUnauthorized = regexp.MustCompile(`^request failed after [0-9]+ tries$`)
+)
+
+func (e *apiError) Error() string {
+	return e.err.Error()
+}
+
+func (e *apiError) Unwrap() error {
+	return e.err
+}
+
+// newAPIError recovers details of SDK error of the API call. The SDK
+// renders PrivX error body as error code and message without the HTTP
+// status, the status is known only for responses without the body.
+func newAPIError(method, path string, err error) error {
+	var known *apiError
+	if err == nil || errors.Is(err, errDryRun) || errors.As(err, &known) {
+		return err
+	}
+
+	msg := err.Error()
+	e := &apiError{err: err, Method: method, Path: path}
+
+	switch {
+	case sdkHTTPError.MatchString(msg):
+		e.Status, _ = strconv.Atoi(sdkHTTPError.FindStringSubmatch(msg)[1])
+	case sdkErrorResponse.MatchString(msg):
+		m := sdkErrorResponse.FindStringSubmatch(msg)
+		e.Code, e.Detail = m[1], m[2]
+	case sdkUnauthorized.MatchString(msg):
+		e.Status = http.StatusUnauthorized
+	default:
+		// transport errors, e.g. refused connection, are not API errors
+		return err
+	}
+
+	return e
+}
+
+// statusCode returns HTTP status of failed API call, 0 for other errors
+func statusCode(err error) int {
+	var api *apiError
+	if errors.As(newAPIError("", "", err), &api) {
+		return api.Status
+	}
+	return 0
+}
+
+// WriteError writes error of Execute in the format of --error-format
+func WriteError(w io.Writer, err error) {
+	report := errorReport{
+		Message:   err.Error(),
+		ExitCode:  ExitCode(err),
+		RequestID: requestID,
+	}
+
+	var api *apiError
+	if errors.As(err, &api) {
+		report.Status, report.Code = api.Status, api.Code
+		report.Method, report.Path = api.Method, api.Path
+		if api.Detail != "" {
+			report.Message = api.Detail
+		}
+	}
+
+	if errorFormat == "json" {
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+
+	details := []string{}
+	if report.Status != 0 {
+		details = append(details, fmt.Sprintf("HTTP %d", report.Status))
+	}
+	if report.Code != "" {
+		details = append(details, report.Code)
+	}
+	if report.Method != "" {
+		details = append(details, report.Method+" "+report.Path)
+	}
+	details = append(details, "request ID "+report.RequestID)
+
+	fmt.Fprintf(w, "Error: %s (%s)\n", report.Message, strings.Join(details, ", "))
+}
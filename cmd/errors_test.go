@@ -0,0 +1,129 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/SSHcom/privx-sdk-go/restapi"
+)
+
+func sdkError(status int, body string) error {
+	return restapi.ErrorFromResponse(&http.Response{
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode: status,
+	}, []byte(body))
+}
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		api    bool
+		status int
+		code   string
+		detail string
+	}{
+		{
+			name:   "empty body",
+			err:    sdkError(http.StatusNotFound, ""),
+			api:    true,
+			status: http.StatusNotFound,
+		},
+		{
+			name:   "html body",
+			err:    sdkError(http.StatusBadGateway, "<html><body>Bad Gateway</body></html>"),
+			api:    true,
+			status: http.StatusBadGateway,
+		},
+		{
+			name:   "error code and message",
+			err:    sdkError(http.StatusNotFound, `{"error_code":"NOT_FOUND","error_message":"role 404 does not exist"}`),
+			api:    true,
+			code:   "NOT_FOUND",
+			detail: "role 404 does not exist",
+		},
+		{
+			name: "error code only",
+			err:  sdkError(http.StatusForbidden, `{"error_code":"FORBIDDEN"}`),
+			api:  true,
+			code: "FORBIDDEN",
+		},
+		{
+			name:   "error property and details",
+			err:    sdkError(http.StatusBadRequest, `{"error_code":"BAD_REQUEST","error_message":"invalid role, see details","property":"name","details":[{"error_code":"EMPTY","property":"name"}]}`),
+			api:    true,
+			code:   "BAD_REQUEST",
+			detail: "invalid role, see details",
+		},
+		{
+			name: "property without message",
+			err:  sdkError(http.StatusBadRequest, `{"error_code":"BAD_REQUEST","property":"name"}`),
+			api:  true,
+			code: "BAD_REQUEST",
+		},
+		{
+			name:   "repeated unauthorized",
+			err:    errors.New("request failed after 2 tries"),
+			api:    true,
+			status: http.StatusUnauthorized,
+		},
+		{
+			name: "transport error",
+			err:  errors.New("dial tcp 127.0.0.1:443: connect: connection refused"),
+		},
+		{
+			name: "status in transport error",
+			err:  errors.New("proxy returned HTTP error: 503"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := newAPIError(http.MethodGet, "/role-store/api/v1/roles/%s", test.err)
+			if err.Error() != test.err.Error() {
+				t.Errorf("message = %q, want %q", err.Error(), test.err.Error())
+			}
+
+			var api *apiError
+			if errors.As(err, &api) != test.api {
+				t.Fatalf("api error = %v, want %v", !test.api, test.api)
+			}
+			if !test.api {
+				return
+			}
+
+			if api.Status != test.status {
+				t.Errorf("status = %d, want %d", api.Status, test.status)
+			}
+			if api.Code != test.code {
+				t.Errorf("code = %q, want %q", api.Code, test.code)
+			}
+			if api.Detail != test.detail {
+				t.Errorf("detail = %q, want %q", api.Detail, test.detail)
+			}
+			if status := statusCode(test.err); status != test.status {
+				t.Errorf("statusCode = %d, want %d", status, test.status)
+			}
+		})
+	}
+}
+
+func TestNewAPIErrorWrapped(t *testing.T) {
+	err := newAPIError(http.MethodDelete, "/vault/api/v1/secrets/%s", sdkError(http.StatusNotFound, ""))
+	wrapped := fmt.Errorf("secret db: %w", err)
+
+	if status := statusCode(wrapped); status != http.StatusNotFound {
+		t.Errorf("statusCode = %d, want %d", status, http.StatusNotFound)
+	}
+	if again := newAPIError(http.MethodGet, "/", wrapped); again != wrapped {
+		t.Errorf("known api error is wrapped again")
+	}
+}
@@ -0,0 +1,358 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/api/monitor"
+	"github.com/spf13/cobra"
+)
+
+type eventOptions struct {
+	listen          string
+	path            string
+	secret          string
+	signatureHeader string
+	exec            string
+	certFile        string
+	keyFile         string
+	since           string
+	until           string
+	userID          string
+	eventType       string
+	follow          bool
+	interval        time.Duration
+	page            pagination
+}
+
+func init() {
+	rootCmd.AddCommand(eventsCmd())
+}
+
+//
+//
+func eventsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "events",
+		Short:        "Receive and process PrivX events",
+		Long:         `Receive and process PrivX events`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(eventListCmd())
+	cmd.AddCommand(eventWebhookServerCmd())
+
+	return cmd
+}
+
+//
+//
+func eventListCmd() *cobra.Command {
+	options := eventOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List audit events",
+		Long: `List audit events of the monitor service. With --follow new events are polled
+and streamed to stdout as JSON lines until interrupted, suitable for piping
+into SIEM shipper.`,
+		Example: `
+	privx-cli events list [access flags] --since 24h --type LOGIN_FAILED
+	privx-cli events list [access flags] --user <USER-ID> --since 2021-06-01T00:00:00Z --until 2021-07-01T00:00:00Z
+	privx-cli events list [access flags] --follow --interval 10s | siem-shipper
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return eventList(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.since, "since", "", "events after timestamp (RFC3339) or duration ago (e.g. 24h, 7d)")
+	flags.StringVar(&options.until, "until", "", "events before timestamp (RFC3339) or duration ago (e.g. 24h, 7d)")
+	flags.StringVar(&options.userID, "user", "", "filter events by user ID")
+	flags.StringVar(&options.eventType, "type", "", "comma separated event names, e.g. LOGIN_FAILED")
+	flags.BoolVarP(&options.follow, "follow", "f", false, "poll new events and stream them as JSON lines")
+	flags.DurationVar(&options.interval, "interval", 5*time.Second, "polling interval of --follow")
+	options.page.register(cmd)
+
+	return cmd
+}
+
+// eventFilter selects audit events of the list command
+type eventFilter struct {
+	since, until time.Time
+	userID       string
+	types        []string
+}
+
+func (f eventFilter) match(event map[string]interface{}) bool {
+	at, err := time.Parse(time.RFC3339, fmt.Sprint(event["timestamp"]))
+	if err == nil && (!f.since.IsZero() && at.Before(f.since) || !f.until.IsZero() && at.After(f.until)) {
+		return false
+	}
+
+	if f.userID != "" && fmt.Sprint(event["user_id"]) != f.userID {
+		return false
+	}
+
+	name := fmt.Sprint(firstOf(event, "event", "event_name"))
+	if len(f.types) > 0 && !anyOf(f.types, []string{name}) {
+		return false
+	}
+
+	return true
+}
+
+func eventList(options eventOptions) error {
+	filter := eventFilter{userID: options.userID}
+
+	var err error
+	if options.since != "" {
+		if filter.since, err = parseTimeFlag(options.since); err != nil {
+			return err
+		}
+	}
+	if options.until != "" {
+		if filter.until, err = parseTimeFlag(options.until); err != nil {
+			return err
+		}
+	}
+	if options.eventType != "" {
+		filter.types = strings.Split(options.eventType, ",")
+	}
+
+	if !options.follow {
+		events, err := searchEvents(filter, nil)
+		if err != nil {
+			return err
+		}
+		page, err := options.page.Apply(events)
+		if err != nil {
+			return err
+		}
+		return stdout(page)
+	}
+
+	if options.interval <= 0 {
+		return fmt.Errorf("invalid --interval: %s", options.interval)
+	}
+
+	if filter.since.IsZero() {
+		filter.since = time.Now()
+	}
+
+	// events of the last seen timestamp are remembered to avoid duplicates
+	seen := map[string]bool{}
+	encoder := json.NewEncoder(os.Stdout)
+
+	for {
+		events, err := searchEvents(filter, seen)
+		if interrupted() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			if err := encoder.Encode(event); err != nil {
+				return err
+			}
+
+			at, err := time.Parse(time.RFC3339, fmt.Sprint(event["timestamp"]))
+			if err != nil {
+				continue
+			}
+			if at.After(filter.since) {
+				filter.since = at
+				seen = map[string]bool{}
+			}
+			seen[fmt.Sprint(event["id"])] = true
+		}
+
+		select {
+		case <-runContext.Done():
+			return nil
+		case <-time.After(options.interval):
+		}
+	}
+}
+
+// searchEvents fetches all matching events in chronological order,
+// events with ID in seen are skipped
+func searchEvents(filter eventFilter, seen map[string]bool) ([]map[string]interface{}, error) {
+	api := monitor.New(curl())
+	limit := 100
+
+	query := map[string]interface{}{}
+	if !filter.since.IsZero() {
+		query["start_time"] = filter.since.UTC().Format(time.RFC3339)
+	}
+	if !filter.until.IsZero() {
+		query["end_time"] = filter.until.UTC().Format(time.RFC3339)
+	}
+	if filter.userID != "" {
+		query["user_id"] = filter.userID
+	}
+
+	var searchObject monitor.AuditEventSearchObject
+	if err := remarshal(query, &searchObject); err != nil {
+		return nil, err
+	}
+
+	events := []map[string]interface{}{}
+	for offset := 0; ; offset += limit {
+		page, err := api.SearchAuditEvents(offset, limit, "timestamp", "ASC", false, &searchObject)
+		if err != nil {
+			return nil, err
+		}
+
+		var items []map[string]interface{}
+		if err := remarshal(page, &items); err != nil {
+			return nil, err
+		}
+
+		for _, event := range items {
+			if seen[fmt.Sprint(event["id"])] || !filter.match(event) {
+				continue
+			}
+			events = append(events, event)
+		}
+
+		if len(items) < limit {
+			return events, nil
+		}
+	}
+}
+
+//
+//
+func eventWebhookServerCmd() *cobra.Command {
+	options := eventOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "webhook-server",
+		Short: "Receive PrivX webhook callbacks",
+		Long: `Run HTTP listener for PrivX webhook callbacks. Each received event is printed
+to stdout as a JSON line, or given to --exec command on stdin. With --secret
+the HMAC-SHA256 signature of the request body is verified, requests with
+invalid signature are rejected.`,
+		Example: `
+	privx-cli events webhook-server --listen :8080 --secret $WEBHOOK_SECRET
+	privx-cli events webhook-server --listen :8443 --cert tls.crt --key tls.key --exec ./on-event.sh
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return eventWebhookServer(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.listen, "listen", ":8080", "listen address")
+	flags.StringVar(&options.path, "path", "/", "callback URL path")
+	flags.StringVar(&options.secret, "secret", os.Getenv("PRIVX_WEBHOOK_SECRET"), "shared secret of HMAC-SHA256 signature")
+	flags.StringVar(&options.signatureHeader, "signature-header", "X-Signature", "HTTP header carrying the signature")
+	flags.StringVar(&options.exec, "exec", "", "command executed for each event, event is given on stdin")
+	flags.StringVar(&options.certFile, "cert", "", "TLS certificate file")
+	flags.StringVar(&options.keyFile, "key", "", "TLS private key file")
+
+	return cmd
+}
+
+func eventWebhookServer(options eventOptions) error {
+	var mu sync.Mutex
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+
+		if options.secret != "" && !validSignature(options.secret, body, r.Header.Get(options.signatureHeader)) {
+			info("rejected event from %s: invalid signature", r.RemoteAddr)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+
+		var event bytes.Buffer
+		if err := json.Compact(&event, body); err != nil {
+			http.Error(w, "invalid JSON", http.StatusBadRequest)
+			return
+		}
+
+		// events are processed one by one to keep output lines intact
+		mu.Lock()
+		defer mu.Unlock()
+
+		if options.exec != "" {
+			if err := runEventHook(options.exec, event.Bytes()); err != nil {
+				info("event hook failed: %v", err)
+				http.Error(w, "event hook failed", http.StatusInternalServerError)
+				return
+			}
+		} else {
+			event.WriteByte('\n')
+			os.Stdout.Write(event.Bytes())
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(options.path, handler)
+
+	info("Listening webhook callbacks at %s%s", options.listen, options.path)
+	if options.certFile != "" {
+		return http.ListenAndServeTLS(options.listen, options.certFile, options.keyFile, mux)
+	}
+
+	return http.ListenAndServe(options.listen, mux)
+}
+
+// validSignature checks hex encoded HMAC-SHA256 of body, optionally
+// prefixed with algorithm as sha256=<hex>
+func validSignature(secret string, body []byte, signature string) bool {
+	signature = strings.TrimPrefix(strings.TrimSpace(signature), "sha256=")
+	given, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return hmac.Equal(given, mac.Sum(nil))
+}
+
+func runEventHook(command string, event []byte) error {
+	hook := exec.Command("sh", "-c", command)
+	hook.Stdin = bytes.NewReader(event)
+	hook.Stdout = os.Stdout
+	hook.Stderr = os.Stderr
+
+	return hook.Run()
+}
@@ -0,0 +1,237 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/spf13/cobra"
+)
+
+type explainOptions struct {
+	userID string
+	host   string
+}
+
+// accessPath is a chain from user to target account
+type accessPath struct {
+	Grant   string `json:"grant"`
+	Source  string `json:"source,omitempty"`
+	Rule    string `json:"rule,omitempty"`
+	Matched string `json:"matched,omitempty"`
+	RoleID  string `json:"role_id"`
+	Role    string `json:"role"`
+	HostID  string `json:"host_id"`
+	Host    string `json:"host"`
+	Account string `json:"account"`
+}
+
+type accessExplanation struct {
+	UserID string       `json:"user_id"`
+	User   string       `json:"user"`
+	Host   string       `json:"host"`
+	Access bool         `json:"access"`
+	Paths  []accessPath `json:"paths"`
+	Reason string       `json:"reason,omitempty"`
+}
+
+func init() {
+	rootCmd.AddCommand(explainAccessCmd())
+}
+
+//
+//
+func explainAccessCmd() *cobra.Command {
+	options := explainOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "explain-access",
+		Short: "Explain how user gets access to host",
+		Long: `Explain the chain from user to host account: directory attribute of the user,
+source rule, role, host role mapping and target account. If there is no
+path, the reason is stated.`,
+		Example: `
+	privx-cli explain-access [access flags] --user <USER-ID> --host <HOST-ID>
+	privx-cli explain-access [access flags] --user <USER-ID> --host web01.example.com
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return explainAccess(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.userID, "user", "", "user ID")
+	flags.StringVar(&options.host, "host", "", "host ID or common name")
+	cmd.MarkFlagRequired("user")
+	cmd.MarkFlagRequired("host")
+
+	return cmd
+}
+
+func explainAccess(options explainOptions) error {
+	api := rolestore.New(curl())
+
+	user, err := api.User(options.userID)
+	if err != nil {
+		return err
+	}
+
+	hosts, err := allHosts()
+	if err != nil {
+		return err
+	}
+
+	var host *hostView
+	for i := range hosts {
+		if hosts[i].ID == options.host || strings.EqualFold(hosts[i].CommonName, options.host) {
+			host = &hosts[i]
+			break
+		}
+	}
+	if host == nil {
+		return fmt.Errorf("host not found: %s", options.host)
+	}
+
+	roles, err := api.Roles()
+	if err != nil {
+		return err
+	}
+
+	var mapping []mappingRole
+	if err := remarshal(roles, &mapping); err != nil {
+		return err
+	}
+	rules := map[string]mappingRule{}
+	for _, role := range mapping {
+		rules[role.ID] = role.SourceRules
+	}
+
+	directoryUser := directoryAttributes(user)
+
+	result := accessExplanation{
+		UserID: user.ID,
+		User:   user.Principal,
+		Host:   host.CommonName,
+		Paths:  []accessPath{},
+	}
+
+	hostRoles := map[string]bool{}
+	for _, principal := range host.Principals {
+		for _, ref := range principal.Roles {
+			hostRoles[ref.ID] = true
+		}
+	}
+
+	for _, role := range user.Roles {
+		for _, principal := range host.Principals {
+			for _, ref := range principal.Roles {
+				if ref.ID != role.ID {
+					continue
+				}
+
+				path := accessPath{
+					Grant:   "explicit",
+					RoleID:  role.ID,
+					Role:    role.Name,
+					HostID:  host.ID,
+					Host:    host.CommonName,
+					Account: principal.Principal,
+				}
+
+				if !role.Explicit {
+					path.Grant = "source rule"
+					if rule, matched := rules[role.ID].explain(user.Source, directoryUser); rule != nil {
+						path.Source = rule.Source
+						path.Rule = rule.Pattern
+						path.Matched = matched
+					}
+				}
+
+				result.Paths = append(result.Paths, path)
+			}
+		}
+	}
+
+	result.Access = len(result.Paths) > 0
+	switch {
+	case result.Access:
+	case len(user.Roles) == 0:
+		result.Reason = "user has no roles"
+	case len(hostRoles) == 0:
+		result.Reason = "host has no role mappings"
+	default:
+		names := []string{}
+		for _, role := range user.Roles {
+			names = append(names, role.Name)
+		}
+		result.Reason = fmt.Sprintf("none of user roles (%s) is mapped to host accounts",
+			strings.Join(names, ", "))
+	}
+
+	return stdout(result)
+}
+
+// directoryAttributes are attributes of role store user which source rules
+// are matched against, the role store does not expose group membership
+func directoryAttributes(user *rolestore.User) mappingUser {
+	attributes := map[string]string{
+		"principal":          user.Principal,
+		"distinguished_name": user.DistinguishedName,
+		"email":              user.Email,
+		"full_name":          user.FullName,
+		"given_name":         user.GivenName,
+		"job_title":          user.Job,
+		"company":            user.Company,
+		"department":         user.Department,
+	}
+	for key, value := range attributes {
+		if value == "" {
+			delete(attributes, key)
+		}
+	}
+
+	return mappingUser{Groups: []string{}, Attributes: attributes}
+}
+
+// explain returns the first rule of the tree that matches the user together
+// with the matching group or attribute
+func (rule mappingRule) explain(source string, user mappingUser) (*mappingRule, string) {
+	if rule.Type == "RULE" {
+		if rule.Source != source || rule.Pattern == "" {
+			return nil, ""
+		}
+
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, ""
+		}
+
+		for _, group := range user.Groups {
+			if re.MatchString(group) {
+				return &rule, "group " + group
+			}
+		}
+		for key, value := range user.Attributes {
+			if re.MatchString(value) {
+				return &rule, "attribute " + key + "=" + value
+			}
+		}
+		return nil, ""
+	}
+
+	for _, sub := range rule.Rules {
+		if match, matched := sub.explain(source, user); match != nil {
+			return match, matched
+		}
+	}
+
+	return nil, ""
+}
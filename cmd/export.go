@@ -0,0 +1,467 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/settings"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+type exportOptions struct {
+	dir            string
+	format         string
+	types          []string
+	settingsScopes []string
+}
+
+// exportKind is a resource type of export, kinds are imported in the
+// order of the list so that references resolve to imported IDs
+type exportKind struct {
+	name      string
+	path      string
+	nameField string
+	paged     bool
+}
+
+var exportKinds = []exportKind{
+	{name: "sources", path: "/role-store/api/v1/sources", nameField: "name"},
+	{name: "roles", path: "/role-store/api/v1/roles", nameField: "name"},
+	{name: "access-groups", path: "/authorizer/api/v1/accessgroups", nameField: "name", paged: true},
+	{name: "hosts", path: "/host-store/api/v1/hosts", nameField: "common_name", paged: true},
+}
+
+// exportSettings is the kind of settings, exported per scope
+const exportSettings = "settings"
+
+// importReadOnly are fields maintained by PrivX, not sent on import
+var importReadOnly = []string{"id", "created", "updated", "updated_by", "author"}
+
+var unsafeFileName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// exportedResource is a resource written to export directory
+type exportedResource struct {
+	Type string `json:"type"`
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+	File string `json:"file"`
+}
+
+// importedResource is a resource created or updated by import
+type importedResource struct {
+	Type   string `json:"type"`
+	File   string `json:"file"`
+	Status string `json:"status"`
+	OldID  string `json:"old_id,omitempty"`
+	NewID  string `json:"new_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd())
+	rootCmd.AddCommand(importCmd())
+}
+
+//
+//
+func exportCmd() *cobra.Command {
+	options := exportOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export resources into directory tree",
+		Long: `Export roles, hosts, sources, access groups and settings into directory tree,
+one file per resource under directory of the resource type. The tree is imported
+into another PrivX instance with import, or kept in version control.`,
+		Example: `
+	privx-cli export [access flags] --dir ./privx
+	privx-cli export [access flags] --dir ./privx --format yaml --types roles,sources
+	privx-cli export [access flags] --dir ./privx --types settings --settings-scope GLOBAL,AUTH
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return export(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.dir, "dir", "", "export directory")
+	flags.StringVar(&options.format, "format", "json", "file format: json or yaml")
+	flags.StringSliceVar(&options.types, "types", exportTypes(), "resource types to export")
+	flags.StringSliceVar(&options.settingsScopes, "settings-scope", []string{"GLOBAL"}, "settings scopes to export")
+	cmd.MarkFlagRequired("dir")
+
+	return cmd
+}
+
+//
+//
+func importCmd() *cobra.Command {
+	options := exportOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import resources from directory tree",
+		Long: `Import resources from directory tree written by export. Resources are matched
+by name, existing ones are updated and missing ones are created. IDs of the exported
+instance are remapped to IDs of this instance, including references between resources,
+e.g. source of role or access group of host. Use --dry-run to preview the changes.`,
+		Example: `
+	privx-cli import [access flags] --dir ./privx
+	privx-cli import [access flags] --dir ./privx --types roles --dry-run
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return importResources(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.dir, "dir", "", "export directory")
+	flags.StringSliceVar(&options.types, "types", exportTypes(), "resource types to import")
+	cmd.MarkFlagRequired("dir")
+
+	return cmd
+}
+
+func exportTypes() []string {
+	types := []string{}
+	for _, kind := range exportKinds {
+		types = append(types, kind.name)
+	}
+	return append(types, exportSettings)
+}
+
+func export(options exportOptions) error {
+	if options.format != "json" && options.format != "yaml" {
+		return fmt.Errorf("unsupported format: %s", options.format)
+	}
+
+	exported := []exportedResource{}
+
+	for _, kind := range exportKinds {
+		if !anyOf(options.types, []string{kind.name}) {
+			continue
+		}
+
+		items, err := exportList(kind)
+		if err != nil {
+			return err
+		}
+
+		used := map[string]bool{}
+		for _, item := range items {
+			id, _ := item["id"].(string)
+			name := fmt.Sprint(item[kind.nameField])
+
+			base := unsafeFileName.ReplaceAllString(name, "_")
+			if base == "" || used[base] {
+				base += "-" + id
+			}
+			used[base] = true
+
+			file := filepath.Join(options.dir, kind.name, base+"."+options.format)
+			if err := writeExport(file, item, options.format); err != nil {
+				return err
+			}
+			exported = append(exported, exportedResource{Type: kind.name, ID: id, Name: name, File: file})
+		}
+	}
+
+	if anyOf(options.types, []string{exportSettings}) {
+		api := settings.New(curl())
+		for _, scope := range options.settingsScopes {
+			scope = strings.ToUpper(scope)
+			data, err := api.ScopeSettings(scope, "")
+			if err != nil {
+				return err
+			}
+
+			file := filepath.Join(options.dir, exportSettings, scope+"."+options.format)
+			if err := writeExport(file, data, options.format); err != nil {
+				return err
+			}
+			exported = append(exported, exportedResource{Type: exportSettings, Name: scope, File: file})
+		}
+	}
+
+	return stdout(exported)
+}
+
+// pageQuery is query string of paginated REST endpoints, the SDK encodes
+// the query from struct fields
+type pageQuery struct {
+	Offset int `json:"offset"`
+	Limit  int `json:"limit"`
+}
+
+// exportList fetches all resources of kind as raw objects
+func exportList(kind exportKind) ([]map[string]interface{}, error) {
+	limit := 100
+	items := []map[string]interface{}{}
+
+	for offset := 0; ; offset += limit {
+		var page struct {
+			Items []map[string]interface{} `json:"items"`
+		}
+
+		req := curl().URL(kind.path)
+		if kind.paged {
+			req = req.Query(pageQuery{Offset: offset, Limit: limit})
+		}
+		if _, err := req.Get(&page); err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+
+		if !kind.paged || len(page.Items) < limit {
+			return items, nil
+		}
+	}
+}
+
+func writeExport(file string, data interface{}, format string) error {
+	var raw interface{}
+	if err := remarshal(data, &raw); err != nil {
+		return err
+	}
+
+	var (
+		bytes []byte
+		err   error
+	)
+	switch format {
+	case "yaml":
+		bytes, err = yaml.Marshal(raw)
+	default:
+		bytes, err = json.MarshalIndent(raw, "", "  ")
+		bytes = append(bytes, '\n')
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(file, textFile(bytes))
+}
+
+func importResources(options exportOptions) error {
+	// IDs of exported instance mapped to IDs of this instance
+	ids := map[string]string{}
+	imported := []importedResource{}
+	failed := 0
+
+	for _, kind := range exportKinds {
+		if !anyOf(options.types, []string{kind.name}) {
+			continue
+		}
+
+		files, err := exportFiles(filepath.Join(options.dir, kind.name))
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			continue
+		}
+
+		existing, err := exportList(kind)
+		if err != nil {
+			return err
+		}
+		byName := map[string]string{}
+		for _, item := range existing {
+			if id, ok := item["id"].(string); ok {
+				byName[fmt.Sprint(item[kind.nameField])] = id
+			}
+		}
+
+		for _, file := range files {
+			result := importResource(kind, file, byName, ids)
+			if result.Status == "failed" {
+				failed++
+			}
+			imported = append(imported, result)
+		}
+	}
+
+	if anyOf(options.types, []string{exportSettings}) {
+		files, err := exportFiles(filepath.Join(options.dir, exportSettings))
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			result := importSettings(file, ids)
+			if result.Status == "failed" {
+				failed++
+			}
+			imported = append(imported, result)
+		}
+	}
+
+	if err := stdout(imported); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d resources failed", failed, len(imported))
+	}
+
+	return nil
+}
+
+func importResource(kind exportKind, file string, byName, ids map[string]string) importedResource {
+	result := importedResource{Type: kind.name, File: file}
+
+	object, err := readExport(file)
+	if err != nil {
+		return result.failed(err)
+	}
+
+	result.OldID, _ = object["id"].(string)
+	for _, field := range importReadOnly {
+		delete(object, field)
+	}
+	object = remapIDs(object, ids).(map[string]interface{})
+
+	if id, ok := byName[fmt.Sprint(object[kind.nameField])]; ok {
+		object["id"] = id
+		_, err = curl().URL(kind.path + "/" + url.PathEscape(id)).Put(object)
+		result.NewID, result.Status = id, "updated"
+	} else {
+		var created struct {
+			ID string `json:"id"`
+		}
+		_, err = curl().URL(kind.path).Post(object, &created)
+		result.NewID, result.Status = created.ID, "created"
+	}
+
+	switch {
+	case err == nil && dryRun:
+		result.Status = "dry-run"
+	case err != nil:
+		return result.failed(err)
+	}
+
+	if result.OldID != "" && result.NewID != "" {
+		ids[result.OldID] = result.NewID
+	}
+
+	return result
+}
+
+func importSettings(file string, ids map[string]string) importedResource {
+	result := importedResource{Type: exportSettings, File: file}
+
+	object, err := readExport(file)
+	if err != nil {
+		return result.failed(err)
+	}
+
+	scope := strings.ToUpper(strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)))
+	data, err := json.Marshal(remapIDs(object, ids))
+	if err != nil {
+		return result.failed(err)
+	}
+
+	payload := json.RawMessage(data)
+	err = settings.New(curl()).UpdateScopeSettings(&payload, scope)
+	switch {
+	case err == nil && dryRun:
+		result.Status = "dry-run"
+	case err != nil:
+		return result.failed(err)
+	default:
+		result.Status = "updated"
+	}
+
+	return result
+}
+
+func (r importedResource) failed(err error) importedResource {
+	r.Status, r.Error = "failed", err.Error()
+	return r
+}
+
+// exportFiles lists JSON and YAML files of directory, missing directory
+// has no files
+func exportFiles(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		switch filepath.Ext(entry.Name()) {
+		case ".json", ".yaml", ".yml":
+			if !entry.IsDir() {
+				files = append(files, filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
+
+func readExport(file string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	data = stripBOM(data)
+
+	object := map[string]interface{}{}
+	if ext := filepath.Ext(file); ext == ".yaml" || ext == ".yml" {
+		err = yaml.Unmarshal(data, &object)
+	} else {
+		err = json.Unmarshal(data, &object)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	return object, nil
+}
+
+// remapIDs replaces references to IDs of exported instance with IDs of
+// this instance
+func remapIDs(value interface{}, ids map[string]string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = remapIDs(item, ids)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = remapIDs(item, ids)
+		}
+	case string:
+		if id, ok := ids[v]; ok {
+			return id
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,177 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/api/authorizer"
+	"github.com/SSHcom/privx-sdk-go/api/userstore"
+	"github.com/spf13/cobra"
+)
+
+type extenderOptions struct {
+	name        string
+	groupID     string
+	out         string
+	systemdUnit string
+	installDir  string
+	wait        waiting
+}
+
+// extenderBootstrap are artifacts of bootstrapped extender
+type extenderBootstrap struct {
+	ClientID    string `json:"client_id"`
+	Name        string `json:"name"`
+	Config      string `json:"config"`
+	SystemdUnit string `json:"systemd_unit,omitempty"`
+}
+
+// extenderUnit is systemd unit template of extender, the pre-config is
+// installed as extender-config.toml of the install directory
+const extenderUnit = `[Unit]
+Description=PrivX Extender %s
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+ExecStart=%s/privx-extender -config %s/extender-config.toml
+Restart=on-failure
+RestartSec=5
+
+[Install]
+WantedBy=multi-user.target
+`
+
+func init() {
+	rootCmd.AddCommand(extenderCmd())
+}
+
+//
+//
+func extenderCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "extender",
+		Short:        "Deploy PrivX extenders",
+		Long:         `Deploy PrivX extenders`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(extenderBootstrapCmd())
+
+	return cmd
+}
+
+//
+//
+func extenderBootstrapCmd() *cobra.Command {
+	options := extenderOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "bootstrap",
+		Short: "Register extender and download its pre-configured config",
+		Long: `Register extender as trusted client, wait until PrivX serves its configuration
+and download the pre-configured config file. With --systemd-unit a systemd unit
+template running the extender from --install-dir is written as well.`,
+		Example: `
+	privx-cli extender bootstrap [access flags] --name extender-eu-1 --group-id <ACCESS-GROUP-ID>
+	privx-cli extender bootstrap [access flags] --name extender-eu-1 --group-id <ACCESS-GROUP-ID> \
+		--out extender-config.toml --systemd-unit privx-extender.service
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return extenderBootstrapRun(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.name, "name", "", "extender name")
+	flags.StringVar(&options.groupID, "group-id", "", "access group ID of extender")
+	flags.StringVar(&options.out, "out", "extender-config.toml", "file name of pre-configured config")
+	flags.StringVar(&options.systemdUnit, "systemd-unit", "", "write systemd unit template to file")
+	flags.StringVar(&options.installDir, "install-dir", "/opt/privx-extender", "install directory of extender used by the unit")
+	options.wait = waiting{enabled: true, timeout: time.Minute}
+	options.wait.register(cmd, "PrivX serves configuration of registered extender")
+	cmd.MarkFlagRequired("name")
+
+	return cmd
+}
+
+func extenderBootstrapRun(options extenderOptions) error {
+	definition := map[string]interface{}{
+		"type":        "EXTENDER",
+		"name":        options.name,
+		"permissions": trustedClientPermissions["EXTENDER"],
+	}
+	if options.groupID != "" {
+		definition["group_id"] = options.groupID
+	}
+
+	var client userstore.TrustedClient
+	if err := remarshal(definition, &client); err != nil {
+		return err
+	}
+
+	id, err := userstore.New(curl()).CreateTrustedClient(client)
+	if err != nil {
+		return err
+	}
+	info("extender %s registered as trusted client %s", options.name, id)
+
+	sessionID, err := extenderConfigHandle(id, options.wait)
+	if err != nil {
+		return fmt.Errorf("extender %s: %w", id, err)
+	}
+
+	api := authorizer.New(curl())
+	err = downloadFile(options.out, func() error {
+		return api.DownloadExtenderConfig(id, sessionID, options.out)
+	})
+	if err != nil {
+		return fmt.Errorf("extender %s: %w", id, err)
+	}
+
+	result := extenderBootstrap{ClientID: id, Name: options.name, Config: options.out}
+
+	if options.systemdUnit != "" {
+		dir := filepath.ToSlash(options.installDir)
+		unit := fmt.Sprintf(extenderUnit, options.name, dir, dir)
+		if err := writeFileAtomic(options.systemdUnit, []byte(unit)); err != nil {
+			return err
+		}
+		result.SystemdUnit = options.systemdUnit
+	}
+
+	return stdout(result)
+}
+
+// extenderConfigHandle waits until PrivX issues download session of the
+// configuration of the registered extender
+func extenderConfigHandle(id string, wait waiting) (string, error) {
+	api := authorizer.New(curl())
+	var sessionID string
+
+	err := wait.poll("configuration of extender "+id, func() (bool, error) {
+		handle, err := api.ExtenderConfigDownloadHandle(id)
+		if err == nil && dryRun {
+			return false, errDryRun
+		}
+		if err == nil {
+			sessionID = handle.SessionID
+			return true, nil
+		}
+		if !wait.enabled || !pending(err) {
+			return false, err
+		}
+		return false, nil
+	})
+
+	return sessionID, err
+}
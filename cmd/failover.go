@@ -0,0 +1,191 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// healthPath is polled to check that PrivX node serves API requests
+const healthPath = "/auth/api/v1/status"
+
+var (
+	endpointOnce sync.Once
+	endpointURL  string
+)
+
+// baseURLs returns API endpoints of HA deployment. Multiple endpoints
+// are separated by commas in PRIVX_API_BASE_URL or base_url of profile.
+func baseURLs() []string {
+	addrs := os.Getenv("PRIVX_API_BASE_URL")
+	if addrs == "" && profileActive {
+		addrs = profileAttributes()["base_url"]
+	}
+
+	urls := []string{}
+	for _, url := range strings.Split(addrs, ",") {
+		if url = strings.TrimRight(strings.TrimSpace(url), "/"); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// endpoint selects healthy API endpoint if several are configured. The
+// selection is sticky, last healthy endpoint is tried first so that
+// consecutive commands use the same node.
+func endpoint() string {
+	endpointOnce.Do(func() {
+		urls := baseURLs()
+		if len(urls) < 2 {
+			return
+		}
+
+		sticky := readStickyEndpoint()
+		candidates := []string{}
+		for _, url := range urls {
+			if url == sticky {
+				candidates = append([]string{url}, candidates...)
+			} else {
+				candidates = append(candidates, url)
+			}
+		}
+
+		for _, url := range candidates {
+			if healthy(url) {
+				endpointURL = url
+				if url != sticky {
+					writeStickyEndpoint(url)
+				}
+				return
+			}
+		}
+
+		// all nodes are down, let the first one to report the error
+		endpointURL = candidates[0]
+	})
+
+	return endpointURL
+}
+
+func healthy(url string) bool {
+	client := http.Client{
+		Timeout: 3 * time.Second,
+		Transport: &http.Transport{
+			// certificate is verified as by the API client, the probe
+			// selects the node that API calls are sent to
+			TLSClientConfig: &tls.Config{RootCAs: apiRootCAs()},
+		},
+	}
+
+	resp, err := client.Get(url + healthPath)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode < 500
+}
+
+// apiRootCAs trusts system CAs and api_ca_crt of profile, environment or
+// config file, like the API client does
+func apiRootCAs() *x509.CertPool {
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+
+	ca := os.Getenv("PRIVX_API_CA_CRT")
+	if profileActive {
+		ca = profileAttributes()["api_ca_crt"]
+	} else if config != "" {
+		ca = configCACert(config)
+	}
+	pool.AppendCertsFromPEM([]byte(ca))
+
+	return pool
+}
+
+// configCACert reads api_ca_crt of TOML config file, the value is
+// a basic or multi-line string
+func configCACert(file string) string {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+
+	text := string(stripBOM(data))
+	i := strings.Index(text, "api_ca_crt")
+	if i < 0 {
+		return ""
+	}
+	value := strings.TrimLeft(text[i+len("api_ca_crt"):], " \t=")
+
+	for _, quote := range []string{`"""`, "'''"} {
+		if strings.HasPrefix(value, quote) {
+			if end := strings.Index(value[3:], quote); end >= 0 {
+				return value[3 : 3+end]
+			}
+			return ""
+		}
+	}
+
+	line := strings.SplitN(value, "\n", 2)[0]
+	if ca, err := strconv.Unquote(strings.TrimSpace(line)); err == nil {
+		return ca
+	}
+	return ""
+}
+
+// stickyEndpointFile is per profile, profiles select nodes of their own
+// deployments
+func stickyEndpointFile() string {
+	dir, err := stateDir()
+	if err != nil {
+		return ""
+	}
+
+	name := "default"
+	if profileActive {
+		if conf, err := readConfig(); err == nil {
+			name = activeProfile(conf)
+		}
+	}
+
+	return filepath.Join(dir, "endpoints", unsafeFileChars.ReplaceAllString(name, "_"))
+}
+
+func readStickyEndpoint() string {
+	file := stickyEndpointFile()
+	if file == "" {
+		return ""
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+func writeStickyEndpoint(url string) {
+	if file := stickyEndpointFile(); file != "" {
+		if err := os.MkdirAll(filepath.Dir(file), 0700); err == nil {
+			writeFileAtomic(file, []byte(url+"\n"))
+		}
+	}
+}
@@ -0,0 +1,235 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/hoststore"
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/spf13/cobra"
+)
+
+type graphOptions struct {
+	roleID string
+	format string
+}
+
+// hostView is a minimal view of host role mappings and cloud origin
+type hostView struct {
+	ID                  string `json:"id"`
+	CommonName          string `json:"common_name"`
+	ExternalID          string `json:"external_id"`
+	InstanceID          string `json:"instance_id"`
+	CloudProvider       string `json:"cloud_provider"`
+	CloudProviderRegion string `json:"cloud_provider_region"`
+	Principals          []struct {
+		Principal string              `json:"principal"`
+		Roles     []rolestore.RoleRef `json:"roles"`
+	} `json:"principals"`
+}
+
+type graphEdge struct {
+	from, to, label string
+}
+
+func init() {
+	rootCmd.AddCommand(graphCmd())
+}
+
+//
+//
+func graphCmd() *cobra.Command {
+	options := graphOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Graph of role mappings",
+		Long: `Print Graphviz or Mermaid graph of role mappings to hosts, accounts and secrets.
+All roles are included unless --role is given. Role ID's are separated by commas when using multiple values, see example`,
+		Example: `
+	privx-cli graph [access flags] --role <ROLE-ID>,<ROLE-ID> --format dot | dot -Tsvg > roles.svg
+	privx-cli graph [access flags] --format mermaid
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return graph(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.roleID, "role", "", "role ID")
+	flags.StringVar(&options.format, "format", "dot", "graph format, dot or mermaid")
+
+	return cmd
+}
+
+func graph(options graphOptions) error {
+	if options.format != "dot" && options.format != "mermaid" {
+		return fmt.Errorf("graph format does not exist: %s", options.format)
+	}
+
+	roles, err := rolestore.New(curl()).Roles()
+	if err != nil {
+		return err
+	}
+
+	var refs []rolestore.RoleRef
+	if err := remarshal(roles, &refs); err != nil {
+		return err
+	}
+
+	selected := map[string]string{}
+	for _, ref := range refs {
+		selected[ref.ID] = ref.Name
+	}
+	if options.roleID != "" {
+		wanted := map[string]string{}
+		for _, id := range strings.Split(options.roleID, ",") {
+			name, ok := selected[id]
+			if !ok {
+				return fmt.Errorf("role does not exist: %s", id)
+			}
+			wanted[id] = name
+		}
+		selected = wanted
+	}
+
+	hosts, err := allHosts()
+	if err != nil {
+		return err
+	}
+
+	secrets, err := allSecrets()
+	if err != nil {
+		return err
+	}
+
+	labels := map[string]string{}
+	edges := []graphEdge{}
+	for id, name := range selected {
+		labels["role_"+id] = "role: " + name
+	}
+
+	for _, host := range hosts {
+		for _, principal := range host.Principals {
+			for _, role := range principal.Roles {
+				if _, ok := selected[role.ID]; !ok {
+					continue
+				}
+				account := "account_" + host.ID + "_" + principal.Principal
+				labels["host_"+host.ID] = "host: " + host.CommonName
+				labels[account] = "account: " + principal.Principal
+				edges = append(edges,
+					graphEdge{"role_" + role.ID, account, ""},
+					graphEdge{account, "host_" + host.ID, ""},
+				)
+			}
+		}
+	}
+
+	for _, secret := range secrets {
+		for _, ref := range secret.AllowRead {
+			if _, ok := selected[ref.ID]; ok {
+				labels["secret_"+secret.ID] = "secret: " + secret.ID
+				edges = append(edges, graphEdge{"role_" + ref.ID, "secret_" + secret.ID, "read"})
+			}
+		}
+		for _, ref := range secret.AllowWrite {
+			if _, ok := selected[ref.ID]; ok {
+				labels["secret_"+secret.ID] = "secret: " + secret.ID
+				edges = append(edges, graphEdge{"role_" + ref.ID, "secret_" + secret.ID, "write"})
+			}
+		}
+	}
+
+	return writeOutput([]byte(renderGraph(options.format, labels, edges)))
+}
+
+func renderGraph(format string, labels map[string]string, edges []graphEdge) string {
+	nodes := []string{}
+	for node := range labels {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	ids := map[string]string{}
+	for i, node := range nodes {
+		ids[node] = fmt.Sprintf("n%d", i)
+	}
+
+	var out strings.Builder
+	seen := map[graphEdge]bool{}
+
+	switch format {
+	case "mermaid":
+		out.WriteString("graph LR\n")
+		for _, node := range nodes {
+			fmt.Fprintf(&out, "  %s[\"%s\"]\n", ids[node], strings.ReplaceAll(labels[node], `"`, "'"))
+		}
+		for _, edge := range edges {
+			if seen[edge] {
+				continue
+			}
+			seen[edge] = true
+			if edge.label != "" {
+				fmt.Fprintf(&out, "  %s -->|%s| %s\n", ids[edge.from], edge.label, ids[edge.to])
+			} else {
+				fmt.Fprintf(&out, "  %s --> %s\n", ids[edge.from], ids[edge.to])
+			}
+		}
+	default:
+		out.WriteString("digraph privx {\n  rankdir=LR;\n")
+		for _, node := range nodes {
+			fmt.Fprintf(&out, "  %s [label=%q];\n", ids[node], labels[node])
+		}
+		for _, edge := range edges {
+			if seen[edge] {
+				continue
+			}
+			seen[edge] = true
+			if edge.label != "" {
+				fmt.Fprintf(&out, "  %s -> %s [label=%q];\n", ids[edge.from], ids[edge.to], edge.label)
+			} else {
+				fmt.Fprintf(&out, "  %s -> %s;\n", ids[edge.from], ids[edge.to])
+			}
+		}
+		out.WriteString("}\n")
+	}
+
+	return out.String()
+}
+
+// allHosts walks all pages of hosts
+func allHosts() ([]hostView, error) {
+	return hostsOf(hoststore.New(curl()))
+}
+
+// hostsOf walks all pages of hosts of the host store client
+func hostsOf(api *hoststore.HostStore) ([]hostView, error) {
+	limit := 100
+	hosts := []hostView{}
+
+	for offset := 0; ; offset += limit {
+		page, err := api.Hosts(offset, limit, "", "", "")
+		if err != nil {
+			return nil, err
+		}
+
+		var view []hostView
+		if err := remarshal(page, &view); err != nil {
+			return nil, err
+		}
+
+		hosts = append(hosts, view...)
+		if len(view) < limit {
+			return hosts, nil
+		}
+	}
+}
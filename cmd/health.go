@@ -0,0 +1,159 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/restapi"
+	"github.com/spf13/cobra"
+)
+
+// healthServices are PrivX microservices and their status endpoints
+var healthServices = []struct {
+	name string
+	path string
+}{
+	{"auth", "/auth/api/v1/status"},
+	{"role-store", "/role-store/api/v1/status"},
+	{"host-store", "/host-store/api/v1/status"},
+	{"vault", "/vault/api/v1/status"},
+	{"connection-manager", "/connection-manager/api/v1/status"},
+	{"monitor-service", "/monitor-service/api/v1/status"},
+	{"authorizer", "/authorizer/api/v1/status"},
+}
+
+type healthOptions struct {
+	services string
+}
+
+// serviceHealth is status of PrivX microservice
+type serviceHealth struct {
+	Service string `json:"service"`
+	Healthy bool   `json:"healthy"`
+	Status  string `json:"status"`
+	Version string `json:"version,omitempty"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+func init() {
+	rootCmd.AddCommand(healthCmd())
+}
+
+//
+//
+func healthCmd() *cobra.Command {
+	options := healthOptions{}
+
+	cmd := &cobra.Command{
+		Use:     "status",
+		Aliases: []string{"health"},
+		Short:   "Check health of PrivX microservices",
+		Long: `Check health of PrivX microservices from their status endpoints. The command
+exits with non-zero status if any of the services is unhealthy, monitoring
+scripts use it as a health check. Service names are separated by commas
+when using multiple values, see example`,
+		Example: `
+	privx-cli status [access flags]
+	privx-cli status [access flags] --output table
+	privx-cli status [access flags] --service auth,vault
+		`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return health(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.services, "service", "", "check only the services, e.g. auth,vault")
+
+	return cmd
+}
+
+func health(options healthOptions) error {
+	selected := map[string]bool{}
+	for _, name := range strings.Split(options.services, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			selected[name] = true
+		}
+	}
+
+	results := []serviceHealth{}
+	for _, service := range healthServices {
+		if len(selected) == 0 || selected[service.name] {
+			results = append(results, serviceHealth{Service: service.name})
+			delete(selected, service.name)
+		}
+	}
+	for name := range selected {
+		return fmt.Errorf("unknown service: %s", name)
+	}
+
+	// services are checked in parallel with the token of one authorization
+	authorizer := auth()
+	if _, err := authorizer.AccessToken(); err != nil {
+		return err
+	}
+	api := curlWith(authorizer)
+
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(result *serviceHealth) {
+			defer wg.Done()
+			checkHealth(api, result)
+		}(&results[i])
+	}
+	wg.Wait()
+
+	if err := stdout(results); err != nil {
+		return err
+	}
+
+	unhealthy := 0
+	for _, result := range results {
+		if !result.Healthy {
+			unhealthy++
+		}
+	}
+	if unhealthy > 0 {
+		return fmt.Errorf("%d of %d services are unhealthy", unhealthy, len(results))
+	}
+
+	return nil
+}
+
+func checkHealth(api restapi.Connector, result *serviceHealth) {
+	path := ""
+	for _, service := range healthServices {
+		if service.name == result.Service {
+			path = service.path
+		}
+	}
+
+	status := map[string]interface{}{}
+	started := time.Now()
+	_, err := api.URL(path).Get(&status)
+	result.Latency = time.Since(started).Round(time.Millisecond).String()
+
+	if err != nil {
+		result.Status, result.Error = "unavailable", err.Error()
+		return
+	}
+
+	result.Status = strings.ToLower(fmt.Sprint(firstOf(status, "status", "state")))
+	if result.Status == "<nil>" {
+		result.Status = "ok"
+	}
+	result.Healthy = result.Status == "ok" || result.Status == "healthy"
+	result.Version, _ = firstOf(status, "version", "app_version").(string)
+}
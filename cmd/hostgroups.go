@@ -0,0 +1,397 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/hoststore"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+type hostGroupOptions struct {
+	name   string
+	search string
+}
+
+// hostGroup is a named host search stored in ~/.privx-cli/host-groups.yaml,
+// members are resolved when the group is used
+type hostGroup struct {
+	Name   string `json:"name" yaml:"-"`
+	Search string `json:"search" yaml:"search"`
+}
+
+// hostGroupMember is a host selected by group search
+type hostGroupMember struct {
+	ID            string   `json:"id"`
+	CommonName    string   `json:"common_name"`
+	Addresses     []string `json:"addresses"`
+	Tags          []string `json:"tags"`
+	AccessGroupID string   `json:"access_group_id"`
+}
+
+func init() {
+	rootCmd.AddCommand(hostGroupsCmd())
+}
+
+//
+//
+func hostGroupsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "host-groups",
+		Short: "Manage named groups of hosts",
+		Long: `Manage named groups of hosts. Group is a saved search, members are resolved
+when the group is used, e.g. hosts tag add --group NAME. Search consists of space
+separated terms, all terms must match:
+
+  tag:VALUE           host has the tag
+  address:VALUE       host has the address
+  access-group:ID     host belongs to access group
+  name:PATTERN        common name matches shell pattern, e.g. web*
+  TEXT                common name contains the text`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(hostGroupCreateCmd())
+	cmd.AddCommand(hostGroupListCmd())
+	cmd.AddCommand(hostGroupShowCmd())
+	cmd.AddCommand(hostGroupDeleteCmd())
+
+	return cmd
+}
+
+//
+//
+func hostGroupCreateCmd() *cobra.Command {
+	options := hostGroupOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create or replace host group",
+		Long:  `Create or replace host group`,
+		Example: `
+	privx-cli host-groups create --name web-fleet --search "tag:web"
+	privx-cli host-groups create --name eu-db --search "tag:db name:*.eu.example.com"
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return hostGroupCreate(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.name, "name", "", "host group name")
+	flags.StringVar(&options.search, "search", "", "host search")
+	cmd.MarkFlagRequired("name")
+	cmd.MarkFlagRequired("search")
+
+	return cmd
+}
+
+func hostGroupCreate(options hostGroupOptions) error {
+	if _, err := parseHostSearch(options.search); err != nil {
+		return err
+	}
+
+	groups, err := readHostGroups()
+	if err != nil {
+		return err
+	}
+
+	groups[options.name] = hostGroup{Name: options.name, Search: options.search}
+
+	return writeHostGroups(groups)
+}
+
+//
+//
+func hostGroupListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List host groups",
+		Long:  `List host groups`,
+		Example: `
+	privx-cli host-groups list
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return hostGroupList()
+		},
+	}
+
+	return cmd
+}
+
+func hostGroupList() error {
+	groups, err := readHostGroups()
+	if err != nil {
+		return err
+	}
+
+	list := []hostGroup{}
+	for _, group := range groups {
+		list = append(list, group)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+
+	return stdout(list)
+}
+
+//
+//
+func hostGroupShowCmd() *cobra.Command {
+	options := hostGroupOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "List hosts of host group",
+		Long:  `List hosts currently matching the host group`,
+		Example: `
+	privx-cli host-groups show [access flags] --name web-fleet
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return hostGroupShow(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.name, "name", "", "host group name")
+	cmd.MarkFlagRequired("name")
+
+	return cmd
+}
+
+func hostGroupShow(options hostGroupOptions) error {
+	members, err := hostGroupMembers(options.name)
+	if err != nil {
+		return err
+	}
+
+	return stdout(members)
+}
+
+//
+//
+func hostGroupDeleteCmd() *cobra.Command {
+	options := hostGroupOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete host group",
+		Long:  `Delete host group, hosts are not changed`,
+		Example: `
+	privx-cli host-groups delete --name web-fleet
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return hostGroupDelete(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.name, "name", "", "host group name")
+	cmd.MarkFlagRequired("name")
+
+	return cmd
+}
+
+func hostGroupDelete(options hostGroupOptions) error {
+	groups, err := readHostGroups()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := groups[options.name]; !ok {
+		return fmt.Errorf("host group does not exist: %s", options.name)
+	}
+	delete(groups, options.name)
+
+	return writeHostGroups(groups)
+}
+
+type hostSearchTerm struct {
+	key, value string
+}
+
+func parseHostSearch(search string) ([]hostSearchTerm, error) {
+	terms := []hostSearchTerm{}
+
+	for _, field := range strings.Fields(search) {
+		term := hostSearchTerm{value: field}
+		if kv := strings.SplitN(field, ":", 2); len(kv) == 2 {
+			term = hostSearchTerm{key: kv[0], value: kv[1]}
+		}
+
+		switch term.key {
+		case "", "tag", "address", "access-group":
+		case "name":
+			if _, err := path.Match(term.value, ""); err != nil {
+				return nil, fmt.Errorf("invalid name pattern %s: %w", term.value, err)
+			}
+		default:
+			return nil, fmt.Errorf("unknown search term: %s", field)
+		}
+
+		terms = append(terms, term)
+	}
+
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("host search is empty")
+	}
+
+	return terms, nil
+}
+
+func (host hostGroupMember) match(terms []hostSearchTerm) bool {
+	for _, term := range terms {
+		var ok bool
+		switch term.key {
+		case "tag":
+			ok = anyOf(host.Tags, []string{term.value})
+		case "address":
+			ok = anyOf(host.Addresses, []string{term.value})
+		case "access-group":
+			ok = host.AccessGroupID == term.value
+		case "name":
+			ok, _ = path.Match(strings.ToLower(term.value), strings.ToLower(host.CommonName))
+		default:
+			ok = strings.Contains(strings.ToLower(host.CommonName), strings.ToLower(term.value))
+		}
+
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hostGroupMembers resolves hosts of the group
+func hostGroupMembers(name string) ([]hostGroupMember, error) {
+	groups, err := readHostGroups()
+	if err != nil {
+		return nil, err
+	}
+
+	group, ok := groups[name]
+	if !ok {
+		return nil, fmt.Errorf("host group does not exist: %s", name)
+	}
+
+	terms, err := parseHostSearch(group.Search)
+	if err != nil {
+		return nil, err
+	}
+
+	api := hoststore.New(curl())
+	limit := 100
+	members := []hostGroupMember{}
+
+	for offset := 0; ; offset += limit {
+		page, err := api.Hosts(offset, limit, "", "", "")
+		if err != nil {
+			return nil, err
+		}
+
+		var hosts []hostGroupMember
+		if err := remarshal(page, &hosts); err != nil {
+			return nil, err
+		}
+
+		for _, host := range hosts {
+			if host.match(terms) {
+				members = append(members, host)
+			}
+		}
+
+		if len(hosts) < limit {
+			return members, nil
+		}
+	}
+}
+
+// hostTargets returns host IDs given as comma separated list or by host group
+func hostTargets(ids, group string) ([]string, error) {
+	switch {
+	case ids != "" && group != "":
+		return nil, fmt.Errorf("either host ID or host group is required, not both")
+	case ids != "":
+		return strings.Split(ids, ","), nil
+	case group == "":
+		return nil, fmt.Errorf("either host ID or host group is required")
+	}
+
+	members, err := hostGroupMembers(group)
+	if err != nil {
+		return nil, err
+	}
+
+	targets := []string{}
+	for _, host := range members {
+		targets = append(targets, host.ID)
+	}
+
+	return targets, nil
+}
+
+func hostGroupsFile() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "host-groups.yaml"), nil
+}
+
+func readHostGroups() (map[string]hostGroup, error) {
+	groups := map[string]hostGroup{}
+
+	file, err := hostGroupsFile()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return groups, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, &groups); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	for name, group := range groups {
+		group.Name = name
+		groups[name] = group
+	}
+
+	return groups, nil
+}
+
+func writeHostGroups(groups map[string]hostGroup) error {
+	file, err := hostGroupsFile()
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(groups)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(file, data)
+}
@@ -23,10 +23,19 @@ type hostOptions struct {
 	filter         string
 	sortkey        string
 	sortdir        string
+	from           string
+	region         string
+	address        string
+	tag            string
+	accessGroupID  string
+	group          string
 	deployStatus   bool
 	disabledStatus bool
+	pruneMissing   bool
+	all            bool
 	limit          int
 	offset         int
+	parallel       int
 }
 
 func init() {
@@ -44,6 +53,9 @@ func hostListCmd() *cobra.Command {
 		Long:  `List and manage PrivX hosts`,
 		Example: `
 	privx-cli hosts [access flags] --offset <OFFSET> --sortkey <SORTKEY>
+	privx-cli hosts [access flags] --all -o table
+	privx-cli hosts [access flags] --address 10.0.0.1 --tag production
+	privx-cli hosts [access flags] --access-group <ACCESS-GROUP-ID>
 		`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -57,6 +69,10 @@ func hostListCmd() *cobra.Command {
 	flags.StringVar(&options.sortdir, "sortdir", "", "sort direction, ASC or DESC (default ASC)")
 	flags.StringVar(&options.sortkey, "sortkey", "", "sort object by name, updated, or created.")
 	flags.StringVar(&options.filter, "filter", "", "filter hosts, possible values: accessible or configured")
+	flags.BoolVar(&options.all, "all", false, "return all hosts, walking all pages")
+	flags.StringVar(&options.address, "address", "", "list hosts with address, comma separated values")
+	flags.StringVar(&options.tag, "tag", "", "list hosts with tag, comma separated values")
+	flags.StringVar(&options.accessGroupID, "access-group", "", "list hosts of access group ID")
 
 	cmd.AddCommand(hostSearchCmd())
 	cmd.AddCommand(hostCreateCmd())
@@ -68,15 +84,32 @@ func hostListCmd() *cobra.Command {
 	cmd.AddCommand(hostDisableCmd())
 	cmd.AddCommand(hostSettingListCmd())
 	cmd.AddCommand(hostsDeployCmd())
+	cmd.AddCommand(hostReconcileCmd())
+	cmd.AddCommand(hostTagCmd())
 
 	return cmd
 }
 
 func hostList(options hostOptions) error {
+	if options.address != "" || options.tag != "" || options.accessGroupID != "" {
+		return hostListFiltered(options)
+	}
+
 	api := hoststore.New(curl())
+	fetch := func(offset, limit int) (interface{}, error) {
+		return api.Hosts(offset, limit, options.sortkey,
+			strings.ToUpper(options.sortdir), options.filter)
+	}
 
-	hosts, err := api.Hosts(options.offset, options.limit, options.sortkey,
-		strings.ToUpper(options.sortdir), options.filter)
+	if options.all {
+		hosts, err := allPages(fetch)
+		if err != nil {
+			return err
+		}
+		return stdout(hosts)
+	}
+
+	hosts, err := fetch(options.offset, options.limit)
 	if err != nil {
 		return err
 	}
@@ -84,6 +117,73 @@ func hostList(options hostOptions) error {
 	return stdout(hosts)
 }
 
+// hostListFiltered filters all hosts by attributes, offset and limit
+// apply to the filtered list
+func hostListFiltered(options hostOptions) error {
+	api := hoststore.New(curl())
+	limit := 100
+	hosts := []hoststore.Host{}
+
+	for offset := 0; ; offset += limit {
+		page, err := api.Hosts(offset, limit, options.sortkey,
+			strings.ToUpper(options.sortdir), options.filter)
+		if err != nil {
+			return err
+		}
+
+		for _, host := range page {
+			var view struct {
+				Addresses     []string `json:"addresses"`
+				Tags          []string `json:"tags"`
+				AccessGroupID string   `json:"access_group_id"`
+			}
+			if err := remarshal(host, &view); err != nil {
+				return err
+			}
+
+			if options.address != "" && !anyOf(view.Addresses, strings.Split(options.address, ",")) {
+				continue
+			}
+			if options.tag != "" && !anyOf(view.Tags, strings.Split(options.tag, ",")) {
+				continue
+			}
+			if options.accessGroupID != "" && view.AccessGroupID != options.accessGroupID {
+				continue
+			}
+			hosts = append(hosts, host)
+		}
+
+		if len(page) < limit {
+			break
+		}
+	}
+
+	if options.all {
+		return stdout(hosts)
+	}
+	if options.offset >= len(hosts) {
+		return stdout([]hoststore.Host{})
+	}
+	hosts = hosts[options.offset:]
+	if options.limit < len(hosts) {
+		hosts = hosts[:options.limit]
+	}
+
+	return stdout(hosts)
+}
+
+// anyOf is true if any value is in the set
+func anyOf(set []string, values []string) bool {
+	for _, s := range set {
+		for _, v := range values {
+			if strings.EqualFold(s, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 //
 //
 func hostSearchCmd() *cobra.Command {
@@ -143,6 +243,8 @@ func hostCreateCmd() *cobra.Command {
 		Long:  `Create new host`,
 		Example: `
 	privx-cli hosts create [access flags] JSON-FILE
+	privx-cli hosts create [access flags] --dir hosts/
+	privx-cli hosts show [access flags] --id <HOST-ID> | jq '.[0]' | privx-cli hosts create [access flags] -
 		`,
 		Args:         cobra.ExactArgs(1),
 		SilenceUsage: true,
@@ -151,7 +253,7 @@ func hostCreateCmd() *cobra.Command {
 		},
 	}
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func hostCreate(cmd *cobra.Command, args []string) error {
@@ -222,6 +324,7 @@ func hostUpdateCmd() *cobra.Command {
 		Long:  `Update host`,
 		Example: `
 	privx-cli hosts update [access flags] JSON-FILE --id <HOST-ID>
+	privx-cli hosts update [access flags] --dir hosts/
 		`,
 		Args:         cobra.ExactArgs(1),
 		SilenceUsage: true,
@@ -234,7 +337,7 @@ func hostUpdateCmd() *cobra.Command {
 	flags.StringVar(&options.hostID, "id", "", "unique host ID")
 	cmd.MarkFlagRequired("id")
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func hostUpdate(options hostOptions, args []string) error {
@@ -246,6 +349,15 @@ func hostUpdate(options hostOptions, args []string) error {
 		return err
 	}
 
+	current, err := api.Host(options.hostID)
+	if err != nil {
+		return err
+	}
+
+	if err := confirmUpdate(current, &updateHost); err != nil {
+		return err
+	}
+
 	err = api.UpdateHost(options.hostID, &updateHost)
 	if err != nil {
 		return err
@@ -262,9 +374,12 @@ func hostDeleteCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "delete",
 		Short: "Delete host",
-		Long:  `Delete host. Host ID's are separated by commas when using multiple values, see example`,
+		Long: `Delete host. Host ID's are separated by commas when using multiple values, see example.
+Hosts are deleted concurrently, failures do not stop the deletion and outcome
+of each ID is printed.`,
 		Example: `
 	privx-cli hosts delete [access flags] --id <HOST-ID>,<HOST-ID>
+	privx-cli hosts delete [access flags] --group <HOST-GROUP> --parallel 8
 		`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -274,7 +389,8 @@ func hostDeleteCmd() *cobra.Command {
 
 	flags := cmd.Flags()
 	flags.StringVar(&options.hostID, "id", "", "unique host ID")
-	cmd.MarkFlagRequired("id")
+	flags.StringVar(&options.group, "group", "", "host group name")
+	flags.IntVar(&options.parallel, "parallel", 4, "number of concurrent deletions")
 
 	return cmd
 }
@@ -282,16 +398,12 @@ func hostDeleteCmd() *cobra.Command {
 func hostDelete(options hostOptions) error {
 	api := hoststore.New(curl())
 
-	for _, id := range strings.Split(options.hostID, ",") {
-		err := api.DeleteHost(id)
-		if err != nil {
-			return err
-		} else {
-			fmt.Println(id)
-		}
+	ids, err := hostTargets(options.hostID, options.group)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return bulkDelete(ids, options.parallel, api.DeleteHost)
 }
 
 //
@@ -448,23 +560,29 @@ func hostSettingList() error {
 //
 //
 func hostsDeployCmd() *cobra.Command {
+	wait := waiting{}
+
 	cmd := &cobra.Command{
 		Use:   "deploy",
 		Short: "Creates target hosts deployment config",
-		Long:  `Creates target hosts deployment config`,
+		Long: `Creates target hosts deployment config. PrivX serves the config of new
+deployment asynchronously, with --wait the download is retried until then.`,
 		Example: `
 	privx-cli hosts deploy [access flags] <NAME>
+	privx-cli hosts deploy [access flags] <NAME> --wait --timeout 1m
 		`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return hostDeploy(args)
+			return hostDeploy(args, wait)
 		},
 	}
 
+	wait.register(cmd, "PrivX serves the deployment config")
+
 	return cmd
 }
 
-func hostDeploy(args []string) error {
+func hostDeploy(args []string, wait waiting) error {
 	if len(args) < 1 {
 		return errors.New("requires name of deployment configuration as an argument")
 	}
@@ -489,7 +607,14 @@ func hostDeploy(args []string) error {
 	}
 
 	conf := apiConfig.New(curl)
-	file, err := conf.ConfigDeploy(cli)
+	var file []byte
+	err = wait.poll("deployment config "+name, func() (bool, error) {
+		file, err = conf.ConfigDeploy(cli)
+		if err != nil && wait.enabled && pending(err) {
+			return false, nil
+		}
+		return true, err
+	})
 	if err != nil {
 		return err
 	}
@@ -506,3 +631,229 @@ func findClientID(seq []userstore.TrustedClient, name string) string {
 	}
 	return ""
 }
+
+//
+//
+func hostReconcileCmd() *cobra.Command {
+	options := hostOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "reconcile",
+		Short: "Reconcile hosts with cloud provider inventory",
+		Long: `Compare PrivX hosts of cloud provider region against live instances. Instances
+missing from PrivX are created, hosts of vanished instances are disabled with
+--prune-missing. AWS credentials are read from AWS_ACCESS_KEY_ID,
+AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables.`,
+		Example: `
+	privx-cli hosts reconcile [access flags] --from aws --region eu-west-1 --dry-run
+	privx-cli hosts reconcile [access flags] --from aws --region eu-west-1 --prune-missing
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return hostReconcile(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.from, "from", "aws", "cloud provider, supported values: aws")
+	flags.StringVar(&options.region, "region", "", "cloud provider region")
+	flags.BoolVar(&options.pruneMissing, "prune-missing", false, "disable hosts whose instances no longer exist")
+	cmd.MarkFlagRequired("region")
+
+	return cmd
+}
+
+type hostReconcileResult struct {
+	Created   []ec2Instance `json:"created"`
+	Disabled  []string      `json:"disabled"`
+	Unchanged int           `json:"unchanged"`
+	DryRun    bool          `json:"dry_run"`
+}
+
+func hostReconcile(options hostOptions) error {
+	if strings.ToLower(options.from) != "aws" {
+		return fmt.Errorf("cloud provider is not supported: %s", options.from)
+	}
+
+	creds, err := awsEnvCredentials()
+	if err != nil {
+		return err
+	}
+
+	instances, err := describeInstances(creds, options.region)
+	if err != nil {
+		return err
+	}
+
+	api := hoststore.New(uncachedCurl())
+	hosts, err := hostsOf(api)
+	if err != nil {
+		return err
+	}
+
+	known := map[string]hostView{}
+	for _, host := range hosts {
+		if strings.ToUpper(host.CloudProvider) != "AWS" || host.CloudProviderRegion != options.region {
+			continue
+		}
+		id := host.InstanceID
+		if id == "" {
+			id = host.ExternalID
+		}
+		known[id] = host
+	}
+
+	result := hostReconcileResult{Created: []ec2Instance{}, Disabled: []string{}, DryRun: dryRun}
+
+	for _, instance := range instances {
+		if _, ok := known[instance.ID]; ok {
+			delete(known, instance.ID)
+			result.Unchanged++
+			continue
+		}
+
+		result.Created = append(result.Created, instance)
+		if dryRun {
+			continue
+		}
+
+		addresses := []string{}
+		for _, ip := range []string{instance.PrivateIP, instance.PublicIP} {
+			if ip != "" {
+				addresses = append(addresses, ip)
+			}
+		}
+
+		var host hoststore.Host
+		err := remarshal(map[string]interface{}{
+			"common_name":           instance.Name,
+			"external_id":           instance.ID,
+			"instance_id":           instance.ID,
+			"cloud_provider":        "AWS",
+			"cloud_provider_region": options.region,
+			"addresses":             addresses,
+		}, &host)
+		if err != nil {
+			return err
+		}
+
+		if _, err := api.CreateHost(host); err != nil {
+			return err
+		}
+	}
+
+	if options.pruneMissing {
+		for _, host := range known {
+			result.Disabled = append(result.Disabled, host.ID)
+			if dryRun {
+				continue
+			}
+			if err := api.UpdateDisabledHostStatus(host.ID, true); err != nil {
+				return err
+			}
+		}
+	}
+
+	return stdout(result)
+}
+
+//
+//
+func hostTagCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "tag",
+		Short:        "Add or remove host tags",
+		Long:         `Add or remove tags of hosts given by ID or host group`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(hostTagUpdateCmd("add", "Add tags to hosts"))
+	cmd.AddCommand(hostTagUpdateCmd("remove", "Remove tags from hosts"))
+
+	return cmd
+}
+
+func hostTagUpdateCmd(use, short string) *cobra.Command {
+	options := hostOptions{}
+
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Long:  short + `. Host ID's and tags are separated by commas when using multiple values, see example`,
+		Example: fmt.Sprintf(`
+	privx-cli hosts tag %s [access flags] --id <HOST-ID>,<HOST-ID> --tag <TAG>,<TAG>
+	privx-cli hosts tag %s [access flags] --group web-fleet --tag <TAG>
+		`, use, use),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return hostTagUpdate(options, use == "add")
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.hostID, "id", "", "unique host ID")
+	flags.StringVar(&options.group, "group", "", "host group name")
+	flags.StringVar(&options.tag, "tag", "", "host tag")
+	cmd.MarkFlagRequired("tag")
+
+	return cmd
+}
+
+func hostTagUpdate(options hostOptions, add bool) error {
+	api := hoststore.New(curl())
+
+	ids, err := hostTargets(options.hostID, options.group)
+	if err != nil {
+		return err
+	}
+	tags := strings.Split(options.tag, ",")
+
+	for _, id := range ids {
+		host, err := api.Host(id)
+		if err != nil {
+			return err
+		}
+
+		var definition map[string]interface{}
+		if err := remarshal(host, &definition); err != nil {
+			return err
+		}
+
+		var current []string
+		if err := remarshal(definition["tags"], &current); err != nil {
+			return err
+		}
+
+		updated := []string{}
+		for _, tag := range current {
+			if add || !anyOf(tags, []string{tag}) {
+				updated = append(updated, tag)
+			}
+		}
+		if add {
+			for _, tag := range tags {
+				if !anyOf(updated, []string{tag}) {
+					updated = append(updated, tag)
+				}
+			}
+		}
+
+		// host already has the requested tags
+		if len(updated) == len(current) {
+			continue
+		}
+		definition["tags"] = updated
+
+		var update hoststore.Host
+		if err := remarshal(definition, &update); err != nil {
+			return err
+		}
+
+		if err := api.UpdateHost(id, &update); err != nil {
+			return fmt.Errorf("host %s: %w", id, err)
+		}
+		fmt.Println(id)
+	}
+
+	return nil
+}
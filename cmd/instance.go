@@ -7,12 +7,28 @@
 package cmd
 
 import (
+	"fmt"
+	"sort"
+
 	"github.com/SSHcom/privx-sdk-go/api/monitor"
 	"github.com/spf13/cobra"
 )
 
+type instanceOptions struct {
+	hostName string
+}
+
+// instanceNode is a PrivX node and microservices running on it
+type instanceNode struct {
+	Hostname   string                   `json:"hostname"`
+	Versions   []string                 `json:"versions"`
+	Consistent bool                     `json:"consistent"`
+	Services   []map[string]interface{} `json:"services"`
+}
+
 func init() {
 	rootCmd.AddCommand(instanceShowCmd())
+	rootCmd.AddCommand(instanceNodesCmd())
 }
 
 //
@@ -76,3 +92,134 @@ func instanceTerminate() error {
 
 	return nil
 }
+
+//
+//
+func instanceNodesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "instances",
+		Short:        "List PrivX nodes and their microservices",
+		Long:         `List PrivX nodes and their microservices, versions and status`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(instanceNodeListCmd())
+	cmd.AddCommand(instanceNodeShowCmd())
+
+	return cmd
+}
+
+//
+//
+func instanceNodeListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List PrivX nodes",
+		Long:  `List PrivX nodes with running microservices. Node is consistent when all services run the same version.`,
+		Example: `
+	privx-cli instances list [access flags]
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return instanceNodeList()
+		},
+	}
+
+	return cmd
+}
+
+func instanceNodeList() error {
+	nodes, err := instanceNodes()
+	if err != nil {
+		return err
+	}
+
+	return stdout(nodes)
+}
+
+//
+//
+func instanceNodeShowCmd() *cobra.Command {
+	options := instanceOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Get PrivX node by hostname",
+		Long:  `Get PrivX node by hostname`,
+		Example: `
+	privx-cli instances show [access flags] --name <HOST-NAME>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return instanceNodeShow(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.hostName, "name", "", "host name")
+	cmd.MarkFlagRequired("name")
+
+	return cmd
+}
+
+func instanceNodeShow(options instanceOptions) error {
+	nodes, err := instanceNodes()
+	if err != nil {
+		return err
+	}
+
+	for _, node := range nodes {
+		if node.Hostname == options.hostName {
+			return stdout(node)
+		}
+	}
+
+	return fmt.Errorf("node does not exist: %s", options.hostName)
+}
+
+// instanceNodes groups component status by node
+func instanceNodes() ([]instanceNode, error) {
+	api := monitor.New(curl())
+
+	status, err := api.ComponentsStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	var components []map[string]interface{}
+	if err := remarshal(status, &components); err != nil {
+		return nil, err
+	}
+
+	index := map[string]*instanceNode{}
+	versions := map[string]map[string]bool{}
+	for _, component := range components {
+		host := fmt.Sprint(firstOf(component, "hostname", "host", "node"))
+		if index[host] == nil {
+			index[host] = &instanceNode{Hostname: host, Services: []map[string]interface{}{}}
+			versions[host] = map[string]bool{}
+		}
+
+		index[host].Services = append(index[host].Services, component)
+		if version, ok := component["version"].(string); ok && version != "" {
+			versions[host][version] = true
+		}
+	}
+
+	nodes := []instanceNode{}
+	for host, node := range index {
+		node.Versions = []string{}
+		for version := range versions[host] {
+			node.Versions = append(node.Versions, version)
+		}
+		sort.Strings(node.Versions)
+		node.Consistent = len(node.Versions) <= 1
+		nodes = append(nodes, *node)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Hostname < nodes[j].Hostname
+	})
+
+	return nodes, nil
+}
@@ -0,0 +1,169 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// leaseEndpoint is a dynamic credentials API of PrivX vault. Servers
+// without dynamic credentials respond 404 to it.
+const leaseEndpoint = "/vault/api/v1/leases"
+
+type leaseOptions struct {
+	leaseID string
+	target  string
+	role    string
+	ttl     time.Duration
+}
+
+//
+//
+func secretLeaseCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lease",
+		Short: "Manage short-lived dynamic credentials",
+		Long: `Manage short-lived dynamic credentials, e.g. database accounts. Requires PrivX
+with dynamic credential support.`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(secretLeaseCreateCmd())
+	cmd.AddCommand(secretLeaseRenewCmd())
+	cmd.AddCommand(secretLeaseRevokeCmd())
+
+	return cmd
+}
+
+//
+//
+func secretLeaseCreateCmd() *cobra.Command {
+	options := leaseOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Lease dynamic credentials for a target",
+		Long:  `Lease dynamic credentials for a target`,
+		Example: `
+	privx-cli secrets lease create [access flags] --target db01 --role readonly --ttl 1h
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return secretLeaseCreate(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.target, "target", "", "target name")
+	flags.StringVar(&options.role, "role", "", "target role of credentials")
+	flags.DurationVar(&options.ttl, "ttl", time.Hour, "lease time to live")
+	cmd.MarkFlagRequired("target")
+	cmd.MarkFlagRequired("role")
+
+	return cmd
+}
+
+func secretLeaseCreate(options leaseOptions) error {
+	lease := map[string]interface{}{}
+
+	_, err := curl().
+		URL(leaseEndpoint).
+		Post(map[string]interface{}{
+			"target": options.target,
+			"role":   options.role,
+			"ttl":    int(options.ttl.Seconds()),
+		}, &lease)
+	if err != nil {
+		return apiUnsupported(err, "dynamic credentials")
+	}
+
+	return stdout(lease)
+}
+
+//
+//
+func secretLeaseRenewCmd() *cobra.Command {
+	options := leaseOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "renew",
+		Short: "Extend lease of dynamic credentials",
+		Long:  `Extend lease of dynamic credentials`,
+		Example: `
+	privx-cli secrets lease renew [access flags] --id <LEASE-ID> --ttl 30m
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return secretLeaseRenew(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.leaseID, "id", "", "lease ID")
+	flags.DurationVar(&options.ttl, "ttl", time.Hour, "extension of the lease")
+	cmd.MarkFlagRequired("id")
+
+	return cmd
+}
+
+func secretLeaseRenew(options leaseOptions) error {
+	lease := map[string]interface{}{}
+
+	_, err := curl().
+		URL(leaseEndpoint+"/"+url.PathEscape(options.leaseID)+"/renew").
+		Post(map[string]interface{}{
+			"ttl": int(options.ttl.Seconds()),
+		}, &lease)
+	if err != nil {
+		return apiUnsupported(err, "dynamic credentials")
+	}
+
+	return stdout(lease)
+}
+
+//
+//
+func secretLeaseRevokeCmd() *cobra.Command {
+	options := leaseOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "revoke",
+		Short: "Revoke dynamic credentials",
+		Long:  `Revoke dynamic credentials. Lease ID's are separated by commas when using multiple values, see example`,
+		Example: `
+	privx-cli secrets lease revoke [access flags] --id <LEASE-ID>,<LEASE-ID>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return secretLeaseRevoke(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.leaseID, "id", "", "lease ID")
+	cmd.MarkFlagRequired("id")
+
+	return cmd
+}
+
+func secretLeaseRevoke(options leaseOptions) error {
+	for _, id := range strings.Split(options.leaseID, ",") {
+		_, err := curl().
+			URL(leaseEndpoint + "/" + url.PathEscape(id)).
+			Delete()
+		if err != nil {
+			return apiUnsupported(err, "dynamic credentials")
+		}
+		info("%s", id)
+	}
+
+	return nil
+}
@@ -7,12 +7,18 @@
 package cmd
 
 import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+
 	"github.com/SSHcom/privx-sdk-go/api/licensemanager"
 	"github.com/spf13/cobra"
 )
 
 type licenseOptions struct {
 	licenseKey string
+	keyFile    string
 	optin      bool
 }
 
@@ -36,10 +42,12 @@ func licenseListCmd() *cobra.Command {
 		},
 	}
 
+	cmd.AddCommand(licenseShowCmd())
 	cmd.AddCommand(licenseSetCmd())
 	cmd.AddCommand(licenseRefreshCmd())
 	cmd.AddCommand(licenseStatisticsSetCmd())
 	cmd.AddCommand(licenseUnsetCmd())
+	cmd.AddCommand(licenseJSURLCmd())
 
 	return cmd
 }
@@ -55,6 +63,25 @@ func licenseList() error {
 	return stdout(license)
 }
 
+//
+//
+func licenseShowCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Get license",
+		Long:  `Get PrivX license information`,
+		Example: `
+	privx-cli license show [access flags]
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return licenseList()
+		},
+	}
+
+	return cmd
+}
+
 //
 //
 func licenseSetCmd() *cobra.Command {
@@ -63,9 +90,12 @@ func licenseSetCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "set",
 		Short: "Set new license",
-		Long:  `Set new license`,
+		Long: `Set new license. The key is given by --key, --key-file or PRIVX_LICENSE_KEY
+environment variable, --key-file - reads the key from stdin.`,
 		Example: `
 	privx-cli license set [access flags] --key <LICENSE-KEY>
+	privx-cli license set [access flags] --key-file license.txt
+	vault read -field=key secret/privx | privx-cli license set [access flags] --key-file -
 		`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -74,7 +104,8 @@ func licenseSetCmd() *cobra.Command {
 	}
 
 	flags := cmd.Flags()
-	flags.StringVar(&options.licenseKey, "key", "", "PrivX license key")
+	flags.StringVar(&options.licenseKey, "key", os.Getenv("PRIVX_LICENSE_KEY"), "PrivX license key")
+	flags.StringVar(&options.keyFile, "key-file", "", "file containing PrivX license key, - for stdin")
 
 	return cmd
 }
@@ -82,6 +113,28 @@ func licenseSetCmd() *cobra.Command {
 func licenseSet(options licenseOptions) error {
 	api := licensemanager.New(curl())
 
+	if options.keyFile != "" {
+		file := os.Stdin
+		if options.keyFile != "-" {
+			var err error
+			if file, err = os.Open(options.keyFile); err != nil {
+				return err
+			}
+			defer file.Close()
+		}
+
+		data, err := ioutil.ReadAll(file)
+		if err != nil {
+			return err
+		}
+		options.licenseKey = string(data)
+	}
+
+	options.licenseKey = strings.TrimSpace(options.licenseKey)
+	if options.licenseKey == "" {
+		return errors.New("license key is required")
+	}
+
 	err := api.SetLicense(options.licenseKey)
 	if err != nil {
 		return err
@@ -157,11 +210,12 @@ func licenseStatisticsSet(options licenseOptions) error {
 //
 func licenseUnsetCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:   "unset",
-		Short: "Unset license",
-		Long:  `Unset PrivX license`,
+		Use:     "deactivate",
+		Aliases: []string{"unset"},
+		Short:   "Deactivate license",
+		Long:    `Deactivate PrivX license, e.g. before moving the license to another installation`,
 		Example: `
-	privx-cli license unset [access flags]
+	privx-cli license deactivate [access flags]
 		`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -179,3 +233,35 @@ func licenseUnset() error {
 
 	return err
 }
+
+//
+//
+func licenseJSURLCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "js-url",
+		Short: "Get license JS URL",
+		Long:  `Get URL of the license script used by PrivX UI for license activation`,
+		Example: `
+	privx-cli license js-url [access flags]
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return licenseJSURL()
+		},
+	}
+
+	return cmd
+}
+
+func licenseJSURL() error {
+	var result interface{}
+
+	_, err := curl().
+		URL("/license-manager/api/v1/license/js-url").
+		Get(&result)
+	if err != nil {
+		return apiUnsupported(err, "license JS URL")
+	}
+
+	return stdout(result)
+}
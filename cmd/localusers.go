@@ -7,7 +7,6 @@
 package cmd
 
 import (
-	"fmt"
 	"strings"
 
 	"github.com/SSHcom/privx-sdk-go/api/userstore"
@@ -20,6 +19,7 @@ type localUserOptions struct {
 	password string
 	offset   int
 	limit    int
+	parallel int
 }
 
 func init() {
@@ -129,7 +129,7 @@ func localUserCreateCmd() *cobra.Command {
 		},
 	}
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func localUserCreate(args []string) error {
@@ -172,7 +172,7 @@ func localUserUpdateCmd() *cobra.Command {
 	flags.StringVar(&options.userID, "id", "", "unique user ID")
 	cmd.MarkFlagRequired("id")
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func localUserUpdate(options localUserOptions, args []string) error {
@@ -200,9 +200,12 @@ func localUserDeleteCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "delete",
 		Short: "Delete local user",
-		Long:  `Delete local user. User ID's are separated by commas when using multiple values, see example`,
+		Long: `Delete local user. User ID's are separated by commas when using multiple values, see example.
+Users are deleted concurrently, failures do not stop the deletion and outcome
+of each ID is printed.`,
 		Example: `
 	privx-cli local-users delete [access flags] --id <USER-ID>,<USER-ID>
+	privx-cli local-users delete [access flags] --id <USER-ID>,<USER-ID> --parallel 8
 		`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -212,6 +215,7 @@ func localUserDeleteCmd() *cobra.Command {
 
 	flags := cmd.Flags()
 	flags.StringVar(&options.userID, "id", "", "unique user ID")
+	flags.IntVar(&options.parallel, "parallel", 4, "number of concurrent deletions")
 	cmd.MarkFlagRequired("id")
 
 	return cmd
@@ -220,16 +224,7 @@ func localUserDeleteCmd() *cobra.Command {
 func localUserDelete(options localUserOptions) error {
 	api := userstore.New(curl())
 
-	for _, id := range strings.Split(options.userID, ",") {
-		err := api.DeleteLocalUser(id)
-		if err != nil {
-			return err
-		} else {
-			fmt.Println(id)
-		}
-	}
-
-	return nil
+	return bulkDelete(strings.Split(options.userID, ","), options.parallel, api.DeleteLocalUser)
 }
 
 //
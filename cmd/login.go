@@ -7,20 +7,35 @@
 package cmd
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/SSHcom/privx-sdk-go/restapi"
 	"github.com/spf13/cobra"
 )
 
 func init() {
 	rootCmd.AddCommand(loginCmd)
+	rootCmd.AddCommand(logoutCmd)
 }
 
 var loginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "login either user or client to PrivX",
-	Long:  `login commands fetches access token for consequent calls of the client`,
+	Long: `login commands fetches access token for consequent calls of the client.
+The token is cached in ~/.privx-cli/tokens until logout, in Credential Manager on
+Windows, following commands use it and re-authenticate silently when it expires.`,
 	Example: `
+privx-cli login [access flags]
 export SESSION=$(privx-cli login [access flags])
 privx-cli -s $SESSION ...
 	`,
@@ -28,12 +43,168 @@ privx-cli -s $SESSION ...
 	RunE:         login,
 }
 
+var logoutCmd = &cobra.Command{
+	Use:   "logout",
+	Short: "Remove cached access token",
+	Long:  `Remove cached access token of the PrivX instance and principal`,
+	Example: `
+privx-cli logout [access flags]
+	`,
+	SilenceUsage: true,
+	RunE:         logout,
+}
+
 func login(cmd *cobra.Command, args []string) error {
 	token, err := auth().AccessToken()
 	if err != nil {
 		return err
 	}
 
+	if err := writeCachedToken(token); err != nil {
+		return err
+	}
+
 	_, err = os.Stdout.Write([]byte(token))
 	return err
 }
+
+func logout(cmd *cobra.Command, args []string) error {
+	return removeCachedToken()
+}
+
+// tokenCache serves access token from disk while it is valid. Tokens
+// are cached only after explicit login.
+type tokenCache struct {
+	restapi.Authorizer
+}
+
+func (c tokenCache) AccessToken() (string, error) {
+	token, err := readCachedToken()
+	if err != nil {
+		return c.Authorizer.AccessToken()
+	}
+
+	if expiry, err := tokenExpiry(token); err == nil && time.Until(expiry) > time.Minute {
+		return token, nil
+	}
+
+	token, err = c.Authorizer.AccessToken()
+	if err != nil {
+		return "", err
+	}
+
+	return token, writeCachedToken(token)
+}
+
+// tokenFile is unique per instance, principal and config. On Windows the
+// name identifies the token in Credential Manager.
+func tokenFile() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256([]byte(strings.Join(baseURLs(), ",") + "\x00" + endpoint() +
+		"\x00" + access + "\x00" + config + "\x00" + profile))
+
+	return filepath.Join(dir, "tokens", hex.EncodeToString(hash[:])), nil
+}
+
+func readTokenFile() (string, error) {
+	file, err := tokenFile()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+func writeTokenFile(token string) error {
+	file, err := tokenFile()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(file, []byte(token))
+}
+
+func removeTokenFile() error {
+	file, err := tokenFile()
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func tokenExpiry(token string) (time.Time, error) {
+	claims, err := decodeClaims(token)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return time.Time{}, fmt.Errorf("access token has no expiry")
+	}
+
+	return time.Unix(int64(exp), 0), nil
+}
+
+// tokenClaims decodes claims of the access token, signature is not
+// verified as the token is only used to describe the principal.
+func tokenClaims() (map[string]interface{}, error) {
+	token, err := auth().AccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeClaims(token)
+}
+
+func decodeClaims(token string) (map[string]interface{}, error) {
+	parts := strings.Split(strings.TrimPrefix(token, "Bearer "), ".")
+	if len(parts) != 3 {
+		return nil, errors.New("access token is not JWT")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, err
+	}
+
+	claims := map[string]interface{}{}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// currentUserID returns ID of authenticated principal
+func currentUserID() (string, error) {
+	claims, err := tokenClaims()
+	if err != nil {
+		return "", err
+	}
+
+	sub, ok := claims["sub"].(string)
+	if !ok || sub == "" {
+		return "", errors.New("access token does not identify the user")
+	}
+
+	return sub, nil
+}
@@ -0,0 +1,186 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// logsPath is REST API of component logs of the monitor service, the SDK
+// has no client for it yet
+const logsPath = "/monitor-service/api/v1/logs"
+
+type logsOptions struct {
+	service  string
+	hostName string
+	level    string
+	since    string
+	format   string
+	follow   bool
+	interval time.Duration
+}
+
+// logQuery selects component log entries
+type logQuery struct {
+	Service  string `json:"service,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+	Level    string `json:"level,omitempty"`
+	Since    string `json:"since,omitempty"`
+	Offset   int    `json:"offset"`
+	Limit    int    `json:"limit"`
+}
+
+func init() {
+	rootCmd.AddCommand(logsCmd())
+}
+
+//
+//
+func logsCmd() *cobra.Command {
+	options := logsOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Fetch logs of PrivX microservices",
+		Long: `Fetch logs of PrivX microservices collected by the monitor service, for
+troubleshooting without SSH access to PrivX hosts. Log lines are printed as
+text or, with --format ndjson, as JSON lines. With --follow new lines are
+polled and streamed until interrupted.`,
+		Example: `
+	privx-cli logs [access flags] --service role-store --since 1h
+	privx-cli logs [access flags] --service auth --level error --follow
+	privx-cli logs [access flags] --since 30m --format ndjson | jq .message
+		`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return logs(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.service, "service", "", "fetch logs of service, e.g. role-store, all services if omitted")
+	flags.StringVar(&options.hostName, "host", "", "fetch logs of PrivX node by hostname")
+	flags.StringVar(&options.level, "level", "", "minimum log level, e.g. info, warning or error")
+	flags.StringVar(&options.since, "since", "1h", "log lines after timestamp (RFC3339) or duration ago (e.g. 1h, 7d)")
+	flags.StringVar(&options.format, "format", "text", "format of log lines: text or ndjson")
+	flags.BoolVarP(&options.follow, "follow", "f", false, "poll new log lines and stream them")
+	flags.DurationVar(&options.interval, "interval", 5*time.Second, "polling interval of --follow")
+
+	return cmd
+}
+
+func logs(options logsOptions) error {
+	if options.format != "text" && options.format != "ndjson" {
+		return fmt.Errorf("invalid --format: %s", options.format)
+	}
+	if options.follow && options.interval <= 0 {
+		return fmt.Errorf("invalid --interval: %s", options.interval)
+	}
+
+	since, err := parseTimeFlag(options.since)
+	if err != nil {
+		return err
+	}
+
+	query := logQuery{
+		Service:  options.service,
+		Hostname: options.hostName,
+		Level:    options.level,
+	}
+
+	// lines of the last seen timestamp are remembered to avoid duplicates
+	seen := map[string]bool{}
+
+	for {
+		query.Since = since.UTC().Format(time.RFC3339Nano)
+		lines, err := fetchLogs(query)
+		if interrupted() != nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, line := range lines {
+			key := fmt.Sprint(line["timestamp"], line["hostname"], line["service"], line["message"])
+			if seen[key] {
+				continue
+			}
+
+			if err := writeLogLine(options.format, line); err != nil {
+				return err
+			}
+
+			at, err := time.Parse(time.RFC3339Nano, fmt.Sprint(line["timestamp"]))
+			if err != nil {
+				continue
+			}
+			if at.After(since) {
+				since = at
+				seen = map[string]bool{}
+			}
+			seen[key] = true
+		}
+
+		if !options.follow {
+			return nil
+		}
+
+		select {
+		case <-runContext.Done():
+			return nil
+		case <-time.After(options.interval):
+		}
+	}
+}
+
+// fetchLogs fetches all pages of log lines matching the query
+func fetchLogs(query logQuery) ([]map[string]interface{}, error) {
+	lines := []map[string]interface{}{}
+	query.Limit = 1000
+
+	for {
+		var page struct {
+			Items []map[string]interface{} `json:"items"`
+		}
+
+		_, err := curl().URL(logsPath).Query(query).Get(&page)
+		if err != nil {
+			return nil, apiUnsupported(err, "component logs")
+		}
+
+		lines = append(lines, page.Items...)
+		if len(page.Items) < query.Limit {
+			return lines, nil
+		}
+		query.Offset += query.Limit
+	}
+}
+
+func writeLogLine(format string, line map[string]interface{}) error {
+	if format == "ndjson" {
+		return json.NewEncoder(os.Stdout).Encode(line)
+	}
+
+	fields := []string{}
+	for _, key := range []string{"timestamp", "hostname", "service", "level"} {
+		if value, ok := line[key]; ok && value != "" {
+			fields = append(fields, fmt.Sprint(value))
+		}
+	}
+	fields = append(fields, strings.TrimRight(fmt.Sprint(firstOf(line, "message", "msg")), "\n"))
+
+	_, err := fmt.Fprintln(os.Stdout, strings.Join(fields, " "))
+	return err
+}
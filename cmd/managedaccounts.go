@@ -0,0 +1,140 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/hoststore"
+	"github.com/spf13/cobra"
+)
+
+type managedAccountOptions struct {
+	hostID  string
+	account string
+}
+
+type managedAccountVerification struct {
+	HostID   string `json:"host_id"`
+	Account  string `json:"account"`
+	Verified bool   `json:"verified"`
+	Error    string `json:"error,omitempty"`
+}
+
+func init() {
+	rootCmd.AddCommand(managedAccountsCmd())
+}
+
+//
+//
+func managedAccountsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "managed-accounts",
+		Short:        "Manage host accounts with vaulted credentials",
+		Long:         `Manage host accounts with vaulted credentials`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(managedAccountVerifyCmd())
+
+	return cmd
+}
+
+//
+//
+func managedAccountVerifyCmd() *cobra.Command {
+	options := managedAccountOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Verify stored credentials against the target host",
+		Long: `Ask PrivX to validate the stored credential of host account against the target host.
+All accounts with stored password are verified unless --account is given. Account names are
+separated by commas when using multiple values. Verification requires support from PrivX server.`,
+		Example: `
+	privx-cli managed-accounts verify [access flags] --id <HOST-ID>
+	privx-cli managed-accounts verify [access flags] --id <HOST-ID> --account <ACCOUNT>,<ACCOUNT>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return managedAccountVerify(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.hostID, "id", "", "host ID")
+	flags.StringVar(&options.account, "account", "", "host account name")
+	cmd.MarkFlagRequired("id")
+
+	return cmd
+}
+
+func managedAccountVerify(options managedAccountOptions) error {
+	curl := curl()
+	api := hoststore.New(curl)
+
+	host, err := api.Host(options.hostID)
+	if err != nil {
+		return err
+	}
+
+	var view struct {
+		Principals []struct {
+			Principal  string `json:"principal"`
+			Passphrase string `json:"passphrase"`
+		} `json:"principals"`
+	}
+	if err := remarshal(host, &view); err != nil {
+		return err
+	}
+
+	accounts := []string{}
+	if options.account != "" {
+		accounts = strings.Split(options.account, ",")
+	} else {
+		for _, principal := range view.Principals {
+			if principal.Passphrase != "" {
+				accounts = append(accounts, principal.Principal)
+			}
+		}
+	}
+
+	if len(accounts) == 0 {
+		return fmt.Errorf("host %s has no managed accounts, nothing to verify", options.hostID)
+	}
+
+	failed := 0
+	results := []managedAccountVerification{}
+	for _, account := range accounts {
+		result := managedAccountVerification{HostID: options.hostID, Account: account}
+
+		_, err := curl.
+			URL("/host-store/api/v1/hosts/" + url.PathEscape(options.hostID) +
+				"/principals/" + url.PathEscape(account) + "/verify").
+			Post(nil)
+		if err != nil {
+			result.Error = apiUnsupported(err, "credential verification").Error()
+			failed++
+		} else {
+			result.Verified = true
+		}
+
+		results = append(results, result)
+	}
+
+	if err := stdout(results); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("credential verification failed for %d account(s)", failed)
+	}
+
+	return nil
+}
@@ -0,0 +1,330 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// networkTargetsPath is REST API of network access manager, the SDK has
+// no client for it yet
+const networkTargetsPath = "/network-access-manager/api/v1/nwtargets"
+
+type networkTargetOptions struct {
+	targetID string
+	name     string
+	sortkey  string
+	sortdir  string
+	offset   int
+	limit    int
+	all      bool
+	disabled bool
+}
+
+// networkTargetQuery is query string of network target list
+type networkTargetQuery struct {
+	Offset  int    `json:"offset"`
+	Limit   int    `json:"limit"`
+	SortKey string `json:"sortkey,omitempty"`
+	SortDir string `json:"sortdir,omitempty"`
+	Name    string `json:"name,omitempty"`
+}
+
+// networkTargetPage is response of network target list
+type networkTargetPage struct {
+	Count int                      `json:"count"`
+	Items []map[string]interface{} `json:"items"`
+}
+
+func init() {
+	rootCmd.AddCommand(networkTargetListCmd())
+}
+
+//
+//
+func networkTargetListCmd() *cobra.Command {
+	options := networkTargetOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "network-targets",
+		Short: "List and manage network targets of network access manager",
+		Long:  `List and manage network targets of network access manager`,
+		Example: `
+	privx-cli network-targets [access flags] --offset <OFFSET> --limit <LIMIT>
+	privx-cli network-targets [access flags] --name <NAME> --all
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return networkTargetList(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.IntVar(&options.offset, "offset", 0, "where to start fetching the items")
+	flags.IntVar(&options.limit, "limit", 50, "number of items to return")
+	flags.StringVar(&options.sortkey, "sortkey", "", "sort object by name, updated, or created")
+	flags.StringVar(&options.sortdir, "sortdir", "", "sort direction, ASC or DESC (default ASC)")
+	flags.StringVar(&options.name, "name", "", "list network targets with name")
+	flags.BoolVar(&options.all, "all", false, "return all network targets, walking all pages")
+
+	cmd.AddCommand(networkTargetShowCmd())
+	cmd.AddCommand(networkTargetCreateCmd())
+	cmd.AddCommand(networkTargetUpdateCmd())
+	cmd.AddCommand(networkTargetDeleteCmd())
+	cmd.AddCommand(networkTargetDisableCmd())
+
+	return cmd
+}
+
+func networkTargetList(options networkTargetOptions) error {
+	fetch := func(offset, limit int) (interface{}, error) {
+		var page networkTargetPage
+		_, err := curl().URL(networkTargetsPath).Query(networkTargetQuery{
+			Offset:  offset,
+			Limit:   limit,
+			SortKey: options.sortkey,
+			SortDir: strings.ToUpper(options.sortdir),
+			Name:    options.name,
+		}).Get(&page)
+		if err != nil {
+			return nil, apiUnsupported(err, "network access manager")
+		}
+		return page.Items, nil
+	}
+
+	if options.all {
+		targets, err := allPages(fetch)
+		if err != nil {
+			return err
+		}
+		return stdout(targets)
+	}
+
+	targets, err := fetch(options.offset, options.limit)
+	if err != nil {
+		return err
+	}
+
+	return stdout(targets)
+}
+
+//
+//
+func networkTargetShowCmd() *cobra.Command {
+	options := networkTargetOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Get network target by ID",
+		Long:  `Get network target by ID. Network target ID's are separated by commas when using multiple values, see example`,
+		Example: `
+	privx-cli network-targets show [access flags] --id <TARGET-ID>,<TARGET-ID>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return networkTargetShow(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.targetID, "id", "", "network target ID")
+	cmd.MarkFlagRequired("id")
+
+	return cmd
+}
+
+func networkTargetShow(options networkTargetOptions) error {
+	targets := []map[string]interface{}{}
+
+	for _, id := range strings.Split(options.targetID, ",") {
+		target, err := networkTarget(id)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, target)
+	}
+
+	return stdout(targets)
+}
+
+func networkTarget(id string) (map[string]interface{}, error) {
+	target := map[string]interface{}{}
+
+	_, err := curl().URL(networkTargetsPath + "/" + url.PathEscape(id)).Get(&target)
+	if err != nil {
+		return nil, apiUnsupported(err, "network access manager")
+	}
+
+	return target, nil
+}
+
+//
+//
+func networkTargetCreateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create new network target",
+		Long:  `Create new network target`,
+		Example: `
+	privx-cli network-targets create [access flags] JSON-FILE
+		`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return networkTargetCreate(args)
+		},
+	}
+
+	return bulkCmd(cmd)
+}
+
+func networkTargetCreate(args []string) error {
+	var target map[string]interface{}
+
+	err := decodeJSON(args[0], &target)
+	if err != nil {
+		return err
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	_, err = curl().URL(networkTargetsPath).Post(target, &created)
+	if err != nil {
+		return apiUnsupported(err, "network access manager")
+	}
+
+	return stdout(created.ID)
+}
+
+//
+//
+func networkTargetUpdateCmd() *cobra.Command {
+	options := networkTargetOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "Update network target",
+		Long:  `Update network target`,
+		Example: `
+	privx-cli network-targets update [access flags] --id <TARGET-ID> JSON-FILE
+		`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return networkTargetUpdate(options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.targetID, "id", "", "network target ID")
+	cmd.MarkFlagRequired("id")
+
+	return bulkCmd(cmd)
+}
+
+func networkTargetUpdate(options networkTargetOptions, args []string) error {
+	var target map[string]interface{}
+
+	err := decodeJSON(args[0], &target)
+	if err != nil {
+		return err
+	}
+
+	current, err := networkTarget(options.targetID)
+	if err != nil {
+		return err
+	}
+
+	if err := confirmUpdate(current, target); err != nil {
+		return err
+	}
+
+	_, err = curl().URL(networkTargetsPath + "/" + url.PathEscape(options.targetID)).Put(target)
+	return err
+}
+
+//
+//
+func networkTargetDeleteCmd() *cobra.Command {
+	options := networkTargetOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete network target",
+		Long:  `Delete network target. Network target ID's are separated by commas when using multiple values, see example`,
+		Example: `
+	privx-cli network-targets delete [access flags] --id <TARGET-ID>,<TARGET-ID>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return networkTargetDelete(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.targetID, "id", "", "network target ID")
+	cmd.MarkFlagRequired("id")
+
+	return cmd
+}
+
+func networkTargetDelete(options networkTargetOptions) error {
+	for _, id := range strings.Split(options.targetID, ",") {
+		_, err := curl().URL(networkTargetsPath + "/" + url.PathEscape(id)).Delete()
+		if err != nil {
+			return apiUnsupported(err, "network access manager")
+		}
+		info("%s", id)
+	}
+
+	return nil
+}
+
+//
+//
+func networkTargetDisableCmd() *cobra.Command {
+	options := networkTargetOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "disable",
+		Short: "Enable/disable network target",
+		Long:  `Enable(false)/disable(true) network target. Network target ID's are separated by commas when using multiple values, see example`,
+		Example: `
+	privx-cli network-targets disable [access flags] --id <TARGET-ID>,<TARGET-ID>
+	privx-cli network-targets disable [access flags] --id <TARGET-ID> --status=false
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return networkTargetDisable(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.targetID, "id", "", "network target ID")
+	flags.BoolVar(&options.disabled, "status", true, "network target disabled status")
+	cmd.MarkFlagRequired("id")
+
+	return cmd
+}
+
+func networkTargetDisable(options networkTargetOptions) error {
+	status := map[string]bool{"disabled": options.disabled}
+
+	for _, id := range strings.Split(options.targetID, ",") {
+		_, err := curl().URL(networkTargetsPath + "/" + url.PathEscape(id) + "/disabled").Put(status)
+		if err != nil {
+			return apiUnsupported(err, "network access manager")
+		}
+		info("%s", id)
+	}
+
+	return nil
+}
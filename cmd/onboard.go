@@ -0,0 +1,293 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/hoststore"
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/SSHcom/privx-sdk-go/api/workflow"
+	"github.com/spf13/cobra"
+)
+
+type onboardOptions struct {
+	name          string
+	sourceID      string
+	group         string
+	hostsTag      string
+	principal     string
+	approverRole  string
+	requesterRole string
+}
+
+// onboardResult are resources created for the team
+type onboardResult struct {
+	RoleID     string   `json:"role_id"`
+	Hosts      []string `json:"hosts"`
+	WorkflowID string   `json:"workflow_id,omitempty"`
+}
+
+// onboarding applies steps and undoes the applied ones on failure
+type onboarding struct {
+	undo []func() error
+}
+
+func init() {
+	rootCmd.AddCommand(onboardTeamCmd())
+}
+
+//
+//
+func onboardTeamCmd() *cobra.Command {
+	options := onboardOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "onboard-team",
+		Short: "Create role, directory mapping, host access and workflow of a team",
+		Long: `Onboard team in one step. The role of the team is created and granted to
+members of the directory group, the role is mapped to the principal of hosts with
+the tag and an approval workflow is created for requesting the role, approved by
+the approver role. Changes made before a failing step are rolled back.`,
+		Example: `
+	privx-cli onboard-team [access flags] --name payments --source <SOURCE-ID> \
+		--ad-group CN=payments,OU=groups,DC=example,DC=com \
+		--hosts-tag payments --principal payments --approver-role leads
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return onboardTeam(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.name, "name", "", "team name, used as the role name")
+	flags.StringVar(&options.sourceID, "source", "", "directory source ID of the group")
+	flags.StringVar(&options.group, "ad-group", "", "directory group of team members")
+	flags.StringVar(&options.hostsTag, "hosts-tag", "", "tag of hosts the team accesses")
+	flags.StringVar(&options.principal, "principal", "", "host account of the team, defaults to the team name")
+	flags.StringVar(&options.approverRole, "approver-role", "", "name or ID of role approving requests of the role")
+	flags.StringVar(&options.requesterRole, "requester-role", "", "name or ID of role allowed to request the role")
+	cmd.MarkFlagRequired("name")
+
+	return cmd
+}
+
+func onboardTeam(options onboardOptions) error {
+	if options.group != "" && options.sourceID == "" {
+		return errors.New("--ad-group requires --source")
+	}
+	if options.principal == "" {
+		options.principal = options.name
+	}
+
+	roles, err := rolestore.New(curl()).Roles()
+	if err != nil {
+		return err
+	}
+	for _, role := range roles {
+		if role.Name == options.name {
+			return fmt.Errorf("role already exists: %s", options.name)
+		}
+	}
+
+	var approver, requester *rolestore.RoleRef
+	if options.approverRole != "" {
+		if approver, err = findRoleRef(roles, options.approverRole); err != nil {
+			return err
+		}
+	}
+	if options.requesterRole != "" {
+		if requester, err = findRoleRef(roles, options.requesterRole); err != nil {
+			return err
+		}
+	}
+
+	tx := onboarding{}
+	result, err := tx.run(options, approver, requester)
+	if err != nil {
+		return tx.rollback(err)
+	}
+
+	return stdout(result)
+}
+
+func (tx *onboarding) run(options onboardOptions, approver, requester *rolestore.RoleRef) (onboardResult, error) {
+	result := onboardResult{Hosts: []string{}}
+
+	role := map[string]interface{}{
+		"name":        options.name,
+		"comment":     "Team " + options.name,
+		"permissions": []string{},
+	}
+	if options.group != "" {
+		role["source_rules"] = mappingRule{
+			Type:  "GROUP",
+			Match: "ANY",
+			Rules: []mappingRule{{
+				Type:    "RULE",
+				Source:  options.sourceID,
+				Pattern: "^" + regexp.QuoteMeta(options.group) + "$",
+			}},
+		}
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	if _, err := curl().URL("/role-store/api/v1/roles").Post(role, &created); err != nil {
+		return result, fmt.Errorf("create role: %w", err)
+	}
+	roleID := created.ID
+	result.RoleID = roleID
+	tx.undo = append(tx.undo, func() error {
+		return rolestore.New(curl()).DeleteRole(roleID)
+	})
+
+	ref := rolestore.RoleRef{ID: roleID, Name: options.name}
+
+	if options.hostsTag != "" {
+		hosts, err := tx.mapHosts(options.hostsTag, options.principal, ref)
+		result.Hosts = hosts
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if approver != nil {
+		wf := map[string]interface{}{
+			"name":         options.name + " access",
+			"action":       "GRANT",
+			"grant_types":  []string{"PERMANENT", "TIME_RESTRICTED"},
+			"target_roles": []rolestore.RoleRef{ref},
+			"steps": []interface{}{map[string]interface{}{
+				"name":      "Approval",
+				"match":     "ANY",
+				"approvers": []interface{}{map[string]interface{}{"role": approver}},
+			}},
+		}
+		if requester != nil {
+			wf["requester_roles"] = []rolestore.RoleRef{*requester}
+		}
+
+		if _, err := curl().URL("/workflow-engine/api/v1/workflows").Post(wf, &created); err != nil {
+			return result, fmt.Errorf("create workflow: %w", err)
+		}
+		result.WorkflowID = created.ID
+		tx.undo = append(tx.undo, func() error {
+			return workflow.New(curl()).DeleteWorkflow(result.WorkflowID)
+		})
+	}
+
+	return result, nil
+}
+
+// mapHosts maps role to principal of hosts with the tag
+func (tx *onboarding) mapHosts(tag, principal string, ref rolestore.RoleRef) ([]string, error) {
+	api := hoststore.New(curl())
+	limit := 100
+	mapped := []string{}
+
+	for offset := 0; ; offset += limit {
+		page, err := api.Hosts(offset, limit, "", "", "")
+		if err != nil {
+			return mapped, err
+		}
+
+		var definitions []map[string]interface{}
+		if err := remarshal(page, &definitions); err != nil {
+			return mapped, err
+		}
+
+		for _, definition := range definitions {
+			var view struct {
+				ID         string                   `json:"id"`
+				Tags       []string                 `json:"tags"`
+				Principals []map[string]interface{} `json:"principals"`
+			}
+			if err := remarshal(definition, &view); err != nil {
+				return mapped, err
+			}
+			if !anyOf(view.Tags, []string{tag}) {
+				continue
+			}
+
+			var original hoststore.Host
+			if err := remarshal(definition, &original); err != nil {
+				return mapped, err
+			}
+
+			found := false
+			for _, p := range view.Principals {
+				if p["principal"] == principal {
+					roles, _ := p["roles"].([]interface{})
+					p["roles"] = append(roles, ref)
+					found = true
+				}
+			}
+			if !found {
+				view.Principals = append(view.Principals, map[string]interface{}{
+					"principal": principal,
+					"roles":     []rolestore.RoleRef{ref},
+				})
+			}
+			definition["principals"] = view.Principals
+
+			var update hoststore.Host
+			if err := remarshal(definition, &update); err != nil {
+				return mapped, err
+			}
+			if err := api.UpdateHost(view.ID, &update); err != nil {
+				return mapped, fmt.Errorf("host %s: %w", view.ID, err)
+			}
+
+			id := view.ID
+			tx.undo = append(tx.undo, func() error {
+				return api.UpdateHost(id, &original)
+			})
+			mapped = append(mapped, id)
+		}
+
+		if len(definitions) < limit {
+			return mapped, nil
+		}
+	}
+}
+
+// rollback undoes applied steps in reverse order
+func (tx *onboarding) rollback(cause error) error {
+	if errors.Is(cause, errDryRun) {
+		return cause
+	}
+
+	failed := []string{}
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		if err := tx.undo[i](); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%w, rollback failed: %s", cause, strings.Join(failed, "; "))
+	}
+	info("rolled back %d changes", len(tx.undo))
+
+	return cause
+}
+
+func findRoleRef(roles []rolestore.Role, nameOrID string) (*rolestore.RoleRef, error) {
+	for _, role := range roles {
+		if role.ID == nameOrID || role.Name == nameOrID {
+			return &rolestore.RoleRef{ID: role.ID, Name: role.Name}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("role not found: %s", nameOrID)
+}
@@ -0,0 +1,575 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/SSHcom/privx-cli/pkg/privxops"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	outFile      string
+	transform    string
+	outputFormat string
+	outTemplate  string
+	timezone     string
+	quiet        bool
+	relativeTime bool
+
+	// tableLocation is time zone of timestamps in table output
+	tableLocation *time.Location
+
+	// captured collects output of the command instead of writing it,
+	// bulk mode reports output of each file in its result
+	captured *[]interface{}
+)
+
+// columns are human-friendly table layouts of resource types,
+// other types show their scalar attributes
+var columns = map[string][]string{
+	"authorizer.AccessGroup":       {"id", "name", "comment"},
+	"connectionmanager.Connection": {"id", "type", "user.display_name", "target_host_address", "connected", "status"},
+	"hoststore.Host":               {"id", "common_name", "addresses", "tags", "access_group_id"},
+	"rolestore.Role":               {"id", "name", "member_count", "access_group_id", "comment"},
+	"rolestore.Source":             {"id", "name", "enabled", "connection.type"},
+	"rolestore.User":               {"id", "username", "display_name", "email", "source"},
+	"userstore.LocalUser":          {"id", "username", "full_name", "email"},
+	"userstore.TrustedClient":      {"id", "name", "type", "registered", "enabled"},
+	"vault.Secret":                 {"name", "author", "created", "updated"},
+	"workflow.Workflow":            {"id", "name", "action", "grant_types"},
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&outFile, "out", "", "write output to file atomically instead of stdout")
+	rootCmd.PersistentFlags().StringVar(&transform, "transform", "", "transform output with jq-lite expression (e.g. '.[] | select(.name == \"admin\") | .id')")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "json", "output format: json, yaml, table or csv")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "print only data and errors, no informational messages or warnings")
+	rootCmd.PersistentFlags().StringVar(&outTemplate, "template", "", "render output with Go template, applied to each item of lists (e.g. '{{.ID}}\\t{{.Name}}')")
+	rootCmd.PersistentFlags().StringVar(&timezone, "timezone", "Local", "time zone of timestamps in table output, e.g. UTC or Europe/Helsinki")
+	rootCmd.PersistentFlags().BoolVar(&relativeTime, "relative-time", false, "show timestamps in table output relative to now, e.g. 2h ago")
+}
+
+func stdout(data interface{}) error {
+	if captured != nil {
+		*captured = append(*captured, data)
+		return nil
+	}
+
+	kind := resourceType(data)
+
+	if query != "" {
+		var err error
+		data, err = queryOutput(data, query)
+		if err != nil {
+			return err
+		}
+		kind = ""
+	}
+
+	if transform != "" {
+		var err error
+		data, err = transformOutput(data, transform)
+		if err != nil {
+			return err
+		}
+		kind = ""
+	}
+
+	if outTemplate != "" {
+		encoded, err := renderTemplate(data, outTemplate)
+		if err != nil {
+			return err
+		}
+		return writeOutput(encoded)
+	}
+
+	encoded, err := render(data, kind)
+	if err != nil {
+		return err
+	}
+
+	return writeOutput(encoded)
+}
+
+// info prints informational message or warning to stderr, stdout is
+// reserved for data so that output of commands can be piped
+func info(format string, args ...interface{}) {
+	if quiet {
+		return
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
+
+// writeOutput writes already rendered output to stdout or --out file
+func writeOutput(data []byte) error {
+	if captured != nil {
+		*captured = append(*captured, string(data))
+		return nil
+	}
+
+	if outFile != "" {
+		return writeFileAtomic(outFile, textFile(data))
+	}
+
+	_, err := os.Stdout.Write(data)
+	return err
+}
+
+func render(data interface{}, kind string) ([]byte, error) {
+	switch outputFormat {
+	case "", "json":
+		return json.Marshal(data)
+	case "yaml":
+		var doc interface{}
+		if err := remarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		return yaml.Marshal(doc)
+	case "table", "csv":
+		if outputFormat == "table" {
+			location, err := time.LoadLocation(timezone)
+			if err != nil {
+				return nil, fmt.Errorf("invalid --timezone: %w", err)
+			}
+			tableLocation = location
+		}
+
+		header, rows, err := tabulate(data, kind)
+		if err != nil {
+			return nil, err
+		}
+		if outputFormat == "csv" {
+			return privxops.CSV(header, rows)
+		}
+		return privxops.Table(header, rows), nil
+	}
+
+	return nil, fmt.Errorf("unknown output format: %s", outputFormat)
+}
+
+// templateFuncs are helpers available in output templates
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"join":  strings.Join,
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+}
+
+// renderTemplate executes Go template for each element of list or once
+// for other data, each execution ends with newline. Fields are named as
+// in SDK types, e.g. {{.ID}}, or by JSON keys after --transform.
+func renderTemplate(data interface{}, text string) ([]byte, error) {
+	text = strings.NewReplacer(`\t`, "\t", `\n`, "\n").Replace(text)
+
+	tmpl, err := template.New("output").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	items := []interface{}{data}
+	value := reflect.ValueOf(data)
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+	if value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+		items = make([]interface{}, value.Len())
+		for i := range items {
+			items[i] = value.Index(i).Interface()
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, item := range items {
+		if err := tmpl.Execute(&buf, item); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\n")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// resourceType names SDK type of data as package.Type,
+// collections are named by their element type
+func resourceType(data interface{}) string {
+	t := reflect.TypeOf(data)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+		t = t.Elem()
+	}
+	if t == nil || t.Name() == "" {
+		return ""
+	}
+
+	return filepath.Base(t.PkgPath()) + "." + t.Name()
+}
+
+// tabulate converts data to rows of columns. Paginated results
+// are tabulated by their items.
+func tabulate(data interface{}, kind string) ([]string, [][]string, error) {
+	var doc interface{}
+	if err := remarshal(data, &doc); err != nil {
+		return nil, nil, err
+	}
+
+	if object, ok := doc.(map[string]interface{}); ok {
+		if items, ok := object["items"].([]interface{}); ok {
+			doc = items
+		}
+	}
+
+	records := []interface{}{doc}
+	if seq, ok := doc.([]interface{}); ok {
+		records = seq
+	}
+
+	header := columns[kind]
+	if header == nil {
+		header = scalarKeys(records)
+	}
+
+	rows := [][]string{}
+	for _, record := range records {
+		if len(header) == 0 {
+			rows = append(rows, []string{cell(record)})
+			continue
+		}
+
+		row := make([]string, len(header))
+		for i, key := range header {
+			row[i] = cell(jsonPath(record, key))
+		}
+		rows = append(rows, row)
+	}
+
+	if len(header) == 0 {
+		header = []string{"value"}
+	}
+
+	return header, rows, nil
+}
+
+// scalarKeys collects keys with scalar values, id and name go first
+func scalarKeys(records []interface{}) []string {
+	seen := map[string]bool{}
+	keys := []string{}
+
+	for _, record := range records {
+		object, ok := record.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for key, value := range object {
+			switch value.(type) {
+			case map[string]interface{}, []interface{}:
+				continue
+			}
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	rank := func(key string) int {
+		switch key {
+		case "id":
+			return 0
+		case "name", "username", "common_name":
+			return 1
+		}
+		return 2
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if rank(keys[i]) != rank(keys[j]) {
+			return rank(keys[i]) < rank(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+
+	return keys
+}
+
+func cell(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		if tableLocation != nil {
+			if at, err := time.Parse(time.RFC3339Nano, v); err == nil {
+				return timeCell(at)
+			}
+		}
+		return v
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			if object, ok := item.(map[string]interface{}); ok && object["name"] != nil {
+				item = object["name"]
+			}
+			items[i] = cell(item)
+		}
+		return strings.Join(items, ",")
+	case map[string]interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+
+	return fmt.Sprint(value)
+}
+
+// timeCell renders timestamp of table output in the selected time zone
+// or relative to now
+func timeCell(at time.Time) string {
+	if !relativeTime {
+		return at.In(tableLocation).Format("2006-01-02 15:04:05 MST")
+	}
+
+	ago, format := time.Since(at), "%s ago"
+	if ago < 0 {
+		ago, format = -ago, "in %s"
+	}
+
+	switch {
+	case ago < time.Minute:
+		return fmt.Sprintf(format, fmt.Sprintf("%ds", int(ago.Seconds())))
+	case ago < time.Hour:
+		return fmt.Sprintf(format, fmt.Sprintf("%dm", int(ago.Minutes())))
+	case ago < 48*time.Hour:
+		return fmt.Sprintf(format, fmt.Sprintf("%dh", int(ago.Hours())))
+	}
+
+	return fmt.Sprintf(format, fmt.Sprintf("%dd", int(ago.Hours()/24)))
+}
+
+// writeSecret delivers secret to file readable only by the owner
+// or to stdout if the target is "-"
+func writeSecret(target string, secret []byte) error {
+	if target == "-" {
+		_, err := os.Stdout.Write(secret)
+		return err
+	}
+
+	if err := writeFileAtomic(target, secret); err != nil {
+		return err
+	}
+
+	return os.Chmod(target, 0600)
+}
+
+// writeFileAtomic writes data to temporary file next to the target and
+// renames it, readers never observe partially written file
+func writeFileAtomic(name string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(name), "."+filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	defer trackPartial(tmp.Name())()
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
+
+// transformOutput evaluates jq-lite expression over JSON view of data.
+// Supported filters are paths (., .key, .[n], .[]), select(PATH OP VALUE)
+// with == and !=, keys and length combined with pipes. Result of the
+// expression that iterates is an array.
+func transformOutput(data interface{}, expr string) (interface{}, error) {
+	var doc interface{}
+	if err := remarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	stream := []interface{}{doc}
+	iterated := false
+
+	for _, filter := range strings.Split(expr, "|") {
+		filter = strings.TrimSpace(filter)
+		next := []interface{}{}
+
+		for _, value := range stream {
+			values, iter, err := jqFilter(value, filter)
+			if err != nil {
+				return nil, err
+			}
+			iterated = iterated || iter
+			next = append(next, values...)
+		}
+
+		stream = next
+	}
+
+	if !iterated && len(stream) == 1 {
+		return stream[0], nil
+	}
+
+	return stream, nil
+}
+
+func jqFilter(value interface{}, filter string) ([]interface{}, bool, error) {
+	switch {
+	case filter == "keys":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false, fmt.Errorf("keys requires object")
+		}
+		keys := []interface{}{}
+		for key := range object {
+			keys = append(keys, key)
+		}
+		return []interface{}{keys}, false, nil
+
+	case filter == "length":
+		switch v := value.(type) {
+		case []interface{}:
+			return []interface{}{len(v)}, false, nil
+		case map[string]interface{}:
+			return []interface{}{len(v)}, false, nil
+		case string:
+			return []interface{}{len(v)}, false, nil
+		}
+		return []interface{}{0}, false, nil
+
+	case strings.HasPrefix(filter, "select(") && strings.HasSuffix(filter, ")"):
+		ok, err := jqSelect(value, filter[len("select("):len(filter)-1])
+		if err != nil || !ok {
+			return nil, false, err
+		}
+		return []interface{}{value}, false, nil
+	}
+
+	return jqPath(value, filter)
+}
+
+func jqSelect(value interface{}, cond string) (bool, error) {
+	op := "=="
+	parts := strings.SplitN(cond, "==", 2)
+	if len(parts) != 2 {
+		op = "!="
+		parts = strings.SplitN(cond, "!=", 2)
+	}
+	if len(parts) != 2 {
+		return false, fmt.Errorf("unsupported select condition: %s", cond)
+	}
+
+	values, _, err := jqPath(value, strings.TrimSpace(parts[0]))
+	if err != nil {
+		return false, err
+	}
+
+	var literal interface{}
+	if err := json.Unmarshal([]byte(strings.TrimSpace(parts[1])), &literal); err != nil {
+		return false, fmt.Errorf("invalid literal in select: %s", parts[1])
+	}
+
+	for _, v := range values {
+		if equal := fmt.Sprint(v) == fmt.Sprint(literal); equal == (op == "==") {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// jqPath evaluates path expression such as .items[].name
+func jqPath(value interface{}, path string) ([]interface{}, bool, error) {
+	if !strings.HasPrefix(path, ".") {
+		return nil, false, fmt.Errorf("unsupported expression: %s", path)
+	}
+
+	stream := []interface{}{value}
+	iterated := false
+	rest := path[1:]
+
+	for rest != "" {
+		next := []interface{}{}
+
+		switch {
+		case strings.HasPrefix(rest, "[]"):
+			rest = rest[2:]
+			iterated = true
+			for _, v := range stream {
+				switch seq := v.(type) {
+				case []interface{}:
+					next = append(next, seq...)
+				case map[string]interface{}:
+					for _, item := range seq {
+						next = append(next, item)
+					}
+				}
+			}
+
+		case strings.HasPrefix(rest, "["):
+			end := strings.Index(rest, "]")
+			if end < 0 {
+				return nil, false, fmt.Errorf("unterminated index: %s", path)
+			}
+			index, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return nil, false, fmt.Errorf("invalid index: %s", path)
+			}
+			rest = rest[end+1:]
+			for _, v := range stream {
+				if seq, ok := v.([]interface{}); ok && index >= 0 && index < len(seq) {
+					next = append(next, seq[index])
+				} else {
+					next = append(next, nil)
+				}
+			}
+
+		default:
+			rest = strings.TrimPrefix(rest, ".")
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			key := rest[:end]
+			rest = rest[end:]
+			for _, v := range stream {
+				if object, ok := v.(map[string]interface{}); ok {
+					next = append(next, object[key])
+				} else {
+					next = append(next, nil)
+				}
+			}
+		}
+
+		stream = next
+	}
+
+	return stream, iterated, nil
+}
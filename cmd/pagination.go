@@ -0,0 +1,32 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"github.com/SSHcom/privx-cli/pkg/privxops"
+	"github.com/spf13/cobra"
+)
+
+// pagination are paging flags of list commands whose API returns the
+// whole list, the page is cut on the client side
+type pagination struct {
+	privxops.Page
+}
+
+func (p *pagination) register(cmd *cobra.Command) {
+	flags := cmd.Flags()
+	flags.IntVar(&p.Offset, "offset", 0, "where to start fetching the items")
+	flags.IntVar(&p.Limit, "limit", 0, "number of items to return (default all)")
+	flags.StringVar(&p.SortKey, "sortkey", "", "sort by specific object property")
+	flags.StringVar(&p.SortDir, "sortdir", "", "sort direction, ASC or DESC (default ASC)")
+	flags.BoolVar(&p.All, "all", false, "return all items, ignoring --offset and --limit")
+}
+
+// allPages walks all pages of paginated API until interrupted
+func allPages(fetch func(offset, limit int) (interface{}, error)) (interface{}, error) {
+	return privxops.AllPages(runContext, fetch)
+}
@@ -0,0 +1,37 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"bytes"
+	"runtime"
+)
+
+// crlf writes text files with CRLF line endings, default on Windows
+var crlf bool
+
+func init() {
+	rootCmd.PersistentFlags().BoolVar(&crlf, "crlf", runtime.GOOS == "windows", "write --out and export files with CRLF line endings")
+}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// stripBOM removes UTF-8 byte order mark written by Windows tools,
+// e.g. Out-File of PowerShell 5, which JSON and YAML decoders reject
+func stripBOM(data []byte) []byte {
+	return bytes.TrimPrefix(data, utf8BOM)
+}
+
+// textFile converts line endings of text written to files with --crlf
+func textFile(data []byte) []byte {
+	if !crlf {
+		return data
+	}
+
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+}
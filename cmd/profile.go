@@ -0,0 +1,443 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	profile string
+
+	// profileActive is set when connection is configured by profile
+	profileActive bool
+)
+
+// profileKeys are attributes of profile and their section in the
+// config.toml format of the SDK, cli attributes are used by CLI only
+var profileKeys = map[string]string{
+	"base_url":            "api",
+	"api_ca_crt":          "api",
+	"api_client_id":       "auth",
+	"api_client_secret":   "auth",
+	"oauth_client_id":     "auth",
+	"oauth_client_secret": "auth",
+	"access_group":        "cli",
+	"allow_commands":      "cli",
+	"deny_commands":       "cli",
+}
+
+// cliConfig is ~/.privx-cli/config.yaml
+type cliConfig struct {
+	Current  string                       `yaml:"current,omitempty"`
+	Profiles map[string]map[string]string `yaml:"profiles"`
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&profile, "profile", os.Getenv("PRIVX_CLI_PROFILE"), "named profile of ~/.privx-cli/config.yaml")
+	rootCmd.AddCommand(configCmd())
+}
+
+//
+//
+func configCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Manage connection profiles",
+		Long: `Manage named connection profiles stored in ~/.privx-cli/config.yaml. The profile
+is selected with @NAME as the first argument, --profile, PRIVX_CLI_PROFILE or
+config use. The base_url may list several comma separated URLs of HA deployment.
+Environment variables and --config file take precedence over the profile.
+
+Profile keys: base_url, api_ca_crt, api_client_id, api_client_secret,
+oauth_client_id, oauth_client_secret, access_group (default namespace of secrets),
+allow_commands and deny_commands (comma separated command prefixes, e.g.
+"connections,audit-events list", deny takes precedence)`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(configSetCmd())
+	cmd.AddCommand(configGetCmd())
+	cmd.AddCommand(configListCmd())
+	cmd.AddCommand(configUseCmd())
+
+	return cmd
+}
+
+//
+//
+func configSetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "set KEY VALUE",
+		Short: "Set profile attribute",
+		Long:  `Set profile attribute, value @FILE reads the value from file`,
+		Example: `
+	privx-cli config set base_url https://privx.example.com --profile prod
+	privx-cli config set api_ca_crt @ca.pem --profile prod
+	privx-cli config set allow_commands connections,audit-events --profile readonly
+		`,
+		Args:         cobra.ExactArgs(2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return configSet(args[0], args[1])
+		},
+	}
+
+	return cmd
+}
+
+func configSet(key, value string) error {
+	if _, ok := profileKeys[key]; !ok {
+		return fmt.Errorf("unknown profile key: %s", key)
+	}
+
+	if strings.HasPrefix(value, "@") {
+		data, err := ioutil.ReadFile(value[1:])
+		if err != nil {
+			return err
+		}
+		value = string(stripBOM(data))
+	}
+
+	conf, err := readConfig()
+	if err != nil {
+		return err
+	}
+
+	name := activeProfile(conf)
+	if err := checkProfileName(name); err != nil {
+		return err
+	}
+	if conf.Profiles[name] == nil {
+		conf.Profiles[name] = map[string]string{}
+	}
+	conf.Profiles[name][key] = value
+	if conf.Current == "" {
+		conf.Current = name
+	}
+
+	return writeConfig(conf)
+}
+
+//
+//
+func configGetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get KEY",
+		Short: "Get profile attribute",
+		Long:  `Get profile attribute`,
+		Example: `
+	privx-cli config get base_url --profile prod
+		`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return configGet(args[0])
+		},
+	}
+
+	return cmd
+}
+
+func configGet(key string) error {
+	conf, err := readConfig()
+	if err != nil {
+		return err
+	}
+
+	name := activeProfile(conf)
+	attrs, ok := conf.Profiles[name]
+	if !ok {
+		return fmt.Errorf("profile does not exist: %s", name)
+	}
+
+	value, ok := attrs[key]
+	if !ok {
+		return fmt.Errorf("%s is not set in profile %s", key, name)
+	}
+
+	fmt.Println(value)
+	return nil
+}
+
+//
+//
+func configListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List profiles",
+		Long:  `List profiles, secrets are masked`,
+		Example: `
+	privx-cli config list
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return configList()
+		},
+	}
+
+	return cmd
+}
+
+type profileView struct {
+	Name       string            `json:"name"`
+	Current    bool              `json:"current"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+func configList() error {
+	conf, err := readConfig()
+	if err != nil {
+		return err
+	}
+
+	current := activeProfile(conf)
+	profiles := []profileView{}
+	for name, attrs := range conf.Profiles {
+		view := profileView{Name: name, Current: name == current, Attributes: map[string]string{}}
+		for key, value := range attrs {
+			if strings.HasSuffix(key, "_secret") {
+				value = "********"
+			}
+			if key == "api_ca_crt" {
+				value = fmt.Sprintf("(%d bytes)", len(value))
+			}
+			view.Attributes[key] = value
+		}
+		profiles = append(profiles, view)
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Name < profiles[j].Name })
+
+	return stdout(profiles)
+}
+
+//
+//
+func configUseCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "use NAME",
+		Short: "Select default profile",
+		Long:  `Select default profile`,
+		Example: `
+	privx-cli config use prod
+		`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return configUse(args[0])
+		},
+	}
+
+	return cmd
+}
+
+func configUse(name string) error {
+	conf, err := readConfig()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := conf.Profiles[name]; !ok {
+		return fmt.Errorf("profile does not exist: %s", name)
+	}
+	conf.Current = name
+
+	return writeConfig(conf)
+}
+
+// profileSelector converts leading @name argument to --profile flag
+func profileSelector(args []string) []string {
+	if len(args) == 0 || !strings.HasPrefix(args[0], "@") || len(args[0]) == 1 {
+		return args
+	}
+
+	// flag precedes the command, arguments after -- stay positional
+	return append([]string{"--profile=" + args[0][1:]}, args[1:]...)
+}
+
+// checkProfileName rejects names which are not a plain file name, the
+// profile is rendered to a file of the name
+func checkProfileName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid profile name: %q", name)
+	}
+	return nil
+}
+
+// activeProfile is given by --profile, otherwise the current one
+func activeProfile(conf cliConfig) string {
+	switch {
+	case profile != "":
+		return profile
+	case conf.Current != "":
+		return conf.Current
+	}
+	return "default"
+}
+
+func configFile() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.yaml"), nil
+}
+
+func readConfig() (cliConfig, error) {
+	conf := cliConfig{Profiles: map[string]map[string]string{}}
+
+	file, err := configFile()
+	if err != nil {
+		return conf, err
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return conf, nil
+	}
+	if err != nil {
+		return conf, err
+	}
+
+	if err := yaml.Unmarshal(stripBOM(data), &conf); err != nil {
+		return conf, fmt.Errorf("%s: %w", file, err)
+	}
+	if conf.Profiles == nil {
+		conf.Profiles = map[string]map[string]string{}
+	}
+
+	return conf, nil
+}
+
+func writeConfig(conf cliConfig) error {
+	file, err := configFile()
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(conf)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(file, data)
+}
+
+// profileAttributes returns attributes of the active profile
+func profileAttributes() map[string]string {
+	conf, err := readConfig()
+	if err != nil {
+		return nil
+	}
+	return conf.Profiles[activeProfile(conf)]
+}
+
+// checkCommandAllowed enforces allow and deny lists of the active profile.
+// The lists constrain shared automation credentials to intended commands,
+// they do not protect against user editing the profile.
+func checkCommandAllowed(cmd *cobra.Command) error {
+	attrs := profileAttributes()
+	path := strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+
+	if prefix, ok := commandPrefixMatch(attrs["deny_commands"], path); ok {
+		return fmt.Errorf("command %q is denied by profile (deny_commands %q)", path, prefix)
+	}
+
+	if allow := attrs["allow_commands"]; allow != "" {
+		if _, ok := commandPrefixMatch(allow, path); !ok {
+			return fmt.Errorf("command %q is not allowed by profile, allowed: %s", path, allow)
+		}
+	}
+
+	return nil
+}
+
+// commandPrefixMatch finds prefix of comma separated list matching
+// whole words of command path
+func commandPrefixMatch(prefixes, path string) (string, bool) {
+	for _, prefix := range strings.Split(prefixes, ",") {
+		prefix = strings.Join(strings.Fields(prefix), " ")
+		if prefix != "" && (path == prefix || strings.HasPrefix(path, prefix+" ")) {
+			return prefix, true
+		}
+	}
+	return "", false
+}
+
+// useProfile renders active profile to SDK config file unless
+// the config file is given explicitly
+func useProfile() error {
+	if config != "" {
+		return nil
+	}
+
+	conf, err := readConfig()
+	if err != nil {
+		return err
+	}
+
+	name := activeProfile(conf)
+	if err := checkProfileName(name); err != nil {
+		return err
+	}
+	attrs, ok := conf.Profiles[name]
+	if !ok {
+		if profile != "" {
+			return fmt.Errorf("profile does not exist: %s", name)
+		}
+		return nil
+	}
+
+	var toml bytes.Buffer
+	for _, section := range []string{"api", "auth"} {
+		fmt.Fprintf(&toml, "[%s]\n", section)
+		for _, key := range []string{"base_url", "api_ca_crt", "api_client_id",
+			"api_client_secret", "oauth_client_id", "oauth_client_secret"} {
+			value, ok := attrs[key]
+			if !ok || profileKeys[key] != section {
+				continue
+			}
+			if key == "base_url" {
+				value = strings.TrimSpace(strings.Split(value, ",")[0])
+			}
+			fmt.Fprintf(&toml, "%s=%s\n", key, strconv.Quote(value))
+		}
+	}
+
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+
+	file := filepath.Join(dir, "profiles", name+".toml")
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	// the file holds client secrets, it is rewritten only on change
+	if current, err := ioutil.ReadFile(file); err != nil || !bytes.Equal(current, toml.Bytes()) {
+		if err := writeFileAtomic(file, toml.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := os.Chmod(file, 0600); err != nil {
+		return err
+	}
+
+	config = file
+	profileActive = true
+	return nil
+}
@@ -0,0 +1,205 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type purgeOptions struct {
+	userIDs  string
+	interval time.Duration
+	confirm  bool
+}
+
+// purgeTarget is a class of user data removed by data-removal endpoint
+type purgeTarget struct {
+	name     string
+	feature  string
+	endpoint string
+	count    func(userID string) (int, error)
+}
+
+// purgeTargets are data-removal steps of GDPR erasure, in execution order
+var purgeTargets = []purgeTarget{
+	{
+		name:     "connections",
+		feature:  "connection anonymization",
+		endpoint: "/connection-manager/api/v1/connections/uid/%s",
+		count:    countUserConnections,
+	},
+	{
+		name:     "audit-events",
+		feature:  "audit event scrubbing",
+		endpoint: "/monitor-service/api/v1/auditevents/uid/%s",
+		count:    countUserAuditEvents,
+	},
+}
+
+// purgeStep is evidence of a single data-removal step
+type purgeStep struct {
+	Target   string `json:"target"`
+	Endpoint string `json:"endpoint"`
+	Records  int    `json:"records"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+}
+
+// purgeReport is evidence of user data erasure
+type purgeReport struct {
+	UserID   string      `json:"user_id"`
+	Operator string      `json:"operator,omitempty"`
+	Started  time.Time   `json:"started"`
+	Finished time.Time   `json:"finished"`
+	Confirm  bool        `json:"confirmed"`
+	Steps    []purgeStep `json:"steps"`
+}
+
+//
+//
+func userPurgeDataCmd() *cobra.Command {
+	options := purgeOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "purge-data",
+		Short: "Remove personal data of users for GDPR erasure requests",
+		Long: `Remove personal data of users for GDPR erasure requests. Connections of the user are
+anonymized and audit events are scrubbed where the PrivX server supports it. Without
+--confirm the records are only counted. Removal calls are spaced by --interval to
+limit load on the server. The output is an evidence report of what was removed.`,
+		Example: `
+	privx-cli users purge-data [access flags] --uid <USER-ID>
+	privx-cli users purge-data [access flags] --uid <USER-ID>,<USER-ID> --confirm > evidence.json
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return userPurgeData(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.userIDs, "uid", "", "comma separated list of user IDs")
+	flags.DurationVar(&options.interval, "interval", time.Second, "delay between removal calls")
+	flags.BoolVar(&options.confirm, "confirm", false, "remove the data, otherwise records are only counted")
+	cmd.MarkFlagRequired("uid")
+
+	return cmd
+}
+
+func userPurgeData(options purgeOptions) error {
+	operator, _ := currentUserID()
+	reports := []purgeReport{}
+	failed := 0
+
+	for _, userID := range strings.Split(options.userIDs, ",") {
+		report := purgeReport{
+			UserID:   userID,
+			Operator: operator,
+			Started:  time.Now().UTC(),
+			Confirm:  options.confirm,
+			Steps:    []purgeStep{},
+		}
+
+		for _, target := range purgeTargets {
+			if err := interrupted(); err != nil {
+				return err
+			}
+
+			step := purgeUserData(target, userID, options.confirm)
+			if step.Status == "failed" {
+				failed++
+			}
+			report.Steps = append(report.Steps, step)
+
+			if options.confirm && options.interval > 0 {
+				time.Sleep(options.interval)
+			}
+		}
+
+		report.Finished = time.Now().UTC()
+		reports = append(reports, report)
+	}
+
+	if err := stdout(reports); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d removal steps failed", failed)
+	}
+
+	return nil
+}
+
+func purgeUserData(target purgeTarget, userID string, confirm bool) purgeStep {
+	step := purgeStep{
+		Target:   target.name,
+		Endpoint: fmt.Sprintf(target.endpoint, url.PathEscape(userID)),
+		Status:   "pending",
+	}
+
+	records, err := target.count(userID)
+	if err != nil {
+		step.Status, step.Error = "failed", err.Error()
+		return step
+	}
+	step.Records = records
+
+	if !confirm {
+		return step
+	}
+
+	if records == 0 {
+		step.Status = "nothing to remove"
+		return step
+	}
+
+	_, err = curl().URL(step.Endpoint).Delete()
+	switch {
+	case err == nil && dryRun:
+		step.Status = "dry-run"
+	case err != nil:
+		err = apiUnsupported(err, target.feature)
+		if strings.Contains(err.Error(), "not supported") {
+			step.Status = "unsupported"
+		} else {
+			step.Status = "failed"
+		}
+		step.Error = err.Error()
+	default:
+		step.Status = "removed"
+	}
+
+	return step
+}
+
+func countUserConnections(userID string) (int, error) {
+	var result struct {
+		Count int `json:"count"`
+	}
+
+	_, err := curl().
+		URL("/connection-manager/api/v1/connections/search").
+		Query(map[string]interface{}{"offset": 0, "limit": 1}).
+		Post(map[string]interface{}{"user_id": []string{userID}}, &result)
+
+	return result.Count, err
+}
+
+func countUserAuditEvents(userID string) (int, error) {
+	events, err := searchEvents(eventFilter{userID: userID}, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(events), nil
+}
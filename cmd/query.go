@@ -0,0 +1,34 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/SSHcom/privx-cli/pkg/privxops"
+)
+
+var query string
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&query, "query", "", "select output with JMESPath expression (e.g. \"[?name=='admin'].id\")")
+}
+
+// queryOutput evaluates JMESPath expression over JSON view of data
+func queryOutput(data interface{}, expr string) (interface{}, error) {
+	var doc interface{}
+	if err := remarshal(data, &doc); err != nil {
+		return nil, err
+	}
+
+	result, err := privxops.Query(doc, expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --query: %w", err)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,262 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/SSHcom/privx-sdk-go/api/userstore"
+	"github.com/SSHcom/privx-sdk-go/api/vault"
+	"github.com/spf13/cobra"
+)
+
+type reportOptions struct {
+	roleID string
+	userID string
+}
+
+type orphanReport struct {
+	Hosts          []orphan `json:"hosts_without_roles"`
+	Roles          []orphan `json:"roles_without_members_or_mappings"`
+	Secrets        []orphan `json:"secrets_without_readers"`
+	TrustedClients []orphan `json:"trusted_clients_never_connected"`
+}
+
+type orphan struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+type secretAccess struct {
+	Name  string   `json:"name"`
+	Read  []string `json:"read_via"`
+	Write []string `json:"write_via"`
+}
+
+func init() {
+	rootCmd.AddCommand(reportCmd())
+}
+
+//
+//
+func reportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "report",
+		Short:        "Reports over PrivX objects",
+		Long:         `Reports over PrivX objects`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(reportSecretAccessCmd())
+	cmd.AddCommand(reportOrphansCmd())
+
+	return cmd
+}
+
+//
+//
+func reportSecretAccessCmd() *cobra.Command {
+	options := reportOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "secret-access",
+		Short: "List secrets readable or writable by role or user",
+		Long: `List every secret readable or writable via the given roles or via the roles of the user.
+Role ID's are separated by commas when using multiple values, see example`,
+		Example: `
+	privx-cli report secret-access [access flags] --role <ROLE-ID>,<ROLE-ID>
+	privx-cli report secret-access [access flags] --user <USER-ID>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return reportSecretAccess(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.roleID, "role", "", "role ID")
+	flags.StringVar(&options.userID, "user", "", "user ID")
+
+	return cmd
+}
+
+func reportSecretAccess(options reportOptions) error {
+	if (options.roleID == "") == (options.userID == "") {
+		return errors.New("specify either --role or --user")
+	}
+
+	subject := map[string]bool{}
+	if options.roleID != "" {
+		for _, id := range strings.Split(options.roleID, ",") {
+			subject[id] = true
+		}
+	} else {
+		roles, err := rolestore.New(curl()).UserRoles(options.userID)
+		if err != nil {
+			return err
+		}
+
+		var refs []rolestore.RoleRef
+		if err := remarshal(roles, &refs); err != nil {
+			return err
+		}
+		for _, ref := range refs {
+			subject[ref.ID] = true
+		}
+	}
+
+	secrets, err := allSecrets()
+	if err != nil {
+		return err
+	}
+
+	report := []secretAccess{}
+	for _, secret := range secrets {
+		access := secretAccess{Name: secret.ID, Read: []string{}, Write: []string{}}
+		for _, ref := range secret.AllowRead {
+			if subject[ref.ID] {
+				access.Read = append(access.Read, ref.ID)
+			}
+		}
+		for _, ref := range secret.AllowWrite {
+			if subject[ref.ID] {
+				access.Write = append(access.Write, ref.ID)
+			}
+		}
+
+		if len(access.Read) > 0 || len(access.Write) > 0 {
+			report = append(report, access)
+		}
+	}
+
+	return stdout(report)
+}
+
+// allSecrets walks all pages of vault secrets
+func allSecrets() ([]vault.Secret, error) {
+	api := vault.New(curl())
+	limit := 100
+	secrets := []vault.Secret{}
+
+	for offset := 0; ; offset += limit {
+		page, err := api.Secrets(offset, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		secrets = append(secrets, page...)
+		if len(page) < limit {
+			return secrets, nil
+		}
+	}
+}
+
+//
+//
+func reportOrphansCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "orphans",
+		Short: "Find orphaned objects",
+		Long: `Find hosts with no role mappings, roles with no members and no mappings,
+secrets with no readable roles and trusted clients never connected`,
+		Example: `
+	privx-cli report orphans [access flags]
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return reportOrphans()
+		},
+	}
+
+	return cmd
+}
+
+func reportOrphans() error {
+	report := orphanReport{
+		Hosts:          []orphan{},
+		Roles:          []orphan{},
+		Secrets:        []orphan{},
+		TrustedClients: []orphan{},
+	}
+
+	hosts, err := allHosts()
+	if err != nil {
+		return err
+	}
+
+	mapped := map[string]bool{}
+	for _, host := range hosts {
+		roles := 0
+		for _, principal := range host.Principals {
+			for _, role := range principal.Roles {
+				mapped[role.ID] = true
+				roles++
+			}
+		}
+		if roles == 0 {
+			report.Hosts = append(report.Hosts, orphan{host.ID, host.CommonName})
+		}
+	}
+
+	secrets, err := allSecrets()
+	if err != nil {
+		return err
+	}
+
+	for _, secret := range secrets {
+		for _, ref := range secret.AllowRead {
+			mapped[ref.ID] = true
+		}
+		if len(secret.AllowRead) == 0 {
+			report.Secrets = append(report.Secrets, orphan{Name: secret.ID})
+		}
+	}
+
+	roles, err := rolestore.New(curl()).Roles()
+	if err != nil {
+		return err
+	}
+
+	var roleView []struct {
+		ID          string `json:"id"`
+		Name        string `json:"name"`
+		MemberCount int    `json:"member_count"`
+	}
+	if err := remarshal(roles, &roleView); err != nil {
+		return err
+	}
+
+	for _, role := range roleView {
+		if role.MemberCount == 0 && !mapped[role.ID] {
+			report.Roles = append(report.Roles, orphan{role.ID, role.Name})
+		}
+	}
+
+	clients, err := userstore.New(curl()).TrustedClients()
+	if err != nil {
+		return err
+	}
+
+	var clientView []struct {
+		ID         string `json:"id"`
+		Name       string `json:"name"`
+		Registered bool   `json:"registered"`
+	}
+	if err := remarshal(clients, &clientView); err != nil {
+		return err
+	}
+
+	for _, client := range clientView {
+		if !client.Registered {
+			report.TrustedClients = append(report.TrustedClients, orphan{client.ID, client.Name})
+		}
+	}
+
+	return stdout(report)
+}
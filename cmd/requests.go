@@ -9,18 +9,24 @@ package cmd
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/SSHcom/privx-sdk-go/api/workflow"
 	"github.com/spf13/cobra"
 )
 
 type requestOptions struct {
-	requestID string
-	filter    string
-	sortkey   string
-	sortdir   string
-	limit     int
-	offset    int
+	requestID     string
+	comment       string
+	filter        string
+	sortkey       string
+	sortdir       string
+	olderThan     string
+	escalateAfter string
+	escalateTo    string
+	webhook       string
+	limit         int
+	offset        int
 }
 
 func init() {
@@ -50,11 +56,16 @@ func requestListCmd() *cobra.Command {
 	flags.IntVar(&options.limit, "limit", 50, "number of items to return")
 	flags.StringVar(&options.filter, "filter", "", "filter request items")
 
+	cmd.AddCommand(requestListSubCmd())
 	cmd.AddCommand(requestCreateCmd())
 	cmd.AddCommand(requestShowCmd())
 	cmd.AddCommand(requestDeleteCmd())
 	cmd.AddCommand(requestHandlingCmd())
+	cmd.AddCommand(requestDecisionCmd("approve", "approved", "Approve a request"))
+	cmd.AddCommand(requestDecisionCmd("reject", "denied", "Reject a request"))
+	cmd.AddCommand(requestDecisionCmd("revoke", "revoked", "Revoke an approved request"))
 	cmd.AddCommand(requestSearchCmd())
+	cmd.AddCommand(requestRemindCmd())
 
 	return cmd
 }
@@ -70,13 +81,40 @@ func requestList(options requestOptions) error {
 	return stdout(requests)
 }
 
+//
+//
+func requestListSubCmd() *cobra.Command {
+	options := requestOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List request queue",
+		Long:  `List the request queue for the user`,
+		Example: `
+	privx-cli requests list [access flags] --offset <OFFSET> --limit <LIMIT> --filter <FILTER>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return requestList(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.IntVar(&options.offset, "offset", 0, "where to start fetching the items")
+	flags.IntVar(&options.limit, "limit", 50, "number of items to return")
+	flags.StringVar(&options.filter, "filter", "", "filter request items")
+
+	return cmd
+}
+
 //
 //
 func requestCreateCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "create",
 		Short: "Create request",
-		Long:  `Add a workflow to the request queue`,
+		Long: `Add a workflow to the request queue. The command exits with status 3 when
+the request is pending approval, the request ID is printed as JSON state.`,
 		Example: `
 	privx-cli requests create [access flags] JSON-FILE
 		`,
@@ -87,7 +125,7 @@ func requestCreateCmd() *cobra.Command {
 		},
 	}
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func requestCreate(cmd *cobra.Command, args []string) error {
@@ -104,6 +142,22 @@ func requestCreate(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	request, err := api.Request(id)
+	if err != nil {
+		return err
+	}
+
+	var state struct {
+		Decision string `json:"decision"`
+	}
+	if err := remarshal(request, &state); err != nil {
+		return err
+	}
+
+	if strings.EqualFold(state.Decision, "PENDING") {
+		return pendingApproval(id)
+	}
+
 	return stdout(id)
 }
 
@@ -230,6 +284,56 @@ func requestHandling(options requestOptions, args []string) error {
 	return nil
 }
 
+//
+//
+func requestDecisionCmd(use, decision, short string) *cobra.Command {
+	options := requestOptions{}
+
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Long: short + `. Only users with matching role are permitted to change the status
+of a step requiring such role.`,
+		Example: fmt.Sprintf(`
+	privx-cli requests %s [access flags] --id <REQUEST-ID>
+	privx-cli requests %s [access flags] --id <REQUEST-ID> --comment "handled by on-call"
+		`, use, use),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return requestDecision(options, decision)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.requestID, "id", "", "comma separated request IDs")
+	flags.StringVar(&options.comment, "comment", "", "comment stored with the decision")
+	cmd.MarkFlagRequired("id")
+
+	return cmd
+}
+
+func requestDecision(options requestOptions, decision string) error {
+	var request workflow.Decision
+	api := workflow.New(curl())
+
+	err := remarshal(map[string]string{
+		"decision": decision,
+		"message":  options.comment,
+	}, &request)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range strings.Split(options.requestID, ",") {
+		err := api.MakeDecisionOnRequest(id, request)
+		if err != nil {
+			return fmt.Errorf("request %s: %w", id, err)
+		}
+	}
+
+	return nil
+}
+
 //
 //
 func requestSearchCmd() *cobra.Command {
@@ -279,3 +383,186 @@ func requestSearch(options requestOptions, args []string) error {
 
 	return stdout(requests)
 }
+
+//
+//
+func requestRemindCmd() *cobra.Command {
+	options := requestOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "remind",
+		Short: "Remind approvers of stale pending requests",
+		Long: `Find requests pending approval longer than --pending-older-than and notify the
+approver roles through webhook. Requests pending longer than --escalate-after are
+escalated to the fallback role given by --escalate-to. Without --notify-webhook
+stale requests are only listed. The command is meant to be run periodically, e.g. from cron.`,
+		Example: `
+	privx-cli requests remind [access flags] --pending-older-than 24h
+	privx-cli requests remind [access flags] --pending-older-than 24h --notify-webhook https://chat.example.com/hooks/approvals
+	privx-cli requests remind [access flags] --notify-webhook <URL> --escalate-after 3d --escalate-to security-oncall
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return requestRemind(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.olderThan, "pending-older-than", "24h", "remind of requests pending longer than the duration (24h, 2d)")
+	flags.StringVar(&options.webhook, "notify-webhook", "", "post reminders to webhook URL")
+	flags.StringVar(&options.escalateAfter, "escalate-after", "", "escalate requests pending longer than the duration")
+	flags.StringVar(&options.escalateTo, "escalate-to", "", "fallback role of escalated requests")
+
+	return cmd
+}
+
+// staleRequest is a request waiting for approval too long
+type staleRequest struct {
+	ID          string   `json:"id"`
+	Requester   string   `json:"requester"`
+	TargetRoles []string `json:"target_roles"`
+	Created     string   `json:"created"`
+	Pending     string   `json:"pending"`
+	Approvers   []string `json:"approvers"`
+	EscalatedTo string   `json:"escalated_to,omitempty"`
+	Notified    bool     `json:"notified"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// pendingRequestView is the part of workflow request needed for reminders
+type pendingRequestView struct {
+	ID            string `json:"id"`
+	Created       string `json:"created"`
+	Decision      string `json:"decision"`
+	RequesterName string `json:"requester_name"`
+	RequesterID   string `json:"requester_id"`
+	TargetRoles   []struct {
+		Name string `json:"name"`
+	} `json:"target_roles"`
+	Workflow struct {
+		Steps []struct {
+			Approvers []struct {
+				Role struct {
+					Name string `json:"name"`
+				} `json:"role"`
+			} `json:"approvers"`
+		} `json:"steps"`
+	} `json:"workflow"`
+}
+
+func requestRemind(options requestOptions) error {
+	olderThan, err := parseDurationFlag(options.olderThan)
+	if err != nil {
+		return err
+	}
+
+	var escalateAfter time.Duration
+	if options.escalateAfter != "" {
+		if options.escalateTo == "" {
+			return fmt.Errorf("--escalate-after requires --escalate-to")
+		}
+		if escalateAfter, err = parseDurationFlag(options.escalateAfter); err != nil {
+			return err
+		}
+	}
+
+	api := workflow.New(curl())
+	limit := 100
+	now := time.Now()
+	stale := []staleRequest{}
+
+	for offset := 0; ; offset += limit {
+		page, err := api.Requests(offset, limit, "")
+		if err != nil {
+			return err
+		}
+
+		var requests []pendingRequestView
+		if err := remarshal(page, &requests); err != nil {
+			return err
+		}
+
+		for _, request := range requests {
+			if !strings.EqualFold(request.Decision, "PENDING") {
+				continue
+			}
+
+			created, err := time.Parse(time.RFC3339, request.Created)
+			if err != nil || now.Sub(created) < olderThan {
+				continue
+			}
+			pending := now.Sub(created)
+
+			reminder := staleRequest{
+				ID:          request.ID,
+				Requester:   request.RequesterName,
+				TargetRoles: []string{},
+				Created:     request.Created,
+				Pending:     pending.Round(time.Minute).String(),
+				Approvers:   []string{},
+			}
+			if reminder.Requester == "" {
+				reminder.Requester = request.RequesterID
+			}
+			for _, role := range request.TargetRoles {
+				reminder.TargetRoles = append(reminder.TargetRoles, role.Name)
+			}
+			for _, step := range request.Workflow.Steps {
+				for _, approver := range step.Approvers {
+					reminder.Approvers = append(reminder.Approvers, approver.Role.Name)
+				}
+			}
+			if escalateAfter > 0 && pending >= escalateAfter {
+				reminder.EscalatedTo = options.escalateTo
+			}
+
+			stale = append(stale, reminder)
+		}
+
+		if len(requests) < limit {
+			break
+		}
+	}
+
+	failed := 0
+	if options.webhook != "" {
+		for i, reminder := range stale {
+			err := notifyWebhook(options.webhook, reminder.text(), reminder)
+			if interrupted() != nil {
+				break
+			}
+			if err != nil {
+				stale[i].Error = err.Error()
+				failed++
+				continue
+			}
+			stale[i].Notified = true
+		}
+	}
+
+	if err := stdout(stale); err != nil {
+		return err
+	}
+
+	if err := interrupted(); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d reminders failed", failed, len(stale))
+	}
+
+	return nil
+}
+
+func (reminder staleRequest) text() string {
+	text := fmt.Sprintf("Request %s by %s for role %s has been pending approval for %s.",
+		reminder.ID, reminder.Requester, strings.Join(reminder.TargetRoles, ", "), reminder.Pending)
+
+	if reminder.EscalatedTo != "" {
+		return text + fmt.Sprintf(" Escalated to %s, approvers %s have not responded.",
+			reminder.EscalatedTo, strings.Join(reminder.Approvers, ", "))
+	}
+
+	return text + fmt.Sprintf(" Approvers: %s.", strings.Join(reminder.Approvers, ", "))
+}
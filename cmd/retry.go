@@ -0,0 +1,76 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+)
+
+// retryMaxWait caps exponential backoff between attempts
+const retryMaxWait = 30 * time.Second
+
+var (
+	retries   int
+	retryWait time.Duration
+)
+
+func init() {
+	rand.Seed(time.Now().UnixNano())
+	rootCmd.PersistentFlags().IntVar(&retries, "retries", 3, "retries of API calls failing with 429, 5xx or network error")
+	rootCmd.PersistentFlags().DurationVar(&retryWait, "retry-wait", time.Second, "initial wait between retries, doubled on each retry")
+}
+
+// retry repeats API call on transient failure with exponential backoff
+// and jitter. POST is not idempotent, it is repeated only when the server
+// rejected the request without processing it.
+func (r *request) retry(method string, call func() (http.Header, error)) (http.Header, error) {
+	wait := retryWait
+
+	for attempt := 0; ; attempt++ {
+		head, err := call()
+		if err == nil || attempt >= retries || !r.transient(method, err) {
+			return head, err
+		}
+
+		// full jitter spreads retries of parallel bulk operations
+		delay := wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+		info("%s %s failed, retrying in %s: %s", method, r.path, delay.Round(time.Millisecond), err)
+
+		select {
+		case <-runContext.Done():
+			return head, err
+		case <-time.After(delay):
+		}
+
+		if wait *= 2; wait > retryMaxWait {
+			wait = retryMaxWait
+		}
+	}
+}
+
+func (r *request) transient(method string, err error) bool {
+	status := statusCode(err)
+	if status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable {
+		return true
+	}
+
+	if method == http.MethodPost && !r.search() {
+		return false
+	}
+
+	switch status {
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -7,18 +7,48 @@
 package cmd
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/SSHcom/privx-cli/pkg/privxops"
 	"github.com/SSHcom/privx-sdk-go/api/rolestore"
 	"github.com/spf13/cobra"
 )
 
 type roleOptions struct {
-	roleID    string
-	roleName  string
-	tokenCode string
-	ttl       int
+	roleID         string
+	roleName       string
+	sourceID       string
+	attributes     string
+	fileName       string
+	tokenCode      string
+	externalID     string
+	session        string
+	region         string
+	chain          []string
+	expiring       string
+	webhook        string
+	format         string
+	userIDs        string
+	grantTTL       string
+	since          string
+	ttl            int
+	parallel       int
+	prune          bool
+	history        bool
+	nonInteractive bool
 }
 
 func init() {
@@ -28,19 +58,24 @@ func init() {
 //
 //
 func roleListCmd() *cobra.Command {
+	options := pagination{}
+
 	cmd := &cobra.Command{
 		Use:   "roles",
 		Short: "List and manage PrivX roles",
 		Long:  `List and manage PrivX roles`,
 		Example: `
 	privx-cli roles [access flags]
+	privx-cli roles [access flags] --sortkey name --offset 20 --limit 10
 		`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return roleList()
+			return roleList(options)
 		},
 	}
 
+	options.register(cmd)
+
 	cmd.AddCommand(roleCreateCmd())
 	cmd.AddCommand(roleShowCmd())
 	cmd.AddCommand(roleDeleteCmd())
@@ -48,11 +83,14 @@ func roleListCmd() *cobra.Command {
 	cmd.AddCommand(rolesMemberListCmd())
 	cmd.AddCommand(roleResolveCmd())
 	cmd.AddCommand(awsTokenShowCmd())
+	cmd.AddCommand(roleSimulateMappingCmd())
+	cmd.AddCommand(roleEvaluateCmd())
+	cmd.AddCommand(roleCatalogCmd())
 
 	return cmd
 }
 
-func roleList() error {
+func roleList(options pagination) error {
 	api := rolestore.New(curl())
 
 	roles, err := api.Roles()
@@ -60,7 +98,12 @@ func roleList() error {
 		return err
 	}
 
-	return stdout(roles)
+	page, err := options.Apply(roles)
+	if err != nil {
+		return err
+	}
+
+	return stdout(page)
 }
 
 //
@@ -72,6 +115,9 @@ func roleCreateCmd() *cobra.Command {
 		Long:  `Create new role`,
 		Example: `
 	privx-cli roles create [access flags] JSON-FILE
+	privx-cli roles create [access flags] --dir roles/
+	privx-cli roles create [access flags] "roles/*.json"
+	jq '.name = "ops"' role.json | privx-cli roles create [access flags] -
 		`,
 		Args:         cobra.ExactArgs(1),
 		SilenceUsage: true,
@@ -80,7 +126,7 @@ func roleCreateCmd() *cobra.Command {
 		},
 	}
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func roleCreate(args []string) error {
@@ -108,9 +154,11 @@ func roleShowCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "show",
 		Short: "Get role by ID",
-		Long:  `Get role by ID`,
+		Long: `Get role by ID. With --history the changes of the role are listed from audit
+events, who changed the role and when, with diff between recorded versions.`,
 		Example: `
 	privx-cli roles show [access flags] --id <ROLE-ID>
+	privx-cli roles show [access flags] --id <ROLE-ID> --history --since 30d
 		`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -120,12 +168,18 @@ func roleShowCmd() *cobra.Command {
 
 	flags := cmd.Flags()
 	flags.StringVar(&options.roleID, "id", "", "role ID")
+	flags.BoolVar(&options.history, "history", false, "list changes of the role")
+	flags.StringVar(&options.since, "since", "90d", "start of history, timestamp or duration ago")
 	cmd.MarkFlagRequired("id")
 
 	return cmd
 }
 
 func roleShow(options roleOptions) error {
+	if options.history {
+		return roleHistory(options)
+	}
+
 	api := rolestore.New(curl())
 
 	role, err := api.Role(options.roleID)
@@ -136,6 +190,99 @@ func roleShow(options roleOptions) error {
 	return stdout(role)
 }
 
+// roleChange is a change of role recorded by audit event
+type roleChange struct {
+	Time   string   `json:"time"`
+	Event  string   `json:"event"`
+	UserID string   `json:"user_id,omitempty"`
+	User   string   `json:"user,omitempty"`
+	Diff   []string `json:"diff,omitempty"`
+}
+
+func roleHistory(options roleOptions) error {
+	since, err := parseTimeFlag(options.since)
+	if err != nil {
+		return err
+	}
+
+	events, err := searchEvents(eventFilter{since: since}, nil)
+	if err != nil {
+		return err
+	}
+
+	changes := []roleChange{}
+	var previous interface{}
+
+	for _, event := range events {
+		name := strings.ToUpper(fmt.Sprint(firstOf(event, "event", "event_name")))
+		if !strings.HasPrefix(name, "ROLE_") || !referencesID(event, options.roleID) {
+			continue
+		}
+
+		change := roleChange{Time: fmt.Sprint(event["timestamp"]), Event: name}
+		change.UserID, _ = event["user_id"].(string)
+		change.User, _ = firstOf(event, "username", "user_name", "user").(string)
+
+		// events recording the role object are diffed against the
+		// previous recorded version
+		if version := firstOf(event, "role", "new_role", "data"); version != nil {
+			if previous != nil {
+				if change.Diff, err = roleVersionDiff(previous, version); err != nil {
+					return err
+				}
+			}
+			previous = version
+		}
+
+		changes = append(changes, change)
+	}
+
+	if len(changes) == 0 {
+		info("no changes of role %s are recorded since %s", options.roleID, since.Format(time.RFC3339))
+	}
+
+	return stdout(changes)
+}
+
+func roleVersionDiff(before, after interface{}) ([]string, error) {
+	a, err := privxops.DiffLines(before)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := privxops.DiffLines(after)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	privxops.WriteDiff(&buf, privxops.UnifiedDiff(a, b, 3), false)
+
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n"), nil
+}
+
+// referencesID tells if any value of object equals to ID
+func referencesID(value interface{}, id string) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, item := range v {
+			if referencesID(item, id) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if referencesID(item, id) {
+				return true
+			}
+		}
+	case string:
+		return v == id
+	}
+
+	return false
+}
+
 //
 //
 func roleDeleteCmd() *cobra.Command {
@@ -144,9 +291,12 @@ func roleDeleteCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "delete",
 		Short: "Delete role",
-		Long:  `Delete role. Role ID's are separated by commas when using multiple values, see example`,
+		Long: `Delete role. Role ID's are separated by commas when using multiple values, see example.
+Roles are deleted concurrently, failures do not stop the deletion and outcome
+of each ID is printed.`,
 		Example: `
 	privx-cli roles delete [access flags] --id <ROLE-ID>,<ROLE-ID>
+	privx-cli roles delete [access flags] --id <ROLE-ID>,<ROLE-ID> --parallel 8 --output table
 		`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -156,6 +306,7 @@ func roleDeleteCmd() *cobra.Command {
 
 	flags := cmd.Flags()
 	flags.StringVar(&options.roleID, "id", "", "role ID")
+	flags.IntVar(&options.parallel, "parallel", 4, "number of concurrent deletions")
 	cmd.MarkFlagRequired("id")
 
 	return cmd
@@ -164,16 +315,7 @@ func roleDeleteCmd() *cobra.Command {
 func roleDelete(options roleOptions) error {
 	api := rolestore.New(curl())
 
-	for _, id := range strings.Split(options.roleID, ",") {
-		err := api.DeleteRole(id)
-		if err != nil {
-			return err
-		} else {
-			fmt.Println(id)
-		}
-	}
-
-	return nil
+	return bulkDelete(strings.Split(options.roleID, ","), options.parallel, api.DeleteRole)
 }
 
 //
@@ -187,6 +329,7 @@ func roleUpdateCmd() *cobra.Command {
 		Long:  `Update role`,
 		Example: `
 	privx-cli roles update [access flags] JSON-FILE --id <ROLE-ID>
+	privx-cli roles update [access flags] --dir roles/
 		`,
 		Args:         cobra.ExactArgs(1),
 		SilenceUsage: true,
@@ -199,7 +342,7 @@ func roleUpdateCmd() *cobra.Command {
 	flags.StringVar(&options.roleID, "id", "", "role ID")
 	cmd.MarkFlagRequired("id")
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func roleUpdate(options roleOptions, args []string) error {
@@ -211,6 +354,15 @@ func roleUpdate(options roleOptions, args []string) error {
 		return err
 	}
 
+	current, err := api.Role(options.roleID)
+	if err != nil {
+		return err
+	}
+
+	if err := confirmUpdate(current, &updateRole); err != nil {
+		return err
+	}
+
 	err = api.UpdateRole(options.roleID, &updateRole)
 	if err != nil {
 		return err
@@ -227,9 +379,14 @@ func rolesMemberListCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "members",
 		Short: "Get members of PrivX role",
-		Long:  `Get members of PrivX role`,
+		Long: `Get members of PrivX role. With --expiring-within only time restricted grants
+ending within the window are listed, optionally posted to --notify-webhook.`,
 		Example: `
 	privx-cli roles members [access flags] UID ...
+	privx-cli roles members [access flags] --id <ROLE-ID> --expiring-within 7d
+	privx-cli roles members [access flags] --id <ROLE-ID> --expiring-within 7d --notify-webhook https://hooks.example.com/T0
+	privx-cli roles members add [access flags] --id <ROLE-ID> --user <UID>,<UID> --ttl 8h
+	privx-cli roles members remove [access flags] --id <ROLE-ID> --user <UID>
 		`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -239,12 +396,25 @@ func rolesMemberListCmd() *cobra.Command {
 
 	flags := cmd.Flags()
 	flags.StringVar(&options.roleID, "id", "", "role ID")
+	flags.StringVar(&options.expiring, "expiring-within", "", "list grants ending within duration (e.g. 7d, 12h)")
+	flags.StringVar(&options.webhook, "notify-webhook", "", "post expiring grants to webhook URL")
 	cmd.MarkFlagRequired("id")
 
+	cmd.AddCommand(roleMemberAddCmd())
+	cmd.AddCommand(roleMemberRemoveCmd())
+	cmd.AddCommand(roleMemberReconcileCmd())
+
 	return cmd
 }
 
 func roleMemberList(options roleOptions) error {
+	if options.expiring != "" {
+		return roleMemberExpiring(options)
+	}
+	if options.webhook != "" {
+		return fmt.Errorf("--notify-webhook requires --expiring-within")
+	}
+
 	api := rolestore.New(curl())
 	members := []rolestore.User{}
 
@@ -259,6 +429,433 @@ func roleMemberList(options roleOptions) error {
 	return stdout(members)
 }
 
+// expiringGrant is time restricted role grant ending soon
+type expiringGrant struct {
+	UserID   string    `json:"user_id"`
+	Username string    `json:"username"`
+	RoleID   string    `json:"role_id"`
+	RoleName string    `json:"role_name"`
+	GrantEnd time.Time `json:"grant_end"`
+}
+
+func roleMemberExpiring(options roleOptions) error {
+	window, err := parseDurationFlag(options.expiring)
+	if err != nil {
+		return err
+	}
+
+	api := rolestore.New(curl())
+	deadline := time.Now().Add(window)
+	grants := []expiringGrant{}
+
+	for _, role := range strings.Split(options.roleID, ",") {
+		members, err := api.GetRoleMembers(role)
+		if err != nil {
+			return err
+		}
+
+		for _, user := range members {
+			for _, ref := range user.Roles {
+				// grant_end is empty for permanent grants
+				if ref.ID != role || ref.GrantEnd == "" {
+					continue
+				}
+				end, err := time.Parse(time.RFC3339, ref.GrantEnd)
+				if err != nil {
+					return fmt.Errorf("user %s: invalid grant_end %q: %w", user.ID, ref.GrantEnd, err)
+				}
+				if end.After(deadline) {
+					continue
+				}
+				grants = append(grants, expiringGrant{
+					UserID:   user.ID,
+					Username: user.Principal,
+					RoleID:   ref.ID,
+					RoleName: ref.Name,
+					GrantEnd: end,
+				})
+			}
+		}
+	}
+
+	sort.Slice(grants, func(i, j int) bool {
+		return grants[i].GrantEnd.Before(grants[j].GrantEnd)
+	})
+
+	if options.webhook != "" && len(grants) > 0 {
+		if err := notifyWebhook(options.webhook, fmt.Sprintf(
+			"%d PrivX role grant(s) expire within %s", len(grants), options.expiring), grants); err != nil {
+			return err
+		}
+	}
+
+	return stdout(grants)
+}
+
+//
+//
+func roleMemberAddCmd() *cobra.Command {
+	options := roleOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "add",
+		Short: "Grant role to users",
+		Long: `Grant role to users. User ID's are separated by commas when using multiple values.
+With --ttl the grant is temporary, PrivX revokes it when the period ends.`,
+		Example: `
+	privx-cli roles members add [access flags] --id <ROLE-ID> --user <UID>,<UID>
+	privx-cli roles members add [access flags] --id <ROLE-ID> --user <UID> --ttl 8h
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return roleMemberAdd(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.roleID, "id", "", "role ID")
+	flags.StringVar(&options.userIDs, "user", "", "comma separated user IDs")
+	flags.StringVar(&options.grantTTL, "ttl", "", "temporary grant period (e.g. 8h, 7d)")
+	cmd.MarkFlagRequired("id")
+	cmd.MarkFlagRequired("user")
+
+	return cmd
+}
+
+// roleGrant is role membership changed by the command
+type roleGrant struct {
+	UserID   string     `json:"user_id"`
+	RoleID   string     `json:"role_id"`
+	GrantEnd *time.Time `json:"grant_end,omitempty"`
+}
+
+func roleMemberAdd(options roleOptions) error {
+	var ttl time.Duration
+	if options.grantTTL != "" {
+		var err error
+		if ttl, err = parseDurationFlag(options.grantTTL); err != nil {
+			return err
+		}
+		if ttl <= 0 {
+			return fmt.Errorf("invalid --ttl: %s", options.grantTTL)
+		}
+	}
+
+	api := rolestore.New(curl())
+	grants := []roleGrant{}
+
+	for _, uid := range strings.Split(options.userIDs, ",") {
+		grant := roleGrant{UserID: uid, RoleID: options.roleID}
+
+		if ttl == 0 {
+			if err := api.GrantUserRole(uid, options.roleID); err != nil {
+				return fmt.Errorf("user %s: %w", uid, err)
+			}
+		} else {
+			end, err := grantTemporaryRole(uid, options.roleID, ttl)
+			if err != nil {
+				return fmt.Errorf("user %s: %w", uid, err)
+			}
+			grant.GrantEnd = &end
+		}
+
+		grants = append(grants, grant)
+	}
+
+	return stdout(grants)
+}
+
+// grantTemporaryRole replaces explicit grant of the role with time
+// restricted one, other roles of the user are kept as they are
+func grantTemporaryRole(uid, roleID string, ttl time.Duration) (time.Time, error) {
+	endpoint := "/role-store/api/v1/users/" + url.PathEscape(uid) + "/roles"
+
+	var current struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+	if _, err := uncachedCurl().URL(endpoint).Get(&current); err != nil {
+		return time.Time{}, err
+	}
+
+	now := time.Now().UTC()
+	end := now.Add(ttl)
+	roles := []map[string]interface{}{}
+	for _, role := range current.Items {
+		if role["id"] == roleID {
+			continue
+		}
+		if explicit, _ := role["explicit"].(bool); explicit {
+			roles = append(roles, role)
+		}
+	}
+	roles = append(roles, map[string]interface{}{
+		"id":          roleID,
+		"explicit":    true,
+		"grant_type":  "TIME_RESTRICTED",
+		"grant_start": now.Format(time.RFC3339),
+		"grant_end":   end.Format(time.RFC3339),
+	})
+
+	if _, err := curl().URL(endpoint).Put(roles); err != nil {
+		return time.Time{}, apiUnsupported(err, "temporary role grant")
+	}
+
+	return end, nil
+}
+
+//
+//
+func roleMemberRemoveCmd() *cobra.Command {
+	options := roleOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "remove",
+		Short: "Revoke role from users",
+		Long: `Revoke explicit grant of role from users. User ID's are separated by commas when
+using multiple values. Grants received through directory mapping are not affected.`,
+		Example: `
+	privx-cli roles members remove [access flags] --id <ROLE-ID> --user <UID>,<UID>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return roleMemberRemove(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.roleID, "id", "", "role ID")
+	flags.StringVar(&options.userIDs, "user", "", "comma separated user IDs")
+	cmd.MarkFlagRequired("id")
+	cmd.MarkFlagRequired("user")
+
+	return cmd
+}
+
+func roleMemberRemove(options roleOptions) error {
+	api := rolestore.New(curl())
+
+	for _, uid := range strings.Split(options.userIDs, ",") {
+		if err := api.RevokeUserRole(uid, options.roleID); err != nil {
+			return fmt.Errorf("user %s: %w", uid, err)
+		}
+		fmt.Println(uid)
+	}
+
+	return nil
+}
+
+// mfaAttempts limits prompts of invalid MFA code
+const mfaAttempts = 3
+
+func promptMFA() (string, error) {
+	fmt.Fprint(os.Stderr, "MFA code: ")
+	code, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return "", errors.New("MFA code is required")
+	}
+
+	return code, nil
+}
+
+// notifyWebhook posts JSON message to webhook. The text field makes
+// the message readable by common chat webhooks.
+func notifyWebhook(url, text string, data interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"text": text,
+		"data": data,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(runContext, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook %s failed: %s", url, resp.Status)
+	}
+
+	return nil
+}
+
+//
+//
+func roleMemberReconcileCmd() *cobra.Command {
+	options := roleOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "reconcile",
+		Short: "Reconcile explicit role members with CSV file",
+		Long: `Reconcile explicit role members with CSV file. The first column of the file is user ID,
+an optional header row is skipped. Users missing from the role are granted the role.
+With --prune, explicit grants of users not listed in the file are revoked.
+Members received through directory mapping are never touched.`,
+		Example: `
+	privx-cli roles members reconcile [access flags] --id <ROLE-ID> --file members.csv
+	privx-cli roles members reconcile [access flags] --id <ROLE-ID> --file members.csv --prune
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return roleMemberReconcile(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.roleID, "id", "", "role ID")
+	flags.StringVar(&options.fileName, "file", "", "CSV file of user IDs")
+	flags.BoolVar(&options.prune, "prune", false, "revoke explicit grants of users missing from the file")
+	cmd.MarkFlagRequired("id")
+	cmd.MarkFlagRequired("file")
+
+	return cmd
+}
+
+type roleMemberReconciliation struct {
+	Added     []privxops.ItemResult `json:"added"`
+	Removed   []privxops.ItemResult `json:"removed"`
+	Unchanged int                   `json:"unchanged"`
+}
+
+// memberChange is the outcome of granting or revoking role of one user
+func memberChange(uid string, err error) privxops.ItemResult {
+	result := privxops.ItemResult{ID: uid, Status: "ok"}
+	switch err = dryRunResult(err); {
+	case errors.Is(err, errDryRun):
+		result.Status = "dry-run"
+	case err != nil:
+		result.Status, result.Error = "failed", err.Error()
+	}
+	return result
+}
+
+func roleMemberReconcile(options roleOptions) error {
+	wanted, err := readMembersCSV(options.fileName)
+	if err != nil {
+		return err
+	}
+
+	api := rolestore.New(uncachedCurl())
+	members, err := api.GetRoleMembers(options.roleID)
+	if err != nil {
+		return err
+	}
+
+	var view []struct {
+		ID    string `json:"id"`
+		Roles []struct {
+			ID       string `json:"id"`
+			Explicit bool   `json:"explicit"`
+		} `json:"roles"`
+	}
+	if err := remarshal(members, &view); err != nil {
+		return err
+	}
+
+	explicit := map[string]bool{}
+	for _, member := range view {
+		for _, role := range member.Roles {
+			if role.ID == options.roleID && role.Explicit {
+				explicit[member.ID] = true
+			}
+		}
+	}
+
+	failed := 0
+	result := roleMemberReconciliation{Added: []privxops.ItemResult{}, Removed: []privxops.ItemResult{}}
+	for _, uid := range wanted {
+		if explicit[uid] {
+			result.Unchanged++
+			continue
+		}
+
+		change := memberChange(uid, api.GrantUserRole(uid, options.roleID))
+		if change.Error != "" {
+			failed++
+		}
+		result.Added = append(result.Added, change)
+	}
+
+	if options.prune {
+		keep := map[string]bool{}
+		for _, uid := range wanted {
+			keep[uid] = true
+		}
+
+		for uid := range explicit {
+			if keep[uid] {
+				continue
+			}
+
+			change := memberChange(uid, api.RevokeUserRole(uid, options.roleID))
+			if change.Error != "" {
+				failed++
+			}
+			result.Removed = append(result.Removed, change)
+		}
+	}
+
+	if err := stdout(result); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d role member changes failed", failed, len(result.Added)+len(result.Removed))
+	}
+
+	return nil
+}
+
+// readMembersCSV returns unique user IDs from the first column of the file
+func readMembersCSV(name string) ([]string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	seen := map[string]bool{}
+	uids := []string{}
+	for line := 0; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		uid := strings.TrimSpace(record[0])
+		if uid == "" || seen[uid] {
+			continue
+		}
+		if line == 0 && (strings.EqualFold(uid, "id") || strings.EqualFold(uid, "user_id")) {
+			continue
+		}
+
+		seen[uid] = true
+		uids = append(uids, uid)
+	}
+
+	return uids, nil
+}
+
 //
 //
 func roleResolveCmd() *cobra.Command {
@@ -304,9 +901,14 @@ func awsTokenShowCmd() *cobra.Command {
 		Use:   "aws-token",
 		Short: "Get an AWS token for a role",
 		Long: `Get an AWS token for a role. Return 403 on an initial request if the AWS role has multi-factor authentication enabled.
-Subsequent request must contain MFA as a query parameter. Return 403 if the user does not have the role.`,
+Subsequent request must contain MFA as a query parameter. Return 403 if the user does not have the role.
+Interactive users are prompted for the MFA code when it is required, otherwise the command
+exits with status 4.
+With --assume-chain each AWS token of the role is used to assume the given role ARNs in order,
+credentials of the last role are returned for each token.`,
 		Example: `
 	privx-cli roles aws-token [access flags] --id <ROLE-ID>
+	privx-cli roles aws-token [access flags] --id <ROLE-ID> --assume-chain <ROLE-ARN> --external-id <EXTERNAL-ID>
 		`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -317,7 +919,12 @@ Subsequent request must contain MFA as a query parameter. Return 403 if the user
 	flags := cmd.Flags()
 	flags.StringVar(&options.roleID, "id", "", "role ID")
 	flags.StringVar(&options.tokenCode, "mfa", "", "multi-factor-authentication code")
+	flags.BoolVar(&options.nonInteractive, "non-interactive", false, "do not prompt for MFA code")
 	flags.IntVar(&options.ttl, "ttl", 50, "max time validity for the token")
+	flags.StringArrayVar(&options.chain, "assume-chain", []string{}, "downstream AWS role ARN to assume with the token (repeatable)")
+	flags.StringVar(&options.externalID, "external-id", "", "external ID for assuming downstream role")
+	flags.StringVar(&options.session, "session-name", "privx-cli", "session name for assuming downstream role")
+	flags.StringVar(&options.region, "region", "", "AWS region of STS endpoint (default global endpoint)")
 	cmd.MarkFlagRequired("id")
 
 	return cmd
@@ -327,9 +934,462 @@ func awsTokenShow(options roleOptions) error {
 	api := rolestore.New(curl())
 
 	token, err := api.AWSToken(options.roleID, options.tokenCode, options.ttl)
+	for attempt := 0; err != nil && attempt < mfaAttempts; attempt++ {
+		if options.nonInteractive || !isTerminal(os.Stdin) || !isMFAChallenge(err) {
+			break
+		}
+
+		if options.tokenCode, err = promptMFA(); err != nil {
+			return err
+		}
+		token, err = api.AWSToken(options.roleID, options.tokenCode, options.ttl)
+	}
+	if err != nil {
+		return mfaRequired(err)
+	}
+
+	if len(options.chain) == 0 {
+		return stdout(token)
+	}
+	if len(token) == 0 {
+		return fmt.Errorf("role %s has no AWS token to assume roles with", options.roleID)
+	}
+
+	// each AWS role linked to the PrivX role has its own token
+	chained := []awsCredentials{}
+	for _, t := range token {
+		creds := awsCredentials{
+			AccessKeyID:     t.AccessKeyID,
+			SecretAccessKey: t.SecretAccessKey,
+			SessionToken:    t.SessionToken,
+			Expires:         t.Expires,
+		}
+
+		for _, arn := range options.chain {
+			creds, err = assumeRole(creds, arn, options.externalID, options.session,
+				options.region, options.ttl)
+			if err != nil {
+				return err
+			}
+		}
+		chained = append(chained, creds)
+	}
+
+	return stdout(chained)
+}
+
+//
+//
+func roleSimulateMappingCmd() *cobra.Command {
+	options := roleOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "simulate-mapping",
+		Short: "Simulate role mapping for a hypothetical directory user",
+		Long: `Evaluate the source rules of all roles against attributes and groups of
+a hypothetical directory user and list the roles the user would receive.
+The attribute file is JSON object with "groups" list and "attributes" map.`,
+		Example: `
+	privx-cli roles simulate-mapping [access flags] --source <SOURCE-ID> --attributes <JSON-FILE>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return roleSimulateMapping(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.sourceID, "source", "", "source ID")
+	flags.StringVar(&options.attributes, "attributes", "", "JSON file with user attributes and groups")
+	cmd.MarkFlagRequired("source")
+	cmd.MarkFlagRequired("attributes")
+
+	return cmd
+}
+
+type mappingUser struct {
+	Groups     []string          `json:"groups"`
+	Attributes map[string]string `json:"attributes"`
+}
+
+type mappingRule struct {
+	Type    string        `json:"type"`
+	Match   string        `json:"match"`
+	Source  string        `json:"source"`
+	Pattern string        `json:"search_string"`
+	Rules   []mappingRule `json:"rules"`
+}
+
+type mappingRole struct {
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	SourceRules mappingRule `json:"source_rules"`
+}
+
+func roleSimulateMapping(options roleOptions) error {
+	var user mappingUser
+	err := decodeJSON(options.attributes, &user)
+	if err != nil {
+		return err
+	}
+
+	api := rolestore.New(curl())
+	roles, err := api.Roles()
+	if err != nil {
+		return err
+	}
+
+	var mapping []mappingRole
+	if err := remarshal(roles, &mapping); err != nil {
+		return err
+	}
+
+	granted := []rolestore.RoleRef{}
+	for _, role := range mapping {
+		ok, err := role.SourceRules.eval(options.sourceID, user)
+		if err != nil {
+			return fmt.Errorf("role %s: %w", role.Name, err)
+		}
+		if ok {
+			granted = append(granted, rolestore.RoleRef{ID: role.ID, Name: role.Name})
+		}
+	}
+
+	return stdout(granted)
+}
+
+// eval returns true if rule tree grants the role to the user of the source.
+// Rule patterns are matched against user's groups and attribute values.
+func (rule mappingRule) eval(source string, user mappingUser) (bool, error) {
+	if rule.Type == "RULE" {
+		if rule.Source != source || rule.Pattern == "" {
+			return false, nil
+		}
+
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return false, err
+		}
+
+		for _, group := range user.Groups {
+			if re.MatchString(group) {
+				return true, nil
+			}
+		}
+		for _, value := range user.Attributes {
+			if re.MatchString(value) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	if len(rule.Rules) == 0 {
+		return false, nil
+	}
+
+	for _, sub := range rule.Rules {
+		ok, err := sub.eval(source, user)
+		if err != nil {
+			return false, err
+		}
+		if ok && rule.Match != "ALL" {
+			return true, nil
+		}
+		if !ok && rule.Match == "ALL" {
+			return false, nil
+		}
+	}
+
+	return rule.Match == "ALL", nil
+}
+
+// evaluatedRole is a role of user with the reason the user has it
+type evaluatedRole struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Grant       string   `json:"grant"`
+	GrantType   string   `json:"grant_type,omitempty"`
+	Source      string   `json:"source,omitempty"`
+	Rule        string   `json:"rule,omitempty"`
+	Matched     string   `json:"matched,omitempty"`
+	Until       string   `json:"until,omitempty"`
+	Permissions []string `json:"permissions"`
+}
+
+// effectivePermission is a permission of user with roles granting it
+type effectivePermission struct {
+	Permission string   `json:"permission"`
+	Roles      []string `json:"roles"`
+}
+
+type roleEvaluation struct {
+	UserID      string                `json:"user_id"`
+	User        string                `json:"user"`
+	Roles       []evaluatedRole       `json:"roles"`
+	Permissions []effectivePermission `json:"permissions"`
+}
+
+//
+//
+func roleEvaluateCmd() *cobra.Command {
+	options := roleOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "evaluate",
+		Short: "Evaluate roles and effective permissions of user",
+		Long: `Resolve all roles of user and print the effective permission set. Each role
+states why the user has it: explicit grant, floating or time restricted grant,
+or source rule of the role with the directory attribute that matched.`,
+		Example: `
+	privx-cli roles evaluate [access flags] --user <USER-ID>
+	privx-cli roles evaluate [access flags] --user <USER-ID> -o table --query roles
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return roleEvaluate(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.userIDs, "user", "", "user ID")
+	cmd.MarkFlagRequired("user")
+
+	return cmd
+}
+
+func roleEvaluate(options roleOptions) error {
+	api := rolestore.New(curl())
+
+	user, err := api.User(options.userIDs)
+	if err != nil {
+		return err
+	}
+
+	roles, err := api.Roles()
+	if err != nil {
+		return err
+	}
+
+	var definitions []struct {
+		mappingRole
+		Permissions []string `json:"permissions"`
+	}
+	if err := remarshal(roles, &definitions); err != nil {
+		return err
+	}
+	byID := map[string]int{}
+	for i, role := range definitions {
+		byID[role.ID] = i
+	}
+
+	directoryUser := directoryAttributes(user)
+
+	result := roleEvaluation{
+		UserID:      user.ID,
+		User:        user.Principal,
+		Roles:       []evaluatedRole{},
+		Permissions: []effectivePermission{},
+	}
+	granted := map[string][]string{}
+
+	for _, ref := range user.Roles {
+		role := evaluatedRole{
+			ID:          ref.ID,
+			Name:        ref.Name,
+			Grant:       "explicit",
+			Permissions: []string{},
+		}
+
+		if ref.GrantType != "" && ref.GrantType != "PERMANENT" {
+			role.GrantType = ref.GrantType
+			role.Until = ref.GrantEnd
+		}
+
+		i, ok := byID[ref.ID]
+		if ok {
+			role.Permissions = definitions[i].Permissions
+		}
+
+		switch {
+		case ref.GrantType == "FLOATING":
+			role.Grant = "floating"
+		case !ref.Explicit:
+			role.Grant = "implicit"
+			if ok {
+				if rule, matched := definitions[i].SourceRules.explain(user.Source, directoryUser); rule != nil {
+					role.Grant = "source rule"
+					role.Source = rule.Source
+					role.Rule = rule.Pattern
+					role.Matched = matched
+				}
+			}
+		}
+
+		for _, permission := range role.Permissions {
+			granted[permission] = append(granted[permission], role.Name)
+		}
+		result.Roles = append(result.Roles, role)
+	}
+
+	for permission, names := range granted {
+		result.Permissions = append(result.Permissions, effectivePermission{Permission: permission, Roles: names})
+	}
+	sort.Slice(result.Permissions, func(i, j int) bool {
+		return result.Permissions[i].Permission < result.Permissions[j].Permission
+	})
+
+	return stdout(result)
+}
+
+//
+//
+func roleCatalogCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "catalog",
+		Short:        "Catalog of requestable roles",
+		Long:         `Catalog of requestable roles`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(roleCatalogExportCmd())
+
+	return cmd
+}
+
+//
+//
+func roleCatalogExportCmd() *cobra.Command {
+	options := roleOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export catalog of requestable roles",
+		Long: `Export catalog of roles that can be requested through workflows with their
+descriptions, approvers, workflows and maximum durations.`,
+		Example: `
+	privx-cli roles catalog export [access flags] --format markdown --out catalog.md
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return roleCatalogExport(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.format, "format", "json", "catalog format, markdown or json")
+
+	return cmd
+}
+
+// catalogEntry is a requestable role
+type catalogEntry struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Workflows   []catalogWorkflow `json:"workflows"`
+}
+
+type catalogWorkflow struct {
+	Name        string   `json:"name"`
+	Approvers   []string `json:"approvers"`
+	GrantTypes  []string `json:"grant_types"`
+	MaxDuration int      `json:"max_duration_hours,omitempty"`
+}
+
+func roleCatalogExport(options roleOptions) error {
+	if options.format != "json" && options.format != "markdown" {
+		return fmt.Errorf("unknown catalog format: %s", options.format)
+	}
+
+	roles, err := rolestore.New(curl()).Roles()
+	if err != nil {
+		return err
+	}
+
+	var view []struct {
+		ID      string `json:"id"`
+		Name    string `json:"name"`
+		Comment string `json:"comment"`
+	}
+	if err := remarshal(roles, &view); err != nil {
+		return err
+	}
+
+	workflows, err := allWorkflows()
+	if err != nil {
+		return err
+	}
+
+	catalog := []catalogEntry{}
+	for _, role := range view {
+		entry := catalogEntry{ID: role.ID, Name: role.Name, Description: role.Comment}
+
+		for _, wf := range workflows {
+			if !hasWorkflowRole(wf.TargetRoles, []string{role.ID}) {
+				continue
+			}
+
+			approvers := []string{}
+			for _, step := range wf.Steps {
+				for _, approver := range step.Approvers {
+					approvers = append(approvers, approver.Role.Name)
+				}
+			}
+
+			entry.Workflows = append(entry.Workflows, catalogWorkflow{
+				Name:        wf.Name,
+				Approvers:   approvers,
+				GrantTypes:  wf.GrantTypes,
+				MaxDuration: wf.MaxTimeRestrictedDuration,
+			})
+		}
+
+		if len(entry.Workflows) > 0 {
+			catalog = append(catalog, entry)
+		}
+	}
+
+	sort.Slice(catalog, func(i, j int) bool { return catalog[i].Name < catalog[j].Name })
+
+	if options.format == "json" {
+		return stdout(catalog)
+	}
+
+	return writeOutput(catalogMarkdown(catalog))
+}
+
+func catalogMarkdown(catalog []catalogEntry) []byte {
+	var md bytes.Buffer
+	cell := func(s string) string {
+		return strings.ReplaceAll(strings.ReplaceAll(s, "|", "\\|"), "\n", " ")
+	}
+
+	md.WriteString("# Requestable roles\n\n")
+	md.WriteString("| Role | Description | Workflow | Approvers | Grant types | Max duration |\n")
+	md.WriteString("|------|-------------|----------|-----------|-------------|--------------|\n")
+	for _, entry := range catalog {
+		for _, wf := range entry.Workflows {
+			duration := ""
+			if wf.MaxDuration > 0 {
+				duration = fmt.Sprintf("%dh", wf.MaxDuration)
+			}
+			fmt.Fprintf(&md, "| %s | %s | %s | %s | %s | %s |\n",
+				cell(entry.Name), cell(entry.Description), cell(wf.Name),
+				cell(strings.Join(wf.Approvers, ", ")),
+				cell(strings.Join(wf.GrantTypes, ", ")), duration)
+		}
+	}
+
+	return md.Bytes()
+}
+
+// remarshal converts SDK object to a local view of the same JSON document
+func remarshal(in, out interface{}) error {
+	data, err := json.Marshal(in)
 	if err != nil {
 		return err
 	}
 
-	return stdout(token)
+	return json.Unmarshal(data, out)
 }
@@ -7,8 +7,10 @@
 package cmd
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/SSHcom/privx-sdk-go/oauth"
 	"github.com/SSHcom/privx-sdk-go/restapi"
@@ -17,7 +19,23 @@ import (
 
 // Execute is entry point to application
 func Execute() error {
-	return rootCmd.Execute()
+	stop := handleSignals()
+	defer stop()
+
+	registerIDCompletions(rootCmd)
+	rootCmd.SetArgs(profileSelector(os.Args[1:]))
+	cmd, err := rootCmd.ExecuteC()
+	recordUsage(cmd, err)
+	writeStatus(err)
+	if errors.Is(err, errDryRun) {
+		info("%s", err)
+		return nil
+	}
+	if err == nil {
+		dryRunSummary()
+	}
+
+	return err
 }
 
 var (
@@ -31,6 +49,29 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&access, "url", "", "PrivX absolute URL (e.g. https://your-instance.privx.io)")
 	rootCmd.PersistentFlags().StringVarP(&access, "access", "a", "", "either access key of api client or username.")
 	rootCmd.PersistentFlags().StringVarP(&secret, "secret", "s", "", "either secret key of api client or password.")
+
+	rootCmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
+		for _, header := range headers {
+			if !strings.Contains(header, "=") {
+				return fmt.Errorf("invalid header, expected KEY=VALUE: %s", header)
+			}
+		}
+
+		// profiles are managed, not used by config commands
+		if cmd.HasParent() && cmd.Parent().Name() == "config" {
+			return nil
+		}
+
+		switch cmd.Name() {
+		case "help", cobra.ShellCompRequestCmd, cobra.ShellCompNoDescRequestCmd:
+		default:
+			if err := checkCommandAllowed(cmd); err != nil {
+				return err
+			}
+		}
+
+		return useProfile()
+	}
 }
 
 //
@@ -51,6 +92,12 @@ Configure client with cli flags
 privx-cli --url https://your-instance.privx.io \
 	--access your-username \
 	--secret your-password
+
+Select named profile with leading @name
+privx-cli @prod roles list
+
+Show change of update command without applying it
+privx-cli roles update role.json --id <ROLE-ID> --dry-run
 `,
 	Run:     root,
 	Version: "v1",
@@ -61,34 +108,50 @@ func root(cmd *cobra.Command, args []string) {
 }
 
 func auth() restapi.Authorizer {
-	curl := restapi.New(
-		restapi.UseConfigFile(config),
-		restapi.UseEnvironment(),
-	)
-
-	return oauth.With(
-		curl,
-		oauth.UseConfigFile(config),
-		oauth.UseEnvironment(),
-		oauth.Access(access),
-		oauth.Secret(secret),
-	)
+	curl := restapi.New(connectorOptions()...)
+
+	return tokenCache{
+		oauth.With(
+			curl,
+			oauth.UseConfigFile(config),
+			oauth.UseEnvironment(),
+			oauth.Access(access),
+			oauth.Secret(secret),
+		),
+	}
 }
 
 func curl() restapi.Connector {
-	return restapi.New(
-		restapi.Auth(auth()),
+	return curlWith(auth())
+}
+
+// curlWith is connector of the authorizer, parallel API calls share it
+// not to authorize each of them
+func curlWith(authorizer restapi.Authorizer) restapi.Connector {
+	return connector{
+		Connector: restapi.New(
+			append(connectorOptions(), restapi.Auth(authorizer))...,
+		),
+	}
+}
+
+// uncachedCurl is connector of read-modify-write operations, which must
+// not base their changes on cached responses
+func uncachedCurl() restapi.Connector {
+	c := curl().(connector)
+	c.uncached = true
+	return c
+}
+
+func connectorOptions() []restapi.Option {
+	opts := []restapi.Option{
 		restapi.UseConfigFile(config),
 		restapi.UseEnvironment(),
-	)
-}
+	}
 
-func stdout(data interface{}) error {
-	encoded, err := json.Marshal(data)
-	if err != nil {
-		return err
+	if url := endpoint(); url != "" {
+		opts = append(opts, restapi.BaseURL(url))
 	}
 
-	_, err = os.Stdout.Write(encoded)
-	return err
+	return opts
 }
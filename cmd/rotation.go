@@ -0,0 +1,276 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// REST API of password rotation, the SDK has no client for it yet
+const (
+	passwordPoliciesPath = "/host-store/api/v1/passwordpolicies"
+	passwordPolicyPath   = "/host-store/api/v1/passwordpolicy"
+)
+
+type rotationOptions struct {
+	hostID  string
+	account string
+	since   string
+}
+
+// rotationResult is an outcome of triggered password rotation
+type rotationResult struct {
+	HostID  string `json:"host_id"`
+	Account string `json:"account"`
+	Rotated bool   `json:"rotated"`
+	Error   string `json:"error,omitempty"`
+}
+
+// rotationEvent is a password rotation recorded by audit events
+type rotationEvent struct {
+	Time    string `json:"time"`
+	Event   string `json:"event"`
+	HostID  string `json:"host_id,omitempty"`
+	Account string `json:"account,omitempty"`
+	User    string `json:"user,omitempty"`
+}
+
+func init() {
+	rootCmd.AddCommand(rotationCmd())
+}
+
+//
+//
+func rotationCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "rotation",
+		Short:        "Manage password rotation of host accounts",
+		Long:         `Manage password rotation policies, rotate passwords of host accounts and audit rotations`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(rotationPoliciesCmd())
+	cmd.AddCommand(rotationRotateCmd())
+	cmd.AddCommand(rotationHistoryCmd())
+
+	return cmd
+}
+
+//
+//
+func rotationPoliciesCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "policies",
+		Short:        "List and create password rotation policies",
+		Long:         `List and create password rotation policies`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(rotationPolicyListCmd())
+	cmd.AddCommand(rotationPolicyCreateCmd())
+
+	return cmd
+}
+
+//
+//
+func rotationPolicyListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List password rotation policies",
+		Long:  `List password rotation policies`,
+		Example: `
+	privx-cli rotation policies list [access flags]
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return rotationPolicyList()
+		},
+	}
+
+	return cmd
+}
+
+func rotationPolicyList() error {
+	var page struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+
+	_, err := curl().URL(passwordPoliciesPath).Get(&page)
+	if err != nil {
+		return apiUnsupported(err, "password rotation")
+	}
+
+	return stdout(page.Items)
+}
+
+//
+//
+func rotationPolicyCreateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create new password rotation policy",
+		Long:  `Create new password rotation policy, e.g. password length, character classes and rotation interval`,
+		Example: `
+	privx-cli rotation policies create [access flags] JSON-FILE
+		`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return rotationPolicyCreate(args)
+		},
+	}
+
+	return bulkCmd(cmd)
+}
+
+func rotationPolicyCreate(args []string) error {
+	var policy map[string]interface{}
+
+	err := decodeJSON(args[0], &policy)
+	if err != nil {
+		return err
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	_, err = curl().URL(passwordPolicyPath).Post(policy, &created)
+	if err != nil {
+		return apiUnsupported(err, "password rotation")
+	}
+
+	return stdout(created.ID)
+}
+
+//
+//
+func rotationRotateCmd() *cobra.Command {
+	options := rotationOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "rotate",
+		Short: "Rotate password of host account",
+		Long: `Rotate password of host account now, regardless of the schedule of its rotation
+policy. Account names are separated by commas when using multiple values, see example`,
+		Example: `
+	privx-cli rotation rotate [access flags] --host <HOST-ID> --account root
+	privx-cli rotation rotate [access flags] --host <HOST-ID> --account <ACCOUNT>,<ACCOUNT>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return rotationRotate(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.hostID, "host", "", "host ID")
+	flags.StringVar(&options.account, "account", "", "host account name")
+	cmd.MarkFlagRequired("host")
+	cmd.MarkFlagRequired("account")
+
+	return cmd
+}
+
+func rotationRotate(options rotationOptions) error {
+	failed := 0
+	results := []rotationResult{}
+
+	for _, account := range strings.Split(options.account, ",") {
+		result := rotationResult{HostID: options.hostID, Account: account}
+
+		_, err := curl().
+			URL("/host-store/api/v1/hosts/" + url.PathEscape(options.hostID) +
+				"/principals/" + url.PathEscape(account) + "/rotate").
+			Post(nil)
+		if err != nil {
+			result.Error = apiUnsupported(err, "password rotation").Error()
+			failed++
+		} else {
+			result.Rotated = true
+		}
+
+		results = append(results, result)
+	}
+
+	if err := stdout(results); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("password rotation failed for %d account(s)", failed)
+	}
+
+	return nil
+}
+
+//
+//
+func rotationHistoryCmd() *cobra.Command {
+	options := rotationOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "List password rotations recorded by audit events",
+		Long:  `List password rotations, successful and failed, recorded by audit events of PrivX`,
+		Example: `
+	privx-cli rotation history [access flags] --since 30d
+	privx-cli rotation history [access flags] --host <HOST-ID> --account root
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return rotationHistory(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.hostID, "host", "", "list rotations of host ID")
+	flags.StringVar(&options.account, "account", "", "list rotations of host account")
+	flags.StringVar(&options.since, "since", "30d", "list rotations since time or duration ago, e.g. 7d")
+
+	return cmd
+}
+
+func rotationHistory(options rotationOptions) error {
+	since, err := parseTimeFlag(options.since)
+	if err != nil {
+		return err
+	}
+
+	events, err := searchEvents(eventFilter{since: since}, nil)
+	if err != nil {
+		return err
+	}
+
+	history := []rotationEvent{}
+	for _, event := range events {
+		name := fmt.Sprint(firstOf(event, "event", "event_name"))
+		if !strings.Contains(name, "ROTAT") {
+			continue
+		}
+		if options.hostID != "" && !referencesID(event, options.hostID) {
+			continue
+		}
+
+		rotation := rotationEvent{Time: fmt.Sprint(event["timestamp"]), Event: name}
+		rotation.HostID, _ = firstOf(event, "host_id", "target_host_id").(string)
+		rotation.Account, _ = firstOf(event, "principal", "account", "target_user").(string)
+		rotation.User, _ = firstOf(event, "username", "user_name", "user").(string)
+
+		if options.account != "" && rotation.Account != options.account {
+			continue
+		}
+
+		history = append(history, rotation)
+	}
+
+	return stdout(history)
+}
@@ -0,0 +1,104 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/SSHcom/privx-sdk-go/api/vault"
+	"github.com/spf13/cobra"
+)
+
+type secretExecOptions struct {
+	secretName string
+	prefix     string
+}
+
+//
+//
+func secretExecCmd() *cobra.Command {
+	options := secretExecOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "exec --name <SECRET-NAME> -- COMMAND [ARGS...]",
+		Short: "Run command with secret in environment",
+		Long: `Run command with keys of secret data as environment variables. Key names are
+upper cased and characters other than letters, digits and underscore are replaced
+with underscore, e.g. db-password becomes DB_PASSWORD. Values other than strings
+are passed as JSON. Secret Name's are separated by commas when using multiple
+values, later secrets override keys of earlier ones. The secret is never written
+to disk, exit status of the command is returned.`,
+		Example: `
+	privx-cli secrets exec [access flags] --name db-credentials -- ./migrate.sh
+	privx-cli vault exec [access flags] --name <SECRET-NAME>,<SECRET-NAME> --prefix APP_ -- env
+		`,
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return secretExec(options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.secretName, "name", "", "secret name")
+	flags.StringVar(&options.prefix, "prefix", "", "prefix of environment variable names")
+	flags.SetInterspersed(false)
+	cmd.MarkFlagRequired("name")
+
+	return cmd
+}
+
+var unsafeEnvChars = regexp.MustCompile(`[^A-Z0-9_]`)
+
+func secretExec(options secretExecOptions, args []string) error {
+	api := vault.New(curl())
+	env := os.Environ()
+
+	for _, name := range strings.Split(options.secretName, ",") {
+		secret, err := api.Secret(name)
+		if err != nil {
+			return err
+		}
+
+		var view struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		if err := remarshal(secret, &view); err != nil {
+			return err
+		}
+		if view.Data == nil {
+			return fmt.Errorf("secret %s has no key-value data", name)
+		}
+
+		for key, value := range view.Data {
+			text, ok := value.(string)
+			if !ok {
+				data, err := json.Marshal(value)
+				if err != nil {
+					return err
+				}
+				text = string(data)
+			}
+
+			variable := unsafeEnvChars.ReplaceAllString(strings.ToUpper(options.prefix+key), "_")
+			env = append(env, variable+"="+text)
+		}
+	}
+
+	child := exec.Command(args[0], args[1:]...)
+	child.Env = env
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+
+	return child.Run()
+}
@@ -0,0 +1,139 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/api/vault"
+	"github.com/spf13/cobra"
+)
+
+type secretWatchOptions struct {
+	secretName string
+	command    string
+	interval   time.Duration
+}
+
+//
+//
+func secretWatchCmd() *cobra.Command {
+	options := secretWatchOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Run command when secret changes",
+		Long: `Poll metadata of secrets and run the command when a secret changes, e.g. to reload
+a service after password rotation. The command is run by sh -c with the secret name
+in PRIVX_SECRET_NAME and the secret metadata as JSON in stdin, the secret data is
+not passed to the command. Watching continues until interrupted.`,
+		Example: `
+	privx-cli secrets watch [access flags] --name db-password --exec "systemctl reload app"
+	privx-cli secrets watch [access flags] --name <SECRET-NAME>,<SECRET-NAME> --interval 5m --exec ./on-rotate.sh
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return secretWatch(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.secretName, "name", "", "secret name")
+	flags.StringVar(&options.command, "exec", "", "command to run when secret changes")
+	flags.DurationVar(&options.interval, "interval", 30*time.Second, "polling interval")
+	cmd.MarkFlagRequired("name")
+	cmd.MarkFlagRequired("exec")
+
+	return cmd
+}
+
+func secretWatch(options secretWatchOptions) error {
+	if options.interval <= 0 {
+		return fmt.Errorf("invalid --interval: %s", options.interval)
+	}
+
+	names := strings.Split(options.secretName, ",")
+
+	// versions are known states of secrets, the first poll sets the baseline
+	versions := map[string]string{}
+	for _, name := range names {
+		_, version, err := secretVersion(name)
+		if err != nil {
+			return err
+		}
+		versions[name] = version
+	}
+
+	for {
+		select {
+		case <-runContext.Done():
+			return nil
+		case <-time.After(options.interval):
+		}
+
+		for _, name := range names {
+			metadata, version, err := secretVersion(name)
+			if interrupted() != nil {
+				return nil
+			}
+			if err != nil {
+				// transient failures must not stop the watch
+				info("%s: %s", name, err)
+				continue
+			}
+			if version == versions[name] {
+				continue
+			}
+			versions[name] = version
+
+			if err := runSecretHook(options.command, name, metadata); err != nil {
+				info("%s: command failed: %s", name, err)
+			}
+		}
+	}
+}
+
+// secretVersion returns metadata of secret and a value that changes when
+// the secret is updated
+func secretVersion(name string) ([]byte, string, error) {
+	secret, err := vault.New(curl()).SecretMetadata(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	metadata, err := json.Marshal(secret)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var view map[string]interface{}
+	if err := json.Unmarshal(metadata, &view); err != nil {
+		return nil, "", err
+	}
+
+	if version := firstOf(view, "version", "updated"); version != nil {
+		return metadata, fmt.Sprint(version), nil
+	}
+
+	return metadata, string(metadata), nil
+}
+
+func runSecretHook(command, name string, metadata []byte) error {
+	hook := exec.Command("sh", "-c", command)
+	hook.Env = append(os.Environ(), "PRIVX_SECRET_NAME="+name)
+	hook.Stdin = bytes.NewReader(metadata)
+	hook.Stdout = os.Stdout
+	hook.Stderr = os.Stderr
+
+	return hook.Run()
+}
@@ -0,0 +1,382 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+type selfUpdateOptions struct {
+	channel   string
+	publicKey string
+	force     bool
+}
+
+// releasesURL lists published releases of the CLI
+const releasesURL = "https://api.github.com/repos/SSHcom/privx-cli/releases"
+
+// Release builds embed their version and base64 encoded ed25519 release
+// signing key with -ldflags, e.g.
+// -X github.com/SSHcom/privx-cli/cmd.version=v1.2.0
+// -X github.com/SSHcom/privx-cli/cmd.releaseKey=<KEY>
+var (
+	version    string
+	releaseKey string
+)
+
+type release struct {
+	Tag        string         `json:"tag_name"`
+	Prerelease bool           `json:"prerelease"`
+	Draft      bool           `json:"draft"`
+	Assets     []releaseAsset `json:"assets"`
+}
+
+type releaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+func (r release) asset(name string) *releaseAsset {
+	for i := range r.Assets {
+		if r.Assets[i].Name == name {
+			return &r.Assets[i]
+		}
+	}
+	return nil
+}
+
+func init() {
+	if version != "" {
+		rootCmd.Version = version
+	}
+	rootCmd.AddCommand(selfUpdateCmd())
+}
+
+//
+//
+func selfUpdateCmd() *cobra.Command {
+	options := selfUpdateOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "self-update",
+		Short: "Update privx-cli to the latest release",
+		Long: `Update privx-cli to the latest release of the channel. The release must have
+a valid ed25519 signature checksums.txt.sig of its tag and checksums.txt by the
+release key embedded in the binary, or by --public-key, and the binary is
+verified against the checksums. The running binary is replaced atomically.`,
+		Example: `
+	privx-cli self-update
+	privx-cli self-update --channel beta --public-key release.pub
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return selfUpdate(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.channel, "channel", "stable", "release channel, stable or beta")
+	flags.StringVar(&options.publicKey, "public-key", "", "file with base64 encoded ed25519 release signing key, instead of the embedded key")
+	flags.BoolVar(&options.force, "force", false, "reinstall even if the version is current")
+
+	return cmd
+}
+
+func selfUpdate(options selfUpdateOptions) error {
+	if options.channel != "stable" && options.channel != "beta" {
+		return fmt.Errorf("unknown channel: %s", options.channel)
+	}
+
+	key, err := releasePublicKey(options.publicKey)
+	if err != nil {
+		return err
+	}
+
+	latest, err := latestRelease(options.channel)
+	if err != nil {
+		return err
+	}
+
+	if !options.force {
+		switch version {
+		case "":
+			return errors.New("version of this build is unknown, use --force to install the latest release")
+		case latest.Tag:
+			info("privx-cli %s is up to date", version)
+			return nil
+		}
+	}
+
+	asset := releaseBinary(latest)
+	if asset == nil {
+		return fmt.Errorf("release %s has no binary for %s/%s", latest.Tag, runtime.GOOS, runtime.GOARCH)
+	}
+
+	sums := latest.asset("checksums.txt")
+	if sums == nil {
+		return fmt.Errorf("release %s has no checksums.txt", latest.Tag)
+	}
+
+	checksums, err := download(sums.URL)
+	if err != nil {
+		return err
+	}
+
+	sig := latest.asset("checksums.txt.sig")
+	if sig == nil {
+		return fmt.Errorf("release %s has no checksums.txt.sig", latest.Tag)
+	}
+	signature, err := download(sig.URL)
+	if err != nil {
+		return err
+	}
+	if err := verifySignature(key, latest.Tag, checksums, signature); err != nil {
+		return err
+	}
+
+	archive, err := download(asset.URL)
+	if err != nil {
+		return err
+	}
+
+	if err := verifyChecksum(checksums, asset.Name, archive); err != nil {
+		return err
+	}
+
+	binary, err := extractBinary(asset.Name, archive)
+	if err != nil {
+		return err
+	}
+
+	if err := replaceExecutable(binary); err != nil {
+		return err
+	}
+
+	info("privx-cli updated to %s", latest.Tag)
+	return nil
+}
+
+func latestRelease(channel string) (release, error) {
+	data, err := download(releasesURL)
+	if err != nil {
+		return release{}, err
+	}
+
+	var releases []release
+	if err := json.Unmarshal(data, &releases); err != nil {
+		return release{}, err
+	}
+
+	// releases are listed newest first
+	for _, r := range releases {
+		if r.Draft || (r.Prerelease && channel != "beta") {
+			continue
+		}
+		return r, nil
+	}
+
+	return release{}, fmt.Errorf("no releases in %s channel", channel)
+}
+
+// releaseBinary picks asset built for the running platform. OS and
+// architecture must be segments of the name, so that "arm" does not
+// match "arm64".
+func releaseBinary(r release) *releaseAsset {
+	for i, asset := range r.Assets {
+		name := strings.ToLower(asset.Name)
+		if strings.HasSuffix(name, ".sig") || strings.HasSuffix(name, ".sha256") {
+			continue
+		}
+
+		segments := map[string]bool{}
+		for _, segment := range strings.FieldsFunc(name, func(c rune) bool {
+			return c == '-' || c == '_' || c == '.'
+		}) {
+			segments[segment] = true
+		}
+
+		if segments[runtime.GOOS] && segments[runtime.GOARCH] {
+			return &r.Assets[i]
+		}
+	}
+	return nil
+}
+
+func download(url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(runContext, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download %s failed: %s", url, resp.Status)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// releasePublicKey is the key of --public-key file or the embedded key
+func releasePublicKey(keyFile string) (ed25519.PublicKey, error) {
+	encoded := releaseKey
+	if keyFile != "" {
+		data, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, err
+		}
+		encoded = string(data)
+	}
+
+	if encoded == "" {
+		return nil, errors.New("this build has no release signing key, use --public-key")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid ed25519 public key")
+	}
+
+	return ed25519.PublicKey(key), nil
+}
+
+// verifySignature checks signature of the release tag, followed by new
+// line, and checksums. Signed tag prevents replay of checksums of older
+// release as the latest one.
+func verifySignature(key ed25519.PublicKey, tag string, checksums, signature []byte) error {
+	if sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature))); err == nil {
+		signature = sig
+	}
+
+	signed := append([]byte(tag+"\n"), checksums...)
+	if !ed25519.Verify(key, signed, signature) {
+		return fmt.Errorf("signature of release %s is not valid", tag)
+	}
+
+	return nil
+}
+
+// verifyChecksum checks data against sha256sum formatted checksums
+func verifyChecksum(checksums []byte, name string, data []byte) error {
+	sum := sha256.Sum256(data)
+
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			if !strings.EqualFold(fields[0], hex.EncodeToString(sum[:])) {
+				return fmt.Errorf("checksum mismatch for %s", name)
+			}
+			return nil
+		}
+	}
+
+	return fmt.Errorf("no checksum for %s", name)
+}
+
+// extractBinary returns privx-cli binary from tar.gz or zip archive,
+// other assets are considered to be plain binaries
+func extractBinary(name string, data []byte) ([]byte, error) {
+	binary := "privx-cli"
+	if runtime.GOOS == "windows" {
+		binary += ".exe"
+	}
+
+	switch {
+	case strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz"):
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		archive := tar.NewReader(gz)
+		for {
+			header, err := archive.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if filepath.Base(header.Name) == binary {
+				return ioutil.ReadAll(archive)
+			}
+		}
+
+	case strings.HasSuffix(name, ".zip"):
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range archive.File {
+			if filepath.Base(file.Name) == binary {
+				f, err := file.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer f.Close()
+				return ioutil.ReadAll(f)
+			}
+		}
+
+	default:
+		return data, nil
+	}
+
+	return nil, fmt.Errorf("%s not found in %s", binary, name)
+}
+
+// replaceExecutable swaps running binary with new one. Windows does not
+// allow overwriting running executable but allows renaming it.
+func replaceExecutable(binary []byte) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(exe+".new", binary); err != nil {
+		return err
+	}
+	if err := os.Chmod(exe+".new", 0755); err != nil {
+		return err
+	}
+
+	if runtime.GOOS == "windows" {
+		os.Remove(exe + ".old")
+		if err := os.Rename(exe, exe+".old"); err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(exe+".new", exe)
+}
@@ -8,6 +8,10 @@ package cmd
 
 import (
 	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/SSHcom/privx-sdk-go/api/settings"
@@ -19,6 +23,13 @@ type settingsOptions struct {
 	section string
 }
 
+type proxySettingsOptions struct {
+	scope     string
+	section   string
+	ports     map[string]int
+	addresses string
+}
+
 func (m settingsOptions) normalize_scope() string {
 	return strings.ToUpper(m.scope)
 }
@@ -35,16 +46,25 @@ func init() {
 //
 func settingsCmd() *cobra.Command {
 	cmd := &cobra.Command{
-		Use:          "settings",
-		Short:        "List and manage settings",
-		Long:         `List and manage settings`,
+		Use:   "settings",
+		Short: "List and manage settings",
+		Long: `List and manage settings. Settings are grouped by scope, GLOBAL or name of
+the service, e.g. CONNECTION-MANAGER, and by section within the scope. The output
+of settings show is accepted by settings update, so settings can be kept in
+version control and reapplied to other environments.`,
+		Example: `
+	privx-cli settings show [access flags] --scope CONNECTION-MANAGER > connection-manager.json
+	privx-cli settings update [access flags] --scope CONNECTION-MANAGER connection-manager.json
+		`,
 		SilenceUsage: true,
 	}
 
 	cmd.AddCommand(settingShowCmd())
 	cmd.AddCommand(settingUpdateCmd())
+	cmd.AddCommand(schemaCmd())
 	cmd.AddCommand(schemaListCmd())
 	cmd.AddCommand(schemaShowCmd())
+	cmd.AddCommand(proxySettingsCmd())
 
 	return cmd
 }
@@ -121,7 +141,7 @@ func settingUpdateCmd() *cobra.Command {
 	flags.StringVar(&options.section, "section", "", "section setting name")
 	cmd.MarkFlagRequired("scope")
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func settingUpdate(options settingsOptions, args []string) error {
@@ -133,6 +153,21 @@ func settingUpdate(options settingsOptions, args []string) error {
 		return err
 	}
 
+	var current interface{}
+	if options.section == "" {
+		current, err = api.ScopeSettings(options.normalize_scope(), "")
+	} else {
+		current, err = api.ScopeSectionSettings(options.normalize_scope(),
+			options.normalize_section())
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := confirmUpdate(current, updateSettings); err != nil {
+		return err
+	}
+
 	switch options.section {
 	case "":
 		err = api.UpdateScopeSettings(&updateSettings, options.normalize_scope())
@@ -144,6 +179,35 @@ func settingUpdate(options settingsOptions, args []string) error {
 	return err
 }
 
+//
+//
+func schemaCmd() *cobra.Command {
+	options := settingsOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Get schema of scope or section settings",
+		Long:  `Get schema of scope or section settings. Scope is by default GLOBAL.`,
+		Example: `
+	privx-cli settings schema [access flags] --scope <SCOPE>
+	privx-cli settings schema [access flags] --scope <SCOPE> --section <SECTION>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if options.section != "" {
+				return schemaShow(options)
+			}
+			return schemaList(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&options.scope, "scope", "", "GLOBAL", "scope setting name")
+	flags.StringVar(&options.section, "section", "", "section setting name")
+
+	return cmd
+}
+
 //
 //
 func schemaListCmd() *cobra.Command {
@@ -216,3 +280,193 @@ func schemaShow(options settingsOptions) error {
 
 	return stdout(res)
 }
+
+// proxyProtocols are listeners of PrivX proxy
+var proxyProtocols = []string{"ssh", "rdp", "web", "vnc", "db"}
+
+//
+//
+func proxySettingsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "proxy",
+		Short:        "Show and update proxy listener settings",
+		Long:         `Show and update proxy listener ports and external addresses`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(proxySettingsShowCmd())
+	cmd.AddCommand(proxySettingsUpdateCmd())
+
+	return cmd
+}
+
+func proxySettingsFlags(cmd *cobra.Command, options *proxySettingsOptions) {
+	flags := cmd.Flags()
+	flags.StringVar(&options.scope, "scope", "CONNECTION-MANAGER", "scope of proxy settings")
+	flags.StringVar(&options.section, "section", "proxy", "section of proxy settings")
+}
+
+//
+//
+func proxySettingsShowCmd() *cobra.Command {
+	options := proxySettingsOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Show proxy listener settings",
+		Long:  `Show proxy listener ports and external addresses`,
+		Example: `
+	privx-cli settings proxy show [access flags]
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return proxySettingsShow(options)
+		},
+	}
+
+	proxySettingsFlags(cmd, &options)
+
+	return cmd
+}
+
+func proxySettingsShow(options proxySettingsOptions) error {
+	section, err := proxySettings(options)
+	if err != nil {
+		return err
+	}
+
+	listeners := map[string]interface{}{}
+	for _, protocol := range proxyProtocols {
+		if port, ok := section[protocol+"_port"]; ok {
+			listeners[protocol+"_port"] = port
+		}
+	}
+	listeners["external_addresses"] = section["external_addresses"]
+
+	return stdout(listeners)
+}
+
+//
+//
+func proxySettingsUpdateCmd() *cobra.Command {
+	options := proxySettingsOptions{ports: map[string]int{}}
+	ports := map[string]*int{}
+
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "Update proxy listener settings",
+		Long: `Update proxy listener ports and external addresses. Only given values are changed.
+Ports must be in range 1-65535 and unique, external addresses are comma separated
+host or host:port values.`,
+		Example: `
+	privx-cli settings proxy update [access flags] --ssh-port 2222 --rdp-port 3389
+	privx-cli settings proxy update [access flags] --external-addresses lb1.example.com,lb2.example.com:2222
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for protocol, port := range ports {
+				if cmd.Flags().Changed(protocol + "-port") {
+					options.ports[protocol+"_port"] = *port
+				}
+			}
+			return proxySettingsUpdate(options)
+		},
+	}
+
+	proxySettingsFlags(cmd, &options)
+	flags := cmd.Flags()
+	for _, protocol := range proxyProtocols {
+		ports[protocol] = flags.Int(protocol+"-port", 0, strings.ToUpper(protocol)+" listener port")
+	}
+	flags.StringVar(&options.addresses, "external-addresses", "", "comma separated external addresses of proxy")
+
+	return cmd
+}
+
+func proxySettingsUpdate(options proxySettingsOptions) error {
+	section, err := proxySettings(options)
+	if err != nil {
+		return err
+	}
+
+	for key, port := range options.ports {
+		if port < 1 || port > 65535 {
+			return fmt.Errorf("invalid %s: %d", key, port)
+		}
+		section[key] = port
+	}
+
+	if options.addresses != "" {
+		addresses := strings.Split(options.addresses, ",")
+		for _, address := range addresses {
+			if err := validateProxyAddress(address); err != nil {
+				return err
+			}
+		}
+		section["external_addresses"] = addresses
+	}
+
+	used := map[string]string{}
+	keys := []string{}
+	for key := range section {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !strings.HasSuffix(key, "_port") {
+			continue
+		}
+		port := fmt.Sprint(section[key])
+		if other, ok := used[port]; ok {
+			return fmt.Errorf("%s and %s use the same port %s", other, key, port)
+		}
+		used[port] = key
+	}
+
+	current, err := proxySettings(options)
+	if err != nil {
+		return err
+	}
+
+	if err := confirmUpdate(current, section); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(section)
+	if err != nil {
+		return err
+	}
+
+	raw := json.RawMessage(data)
+	return settings.New(curl()).UpdateScopeSectionSettings(&raw,
+		strings.ToUpper(options.scope), strings.ToLower(options.section))
+}
+
+func proxySettings(options proxySettingsOptions) (map[string]interface{}, error) {
+	res, err := settings.New(curl()).ScopeSectionSettings(strings.ToUpper(options.scope),
+		strings.ToLower(options.section))
+	if err != nil {
+		return nil, err
+	}
+
+	section := map[string]interface{}{}
+	err = remarshal(res, &section)
+	return section, err
+}
+
+func validateProxyAddress(address string) error {
+	host := address
+	if h, port, err := net.SplitHostPort(address); err == nil {
+		n, err := strconv.Atoi(port)
+		if err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("invalid port in external address: %s", address)
+		}
+		host = h
+	}
+
+	if host == "" || strings.ContainsAny(host, " /") {
+		return fmt.Errorf("invalid external address: %s", address)
+	}
+
+	return nil
+}
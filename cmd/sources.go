@@ -9,6 +9,7 @@ package cmd
 import (
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/SSHcom/privx-sdk-go/api/rolestore"
 	"github.com/spf13/cobra"
@@ -16,6 +17,7 @@ import (
 
 type sourceOptions struct {
 	sourceID string
+	wait     waiting
 }
 
 func init() {
@@ -38,6 +40,7 @@ privx-cli sources [access flags]
 		},
 	}
 
+	cmd.AddCommand(sourceListSubCmd())
 	cmd.AddCommand(sourceCreateCmd())
 	cmd.AddCommand(sourceShowCmd())
 	cmd.AddCommand(sourceDeleteCmd())
@@ -58,6 +61,25 @@ func sourceList() error {
 	return stdout(sources)
 }
 
+//
+//
+func sourceListSubCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List user and host directories",
+		Long:  `List user and host directories`,
+		Example: `
+	privx-cli sources list [access flags]
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return sourceList()
+		},
+	}
+
+	return cmd
+}
+
 //
 //
 func sourceCreateCmd() *cobra.Command {
@@ -75,7 +97,7 @@ func sourceCreateCmd() *cobra.Command {
 		},
 	}
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func sourceCreate(cmd *cobra.Command, args []string) error {
@@ -194,7 +216,7 @@ func sourceUpdateCmd() *cobra.Command {
 	flags.StringVar(&options.sourceID, "id", "", "unique source ID")
 	cmd.MarkFlagRequired("id")
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func sourceUpdate(options sourceOptions, args []string) error {
@@ -206,6 +228,15 @@ func sourceUpdate(options sourceOptions, args []string) error {
 		return err
 	}
 
+	current, err := api.Source(options.sourceID)
+	if err != nil {
+		return err
+	}
+
+	if err := confirmUpdate(current, &updateSource); err != nil {
+		return err
+	}
+
 	err = api.UpdateSource(options.sourceID, &updateSource)
 	if err != nil {
 		return err
@@ -222,10 +253,14 @@ func sourceRefreshCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "refresh",
 		Short: "Refresh Source",
-		Long:  `Refresh Source. Source ID's are separated by commas when using multiple values, see example`,
+		Long: `Refresh Source. Source ID's are separated by commas when using multiple values, see example.
+Sync status of the sources is reported after the refresh is triggered. With --wait
+the command waits until the sources are synchronized or --timeout is up.`,
 		Example: `
 	privx-cli sources refresh [access flags] --id <SOURCE-ID>,<SOURCE-ID>
+	privx-cli sources refresh [access flags] --id <SOURCE-ID> --wait --timeout 5m
 		`,
+		Args:         cobra.NoArgs,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return sourceRefresh(options)
@@ -234,18 +269,92 @@ func sourceRefreshCmd() *cobra.Command {
 
 	flags := cmd.Flags()
 	flags.StringVar(&options.sourceID, "id", "", "source ID")
+	options.wait.register(cmd, "sources are synchronized")
 	cmd.MarkFlagRequired("id")
 
 	return cmd
 }
 
+// sourceSyncStatus is a sync state of source reported by refresh
+type sourceSyncStatus struct {
+	ID      string      `json:"id"`
+	Name    string      `json:"name"`
+	Synced  bool        `json:"synced"`
+	Updated string      `json:"updated,omitempty"`
+	Status  interface{} `json:"status,omitempty"`
+}
+
 func sourceRefresh(options sourceOptions) error {
 	api := rolestore.New(curl())
+	ids := strings.Split(options.sourceID, ",")
+	started := time.Now()
 
-	err := api.RefreshSources(strings.Split(options.sourceID, ","))
+	err := api.RefreshSources(ids)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	var (
+		report  []sourceSyncStatus
+		pending int
+	)
+	err = options.wait.poll("synchronization of sources", func() (bool, error) {
+		var fail error
+		report, pending, fail = sourceSyncReport(ids, started)
+		return pending == 0, fail
+	})
+	if report != nil {
+		if err := stdout(report); err != nil {
+			return err
+		}
+	}
+	if err != nil && pending > 0 {
+		info("%d of %d sources are not synchronized", pending, len(ids))
+	}
+
+	return err
+}
+
+// sourceSyncReport reads status of sources, source is synced when its
+// status is updated after the given time
+func sourceSyncReport(ids []string, after time.Time) ([]sourceSyncStatus, int, error) {
+	api := rolestore.New(curl())
+	report := []sourceSyncStatus{}
+	pending := 0
+
+	for _, id := range ids {
+		source, err := api.Source(id)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var view struct {
+			ID     string                   `json:"id"`
+			Name   string                   `json:"name"`
+			Status []map[string]interface{} `json:"status"`
+		}
+		if err := remarshal(source, &view); err != nil {
+			return nil, 0, err
+		}
+
+		status := sourceSyncStatus{ID: view.ID, Name: view.Name, Status: view.Status}
+		var latest time.Time
+		for _, s := range view.Status {
+			updated, err := time.Parse(time.RFC3339, fmt.Sprint(s["updated"]))
+			if err == nil && updated.After(latest) {
+				latest = updated
+			}
+		}
+		if !latest.IsZero() {
+			status.Updated = latest.Format(time.RFC3339)
+			status.Synced = latest.After(after)
+		}
+
+		if !status.Synced {
+			pending++
+		}
+		report = append(report, status)
+	}
+
+	return report, pending, nil
 }
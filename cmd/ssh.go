@@ -0,0 +1,158 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type sshOptions struct {
+	proxy    string
+	identity string
+	port     int
+	agent    bool
+	agentTTL time.Duration
+}
+
+func init() {
+	rootCmd.AddCommand(sshCmd())
+}
+
+//
+//
+func sshCmd() *cobra.Command {
+	options := sshOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "ssh USER@HOST [-- SSH-ARGS...]",
+		Short: "Connect to target host with short-lived certificate",
+		Long: `Connect to target host with short-lived SSH certificate issued by PrivX. The certificate
+is issued for a temporary key pair, or for the key given by --identity, and removed
+when the session ends. With --agent the key and certificate are added to ssh-agent
+for --agent-ttl instead. Sessions are routed via PrivX proxy given by --proxy or
+PRIVX_PROXY_ADDRESS. Arguments after -- are passed to ssh.`,
+		Example: `
+	privx-cli ssh [access flags] deploy@web01.example.com
+	privx-cli ssh [access flags] --proxy privx.example.com deploy@10.0.0.5
+	privx-cli ssh [access flags] --identity ~/.ssh/id_ed25519 deploy@web01.example.com -- -L 8080:localhost:80
+	privx-cli ssh [access flags] --agent --agent-ttl 15m deploy@web01.example.com
+		`,
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return sshConnect(options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.proxy, "proxy", os.Getenv("PRIVX_PROXY_ADDRESS"), "PrivX proxy address")
+	flags.StringVarP(&options.identity, "identity", "i", "", "private key to certify, temporary key if omitted")
+	flags.IntVarP(&options.port, "port", "p", 22, "target host port")
+	flags.BoolVar(&options.agent, "agent", false, "add key and certificate to ssh-agent")
+	flags.DurationVar(&options.agentTTL, "agent-ttl", 5*time.Minute, "lifetime of key in ssh-agent")
+	flags.SetInterspersed(false)
+
+	return cmd
+}
+
+func sshConnect(options sshOptions, args []string) error {
+	at := strings.LastIndex(args[0], "@")
+	if at <= 0 || at == len(args[0])-1 {
+		return fmt.Errorf("target must be USER@HOST: %s", args[0])
+	}
+	principal, host := args[0][:at], args[0][at+1:]
+
+	dir, err := ioutil.TempDir("", "privx-cli-ssh")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	key := filepath.Join(dir, "id_ed25519")
+	if options.identity == "" {
+		keygen := exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", "privx-cli", "-f", key)
+		keygen.Stderr = os.Stderr
+		if err := keygen.Run(); err != nil {
+			return fmt.Errorf("ssh-keygen: %w", err)
+		}
+	} else {
+		key = options.identity
+	}
+
+	publicKey, err := ioutil.ReadFile(key + ".pub")
+	if err != nil {
+		return err
+	}
+
+	credentials, err := targetHostCredentials(string(publicKey), host, options.port, principal)
+	if err != nil {
+		return err
+	}
+
+	cert, err := openSSHCertificate(credentials, host, principal)
+	if err != nil {
+		return err
+	}
+
+	certFile := filepath.Join(dir, "id-cert.pub")
+	if err := writeFileAtomic(certFile, []byte(cert+"\n")); err != nil {
+		return err
+	}
+
+	sshArgs := []string{}
+	if options.agent {
+		// ssh-add loads certificate named after the key
+		agentKey := filepath.Join(dir, "agent")
+		if err := copyFile(key, agentKey); err != nil {
+			return err
+		}
+		if err := os.Rename(certFile, agentKey+"-cert.pub"); err != nil {
+			return err
+		}
+
+		add := exec.Command("ssh-add", "-q", "-t", strconv.Itoa(int(options.agentTTL.Seconds())), agentKey)
+		add.Stderr = os.Stderr
+		if err := add.Run(); err != nil {
+			return fmt.Errorf("ssh-add: %w", err)
+		}
+	} else {
+		sshArgs = append(sshArgs, "-i", key, "-o", "CertificateFile="+certFile, "-o", "IdentitiesOnly=yes")
+	}
+
+	if options.proxy != "" {
+		sshArgs = append(sshArgs, "-t", "-l", principal+"@"+host, options.proxy)
+	} else {
+		sshArgs = append(sshArgs, "-p", strconv.Itoa(options.port), principal+"@"+host)
+	}
+	sshArgs = append(sshArgs, args[1:]...)
+
+	info("Connecting %s@%s", principal, host)
+	session := exec.Command("ssh", sshArgs...)
+	session.Stdin = os.Stdin
+	session.Stdout = os.Stdout
+	session.Stderr = os.Stderr
+
+	return session.Run()
+}
+
+func copyFile(from, to string) error {
+	data, err := ioutil.ReadFile(from)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(to, data, 0600)
+}
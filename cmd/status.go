@@ -0,0 +1,104 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"regexp"
+)
+
+// Exit codes of the CLI. Scripts branch on the dedicated codes, the
+// details of the state are printed to stdout as JSON.
+const (
+	ExitOK              = 0
+	ExitError           = 1
+	ExitPendingApproval = 3
+	ExitMFARequired     = 4
+	ExitTimeout         = 5
+	ExitInterrupted     = 130
+)
+
+// approvalPollInterval is suggested delay before checking pending request
+const approvalPollInterval = 30
+
+// statusError is a non-final state of the command
+type statusError struct {
+	code       int
+	State      string `json:"state"`
+	RequestID  string `json:"request_id,omitempty"`
+	RetryAfter int    `json:"retry_after,omitempty"`
+	Message    string `json:"message"`
+}
+
+func (e *statusError) Error() string {
+	return e.Message
+}
+
+func pendingApproval(requestID string) error {
+	return &statusError{
+		code:       ExitPendingApproval,
+		State:      "pending_approval",
+		RequestID:  requestID,
+		RetryAfter: approvalPollInterval,
+		Message:    "request " + requestID + " is pending approval",
+	}
+}
+
+// mfaErrorCode is PrivX error code of missing or invalid MFA code,
+// e.g. MFA_REQUIRED or INVALID_TOKENCODE
+var mfaErrorCode = regexp.MustCompile(`(^|_)(MFA|TOKENCODE)(_|$)`)
+
+// isMFAChallenge recognizes MFA challenge of PrivX API by its error code
+func isMFAChallenge(err error) bool {
+	var api *apiError
+	return errors.As(err, &api) && mfaErrorCode.MatchString(api.Code)
+}
+
+// mfaRequired converts MFA challenge to status error
+func mfaRequired(err error) error {
+	if !isMFAChallenge(err) {
+		return err
+	}
+
+	return &statusError{
+		code:    ExitMFARequired,
+		State:   "mfa_required",
+		Message: "multi-factor authentication code is required: " + err.Error(),
+	}
+}
+
+// ExitCode maps error returned by Execute to process exit status. Exit
+// status of child process, e.g. secrets exec, is passed through.
+func ExitCode(err error) int {
+	var status *statusError
+	var child *exec.ExitError
+
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &status):
+		return status.code
+	case errors.As(err, &child) && child.ExitCode() > 0:
+		return child.ExitCode()
+	case errors.Is(err, context.Canceled):
+		return ExitInterrupted
+	}
+
+	return ExitError
+}
+
+// writeStatus prints structured state of the command
+func writeStatus(err error) {
+	var status *statusError
+	if errors.As(err, &status) {
+		json.NewEncoder(os.Stdout).Encode(status)
+	}
+}
@@ -0,0 +1,37 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestIsMFAChallenge(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"mfa required", sdkError(http.StatusForbidden, `{"error_code":"MFA_REQUIRED"}`), true},
+		{"invalid tokencode", sdkError(http.StatusForbidden, `{"error_code":"INVALID_TOKENCODE","error_message":"invalid code"}`), true},
+		{"mfa in message", sdkError(http.StatusForbidden, `{"error_code":"FORBIDDEN","error_message":"role requires MFA"}`), false},
+		{"similar code", sdkError(http.StatusBadRequest, `{"error_code":"MFAX_SETTINGS"}`), false},
+		{"status only", sdkError(http.StatusForbidden, ""), false},
+		{"plain error", errors.New("MFA_REQUIRED"), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := newAPIError(http.MethodPost, "/role-store/api/v1/roles/%s/awstoken", test.err)
+			if got := isMFAChallenge(err); got != test.want {
+				t.Errorf("isMFAChallenge(%q) = %v, want %v", err, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// targetDomainsPath is REST API of target domains, the SDK has no client
+// for it yet
+const targetDomainsPath = "/role-store/api/v1/targetdomains"
+
+type targetDomainOptions struct {
+	domainID string
+	offset   int
+	limit    int
+}
+
+func init() {
+	rootCmd.AddCommand(targetDomainsCmd())
+}
+
+//
+//
+func targetDomainsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "target-domains",
+		Short: "Manage target domains and brokered AD accounts",
+		Long: `Manage target domains, e.g. Active Directory domains whose accounts PrivX
+brokers for Windows connections`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(targetDomainListCmd())
+	cmd.AddCommand(targetDomainShowCmd())
+	cmd.AddCommand(targetDomainCreateCmd())
+	cmd.AddCommand(targetDomainDeleteCmd())
+	cmd.AddCommand(targetDomainAccountsCmd())
+
+	return cmd
+}
+
+//
+//
+func targetDomainListCmd() *cobra.Command {
+	options := targetDomainOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List target domains",
+		Long:  `List target domains`,
+		Example: `
+	privx-cli target-domains list [access flags] --offset <OFFSET> --limit <LIMIT>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return targetDomainList(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.IntVar(&options.offset, "offset", 0, "where to start fetching the items")
+	flags.IntVar(&options.limit, "limit", 50, "number of items to return")
+
+	return cmd
+}
+
+func targetDomainList(options targetDomainOptions) error {
+	var page struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+
+	_, err := curl().URL(targetDomainsPath).
+		Query(pageQuery{Offset: options.offset, Limit: options.limit}).
+		Get(&page)
+	if err != nil {
+		return apiUnsupported(err, "target domains")
+	}
+
+	return stdout(page.Items)
+}
+
+//
+//
+func targetDomainShowCmd() *cobra.Command {
+	options := targetDomainOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Get target domain by ID",
+		Long:  `Get target domain by ID. Target domain ID's are separated by commas when using multiple values, see example`,
+		Example: `
+	privx-cli target-domains show [access flags] --id <DOMAIN-ID>,<DOMAIN-ID>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return targetDomainShow(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.domainID, "id", "", "target domain ID")
+	cmd.MarkFlagRequired("id")
+
+	return cmd
+}
+
+func targetDomainShow(options targetDomainOptions) error {
+	domains := []map[string]interface{}{}
+
+	for _, id := range strings.Split(options.domainID, ",") {
+		domain := map[string]interface{}{}
+		_, err := curl().URL(targetDomainsPath + "/" + url.PathEscape(id)).Get(&domain)
+		if err != nil {
+			return apiUnsupported(err, "target domains")
+		}
+		domains = append(domains, domain)
+	}
+
+	return stdout(domains)
+}
+
+//
+//
+func targetDomainCreateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create new target domain",
+		Long:  `Create new target domain`,
+		Example: `
+	privx-cli target-domains create [access flags] JSON-FILE
+		`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return targetDomainCreate(args)
+		},
+	}
+
+	return bulkCmd(cmd)
+}
+
+func targetDomainCreate(args []string) error {
+	var domain map[string]interface{}
+
+	err := decodeJSON(args[0], &domain)
+	if err != nil {
+		return err
+	}
+
+	var created struct {
+		ID string `json:"id"`
+	}
+	_, err = curl().URL(targetDomainsPath).Post(domain, &created)
+	if err != nil {
+		return apiUnsupported(err, "target domains")
+	}
+
+	return stdout(created.ID)
+}
+
+//
+//
+func targetDomainDeleteCmd() *cobra.Command {
+	options := targetDomainOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete target domain",
+		Long:  `Delete target domain. Target domain ID's are separated by commas when using multiple values, see example`,
+		Example: `
+	privx-cli target-domains delete [access flags] --id <DOMAIN-ID>,<DOMAIN-ID>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return targetDomainDelete(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.domainID, "id", "", "target domain ID")
+	cmd.MarkFlagRequired("id")
+
+	return cmd
+}
+
+func targetDomainDelete(options targetDomainOptions) error {
+	for _, id := range strings.Split(options.domainID, ",") {
+		_, err := curl().URL(targetDomainsPath + "/" + url.PathEscape(id)).Delete()
+		if err != nil {
+			return apiUnsupported(err, "target domains")
+		}
+		info("%s", id)
+	}
+
+	return nil
+}
+
+//
+//
+func targetDomainAccountsCmd() *cobra.Command {
+	options := targetDomainOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "accounts",
+		Short: "List accounts of target domain",
+		Long:  `List accounts of target domain discovered by PrivX, including the accounts brokered to users`,
+		Example: `
+	privx-cli target-domains accounts [access flags] --id <DOMAIN-ID>
+	privx-cli target-domains accounts [access flags] --id <DOMAIN-ID> --offset <OFFSET> --limit <LIMIT>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return targetDomainAccounts(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.domainID, "id", "", "target domain ID")
+	flags.IntVar(&options.offset, "offset", 0, "where to start fetching the items")
+	flags.IntVar(&options.limit, "limit", 50, "number of items to return")
+	cmd.MarkFlagRequired("id")
+
+	return cmd
+}
+
+func targetDomainAccounts(options targetDomainOptions) error {
+	var page struct {
+		Items []map[string]interface{} `json:"items"`
+	}
+
+	_, err := curl().URL(targetDomainsPath + "/" + url.PathEscape(options.domainID) + "/accounts").
+		Query(pageQuery{Offset: options.offset, Limit: options.limit}).
+		Get(&page)
+	if err != nil {
+		return apiUnsupported(err, "target domains")
+	}
+
+	return stdout(page.Items)
+}
@@ -0,0 +1,168 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/api/connectionmanager"
+	"github.com/spf13/cobra"
+)
+
+type trailOptions struct {
+	olderThan string
+}
+
+func init() {
+	rootCmd.AddCommand(trailsCmd())
+}
+
+//
+//
+func trailsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "trails",
+		Short:        "Manage session trail storage",
+		Long:         `Report trail storage consumption and manage trail retention`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(trailStorageStatusCmd())
+	cmd.AddCommand(trailPurgeCmd())
+
+	return cmd
+}
+
+//
+//
+func trailStorageStatusCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "storage-status",
+		Short: "Show trail storage consumption",
+		Long:  `Show trail storage consumption reported by connection manager`,
+		Example: `
+	privx-cli trails storage-status [access flags]
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return trailStorageStatus()
+		},
+	}
+
+	return cmd
+}
+
+func trailStorageStatus() error {
+	status := map[string]interface{}{}
+
+	_, err := curl().
+		URL("/connection-manager/api/v1/trails/storage").
+		Get(&status)
+	if err != nil {
+		return apiUnsupported(err, "trail storage status")
+	}
+
+	return stdout(status)
+}
+
+//
+//
+func trailPurgeCmd() *cobra.Command {
+	options := trailOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "purge",
+		Short: "Remove trails of old connections",
+		Long: `Remove trails of connections ended before the retention period. Use --dry-run
+to list the connections first.`,
+		Example: `
+	privx-cli trails purge [access flags] --older-than 180d --dry-run
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return trailPurge(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.olderThan, "older-than", "", "retention period, e.g. 180d")
+	cmd.MarkFlagRequired("older-than")
+
+	return cmd
+}
+
+// trailView is a connection with trail
+type trailView struct {
+	ID           string    `json:"id"`
+	Type         string    `json:"type"`
+	Connected    time.Time `json:"connected"`
+	Disconnected time.Time `json:"disconnected"`
+	TrailRemoved bool      `json:"trail_removed"`
+}
+
+type trailPurgeResult struct {
+	Cutoff  time.Time   `json:"cutoff"`
+	DryRun  bool        `json:"dry_run"`
+	Removed []trailView `json:"removed"`
+}
+
+func trailPurge(options trailOptions) error {
+	cutoff, err := parseTimeFlag(options.olderThan)
+	if err != nil {
+		return err
+	}
+
+	var search connectionmanager.ConnectionSearch
+	if err := remarshal(map[string]interface{}{
+		"connected": map[string]time.Time{"end": cutoff},
+	}, &search); err != nil {
+		return err
+	}
+
+	api := connectionmanager.New(curl())
+	limit := 100
+	candidates := []trailView{}
+
+	for offset := 0; ; offset += limit {
+		page, err := api.SearchConnections(offset, limit, "ASC", "connected", search)
+		if err != nil {
+			return err
+		}
+
+		var view []trailView
+		if err := remarshal(page, &view); err != nil {
+			return err
+		}
+
+		for _, conn := range view {
+			if conn.TrailRemoved || conn.Disconnected.IsZero() || conn.Disconnected.After(cutoff) {
+				continue
+			}
+			candidates = append(candidates, conn)
+		}
+
+		if len(view) < limit {
+			break
+		}
+	}
+
+	result := trailPurgeResult{Cutoff: cutoff, DryRun: dryRun, Removed: []trailView{}}
+	for _, conn := range candidates {
+		if !dryRun {
+			_, err := curl().
+				URL("/connection-manager/api/v1/connections/" + url.PathEscape(conn.ID) + "/trail").
+				Delete()
+			if err != nil {
+				return apiUnsupported(err, "trail removal")
+			}
+		}
+		result.Removed = append(result.Removed, conn)
+	}
+
+	return stdout(result)
+}
@@ -8,11 +8,18 @@ package cmd
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/SSHcom/privx-sdk-go/api/authorizer"
 	"github.com/SSHcom/privx-sdk-go/api/userstore"
 	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
 )
 
 type trustedClientOptions struct {
@@ -21,12 +28,47 @@ type trustedClientOptions struct {
 	fileName        string
 	clientType      string
 	trustedClientID string
+	secretOut       string
+	outDir          string
+	secretTTL       string
+	name            string
+	parallel        int
+	unclaimed       bool
+	expired         bool
+	generate        bool
 }
 
 func (m trustedClientOptions) normalizeClientType() string {
 	return strings.ToUpper(m.clientType)
 }
 
+// trustedClientType maps CLI client type to PrivX client type
+func (m trustedClientOptions) trustedClientType() (string, error) {
+	switch m.clientType {
+	case "":
+		return "", nil
+	case "extender", "carrier":
+		return m.normalizeClientType(), nil
+	case "webproxy":
+		return "ICAP", nil
+	}
+
+	return "", fmt.Errorf("client type does not exist: %s", m.clientType)
+}
+
+// trustedClientPermissions are default permissions of generated clients
+var trustedClientPermissions = map[string][]string{
+	"EXTENDER": {"privx-extender"},
+	"CARRIER":  {"privx-carrier"},
+	"ICAP":     {"privx-web-proxy"},
+}
+
+// instance specific attributes are not exported
+var trustedClientInstanceFields = []string{
+	"id", "secret", "registered", "created", "updated",
+	"author", "updated_by", "registration_token", "extender_address",
+}
+
 func init() {
 	rootCmd.AddCommand(trustedClientsCmd())
 }
@@ -36,17 +78,24 @@ func init() {
 func trustedClientsCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:          "trusted-clients",
-		Short:        "List trusted clients and donwload pre configs",
-		Long:         `List trusted clients and donwload pre configs`,
+		Short:        "Manage trusted clients and download pre configs",
+		Long:         `Manage trusted clients (extender | web-proxy | carrier) and download pre configs`,
 		SilenceUsage: true,
 	}
 
 	cmd.AddCommand(caListCmd())
 	cmd.AddCommand(caShowCmd())
 	cmd.AddCommand(revocationListCmd())
+	cmd.AddCommand(refreshCRLsCmd())
 	cmd.AddCommand(trustedClientListCmd())
 	cmd.AddCommand(trustedClientShowCmd())
+	cmd.AddCommand(trustedClientCreateCmd())
+	cmd.AddCommand(trustedClientUpdateCmd())
+	cmd.AddCommand(trustedClientDeleteCmd())
+	cmd.AddCommand(trustedClientRegenerateSecretCmd())
 	cmd.AddCommand(preconfigurationDownloadCmd())
+	cmd.AddCommand(trustedClientExportCmd())
+	cmd.AddCommand(trustedClientImportCmd())
 
 	return cmd
 }
@@ -59,10 +108,14 @@ func trustedClientListCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "list",
 		Short: "List trusted clients",
-		Long:  `List trusted clients (extender | web-proxy | carrier)`,
+		Long: `List trusted clients (extender | web-proxy | carrier). With --unclaimed only clients
+whose registration was never completed by the component are listed, with --expired
+only unclaimed clients whose registration secret is older than --secret-ttl.`,
 		Example: `
 	privx-cli trusted-clients [access flags] --type extender | webproxy | carrier
 	privx-cli trusted-clients [access flags] --group-id <ACCESS-GROUP-ID> --type extender | webproxy | carrier
+	privx-cli trusted-clients list [access flags] --unclaimed
+	privx-cli trusted-clients list [access flags] --type extender --expired --secret-ttl 48h
 		`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -71,40 +124,64 @@ func trustedClientListCmd() *cobra.Command {
 	}
 
 	flags := cmd.Flags()
-	flags.StringVar(&options.clientType, "type", "", " trusted client type")
-	cmd.MarkFlagRequired("type")
+	flags.StringVar(&options.clientType, "type", "", "trusted client type, all types if omitted")
+	flags.BoolVar(&options.unclaimed, "unclaimed", false, "list clients that never completed registration")
+	flags.BoolVar(&options.expired, "expired", false, "list unclaimed clients with expired registration secret")
+	flags.StringVar(&options.secretTTL, "secret-ttl", "24h", "validity of registration secret used by --expired")
 
 	return cmd
 }
 
 func trustedClientList(options trustedClientOptions) error {
-	var clients []userstore.TrustedClient
 	api := userstore.New(curl())
 
+	clientType, err := options.trustedClientType()
+	if err != nil {
+		return err
+	}
+
 	res, err := api.TrustedClients()
 	if err != nil {
 		return err
 	}
 
-	switch options.clientType {
-	case "extender":
-		clients = trustedClientListHelper(res, options.normalizeClientType())
-	case "webproxy":
-		clients = trustedClientListHelper(res, "ICAP")
-	case "carrier":
-		clients = trustedClientListHelper(res, options.normalizeClientType())
-	default:
-		return fmt.Errorf("client type does not exist: %s", options.clientType)
+	clients := trustedClientListHelper(res, clientType)
+	if !options.unclaimed && !options.expired {
+		return stdout(clients)
 	}
 
-	return stdout(clients)
+	ttl, err := parseDurationFlag(options.secretTTL)
+	if err != nil {
+		return err
+	}
+
+	filtered := []userstore.TrustedClient{}
+	for _, client := range clients {
+		var view struct {
+			Registered bool      `json:"registered"`
+			Created    time.Time `json:"created"`
+		}
+		if err := remarshal(client, &view); err != nil {
+			return err
+		}
+
+		if view.Registered {
+			continue
+		}
+		if options.expired && time.Since(view.Created) < ttl {
+			continue
+		}
+		filtered = append(filtered, client)
+	}
+
+	return stdout(filtered)
 }
 
 func trustedClientListHelper(trustedClients []userstore.TrustedClient, clientType string) []userstore.TrustedClient {
 	clients := []userstore.TrustedClient{}
 
 	for _, client := range trustedClients {
-		if client.Type == userstore.ClientType(clientType) {
+		if clientType == "" || client.Type == userstore.ClientType(clientType) {
 			clients = append(clients, client)
 		}
 	}
@@ -112,6 +189,72 @@ func trustedClientListHelper(trustedClients []userstore.TrustedClient, clientTyp
 	return clients
 }
 
+//
+//
+func trustedClientRegenerateSecretCmd() *cobra.Command {
+	options := trustedClientOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "regenerate-secret",
+		Short: "Regenerate registration secret of trusted client",
+		Long: `Regenerate registration secret of trusted client, e.g. after failed enrollment of
+extender or web-proxy. The client must register again with the new secret.`,
+		Example: `
+	privx-cli trusted-clients regenerate-secret [access flags] --client-id <TRUSTED-CLIENT-ID>
+	privx-cli trusted-clients regenerate-secret [access flags] --client-id <TRUSTED-CLIENT-ID> --secret-out extender.secret
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return trustedClientRegenerateSecret(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.trustedClientID, "client-id", "", "trusted client ID")
+	flags.StringVar(&options.secretOut, "secret-out", "-", "write new secret to file, - for stdout")
+	cmd.MarkFlagRequired("client-id")
+
+	return cmd
+}
+
+func trustedClientRegenerateSecret(options trustedClientOptions) error {
+	api := userstore.New(curl())
+
+	client, err := api.TrustedClient(options.trustedClientID)
+	if err != nil {
+		return err
+	}
+
+	var definition map[string]interface{}
+	if err := remarshal(client, &definition); err != nil {
+		return err
+	}
+	previous, _ := definition["secret"].(string)
+
+	// PrivX issues new registration secret for client that is not registered
+	delete(definition, "secret")
+	definition["registered"] = false
+
+	var reset userstore.TrustedClient
+	if err := remarshal(definition, &reset); err != nil {
+		return err
+	}
+
+	if err := api.UpdateTrustedClient(options.trustedClientID, &reset); err != nil {
+		return err
+	}
+
+	secret, err := trustedClientSecret(options.trustedClientID)
+	if err != nil {
+		return err
+	}
+	if secret == previous {
+		return fmt.Errorf("secret regeneration of trusted client is not supported by this PrivX server")
+	}
+
+	return writeSecret(options.secretOut, []byte(secret+"\n"))
+}
+
 //
 //
 func trustedClientShowCmd() *cobra.Command {
@@ -148,6 +291,169 @@ func trustedClientShow(options trustedClientOptions) error {
 	return stdout(client)
 }
 
+//
+//
+func trustedClientCreateCmd() *cobra.Command {
+	options := trustedClientOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "create",
+		Short: "Create new trusted client",
+		Long: `Create new trusted client from JSON file, or with --generate a client of the type
+with default permissions. The ID of the new client is printed, except with --generate,
+which prints the registration secret used to register the component. Use --secret-out
+to save the secret to file (mode 0600) instead.`,
+		Example: `
+	privx-cli trusted-clients create [access flags] JSON-FILE
+	privx-cli trusted-clients create [access flags] --generate --type extender --name extender-eu-1
+	privx-cli trusted-clients create [access flags] --generate --type webproxy --name proxy-1 --group-id <ACCESS-GROUP-ID> --secret-out proxy-1.secret
+		`,
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return trustedClientCreate(options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&options.generate, "generate", false, "generate client of --type with default settings")
+	flags.StringVar(&options.clientType, "type", "", "trusted client type of generated client")
+	flags.StringVar(&options.name, "name", "", "name of generated client")
+	flags.StringVar(&options.accessGroupID, "group-id", "", "access group ID of generated client")
+	flags.StringVar(&options.secretOut, "secret-out", "", "write registration secret to file, - for stdout")
+
+	return bulkCmd(cmd)
+}
+
+func trustedClientCreate(options trustedClientOptions, args []string) error {
+	if !options.generate {
+		if len(args) != 1 {
+			return fmt.Errorf("JSON-FILE is required unless --generate is used")
+		}
+		return clientCreate(clientOptions{secretOut: options.secretOut}, args)
+	}
+
+	if len(args) > 0 {
+		return fmt.Errorf("--generate does not accept JSON-FILE")
+	}
+	if options.name == "" {
+		return fmt.Errorf("--generate requires --name")
+	}
+
+	clientType, err := options.trustedClientType()
+	if err != nil {
+		return err
+	}
+	if clientType == "" {
+		return fmt.Errorf("--generate requires --type extender | webproxy | carrier")
+	}
+
+	definition := map[string]interface{}{
+		"type":        clientType,
+		"name":        options.name,
+		"permissions": trustedClientPermissions[clientType],
+	}
+	if options.accessGroupID != "" {
+		definition["group_id"] = options.accessGroupID
+	}
+
+	var client userstore.TrustedClient
+	if err := remarshal(definition, &client); err != nil {
+		return err
+	}
+
+	id, err := userstore.New(curl()).CreateTrustedClient(client)
+	if err != nil {
+		return err
+	}
+
+	secret, err := trustedClientSecret(id)
+	if err != nil {
+		return err
+	}
+
+	if options.secretOut == "" {
+		options.secretOut = "-"
+	}
+	if options.secretOut != "-" {
+		info("trusted client %s created", id)
+	}
+
+	return writeSecret(options.secretOut, []byte(secret+"\n"))
+}
+
+//
+//
+func trustedClientUpdateCmd() *cobra.Command {
+	options := trustedClientOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "Update trusted client",
+		Long:  `Update trusted client from JSON file`,
+		Example: `
+	privx-cli trusted-clients update [access flags] --client-id <TRUSTED-CLIENT-ID> JSON-FILE
+		`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return trustedClientUpdate(options, args)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.trustedClientID, "client-id", "", "trusted client ID")
+	cmd.MarkFlagRequired("client-id")
+
+	return cmd
+}
+
+func trustedClientUpdate(options trustedClientOptions, args []string) error {
+	var updateClient userstore.TrustedClient
+	api := userstore.New(curl())
+
+	err := decodeJSON(args[0], &updateClient)
+	if err != nil {
+		return err
+	}
+
+	current, err := api.TrustedClient(options.trustedClientID)
+	if err != nil {
+		return err
+	}
+
+	if err := confirmUpdate(current, &updateClient); err != nil {
+		return err
+	}
+
+	return api.UpdateTrustedClient(options.trustedClientID, &updateClient)
+}
+
+//
+//
+func trustedClientDeleteCmd() *cobra.Command {
+	options := trustedClientOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "delete",
+		Short: "Delete trusted client",
+		Long:  `Delete trusted client. Client ID's are separated by commas when using multiple values, see example`,
+		Example: `
+	privx-cli trusted-clients delete [access flags] --client-id <TRUSTED-CLIENT-ID>,<TRUSTED-CLIENT-ID>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return clientDelete(clientOptions{trustedClientID: options.trustedClientID})
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.trustedClientID, "client-id", "", "trusted client ID")
+	cmd.MarkFlagRequired("client-id")
+
+	return cmd
+}
+
 //
 //
 func caListCmd() *cobra.Command {
@@ -305,7 +611,9 @@ func revocationListCmd() *cobra.Command {
 func extenderRevocationList(options trustedClientOptions) error {
 	api := authorizer.New(curl())
 
-	err := api.DownloadExtenderCertificateCRL(options.fileName, options.trustedClientID)
+	err := downloadFile(options.fileName, func() error {
+		return api.DownloadExtenderCertificateCRL(options.fileName, options.trustedClientID)
+	})
 	if err != nil {
 		return err
 	}
@@ -316,7 +624,9 @@ func extenderRevocationList(options trustedClientOptions) error {
 func webproxyRevocationList(options trustedClientOptions) error {
 	api := authorizer.New(curl())
 
-	err := api.DownloadWebProxyCertificateCRL(options.fileName, options.trustedClientID)
+	err := downloadFile(options.fileName, func() error {
+		return api.DownloadWebProxyCertificateCRL(options.fileName, options.trustedClientID)
+	})
 	if err != nil {
 		return err
 	}
@@ -324,6 +634,149 @@ func webproxyRevocationList(options trustedClientOptions) error {
 	return nil
 }
 
+//
+//
+func refreshCRLsCmd() *cobra.Command {
+	options := trustedClientOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "refresh-crls",
+		Short: "Download revocation lists of all extenders and web-proxies",
+		Long: `Download current revocation lists of all extenders and web-proxies to directory.
+Files are named <type>-<client name>-<client id>.crl. Lists are downloaded
+concurrently, failed downloads are reported and make the command fail.`,
+		Example: `
+	privx-cli trusted-clients refresh-crls [access flags] --out-dir ./crls
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return refreshCRLs(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.outDir, "out-dir", "", "output directory")
+	flags.IntVar(&options.parallel, "parallel", 4, "number of concurrent downloads")
+	cmd.MarkFlagRequired("out-dir")
+
+	return cmd
+}
+
+type crlDownload struct {
+	ClientID string `json:"client_id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	File     string `json:"file,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func refreshCRLs(options trustedClientOptions) error {
+	downloads, err := downloadCRLs(options.outDir, options.parallel)
+	if err != nil {
+		return err
+	}
+
+	if err := stdout(downloads); err != nil {
+		return err
+	}
+
+	return failedCRLs(downloads)
+}
+
+// downloadCRLs downloads revocation lists of extenders and web-proxies
+// to directory, failures are reported per download
+func downloadCRLs(outDir string, parallel int) ([]crlDownload, error) {
+	if parallel < 1 {
+		return nil, fmt.Errorf("invalid --parallel: %d", parallel)
+	}
+
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return nil, err
+	}
+
+	clients, err := userstore.New(curl()).TrustedClients()
+	if err != nil {
+		return nil, err
+	}
+
+	downloads := []crlDownload{}
+	for _, client := range clients {
+		var kind string
+		switch client.Type {
+		case userstore.ClientType("EXTENDER"):
+			kind = "extender"
+		case userstore.ClientType("ICAP"):
+			kind = "webproxy"
+		default:
+			continue
+		}
+
+		name := unsafeFileChars.ReplaceAllString(client.Name, "_")
+		downloads = append(downloads, crlDownload{
+			ClientID: client.ID,
+			Name:     client.Name,
+			Type:     kind,
+			File:     filepath.Join(outDir, fmt.Sprintf("%s-%s-%s.crl", kind, name, client.ID)),
+		})
+	}
+
+	api := authorizer.New(curl())
+	queue := make(chan int)
+	wg := sync.WaitGroup{}
+
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				item := &downloads[i]
+
+				err := downloadFile(item.File, func() error {
+					if item.Type == "extender" {
+						return api.DownloadExtenderCertificateCRL(item.File, item.ClientID)
+					}
+					return api.DownloadWebProxyCertificateCRL(item.File, item.ClientID)
+				})
+
+				if err != nil {
+					item.Error = err.Error()
+					item.File = ""
+				}
+			}
+		}()
+	}
+
+	for i := range downloads {
+		if interrupted() != nil {
+			downloads[i].Error = "interrupted"
+			downloads[i].File = ""
+			continue
+		}
+		queue <- i
+	}
+	close(queue)
+	wg.Wait()
+
+	return downloads, nil
+}
+
+func failedCRLs(downloads []crlDownload) error {
+	failed := 0
+	for _, download := range downloads {
+		if download.Error != "" {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d revocation lists failed", failed, len(downloads))
+	}
+
+	return nil
+}
+
 //
 //
 func preconfigurationDownloadCmd() *cobra.Command {
@@ -375,7 +828,9 @@ func downloadExtenderPreConf(options trustedClientOptions) error {
 		return err
 	}
 
-	err = api.DownloadExtenderConfig(options.trustedClientID, handler.SessionID, options.fileName)
+	err = downloadFile(options.fileName, func() error {
+		return api.DownloadExtenderConfig(options.trustedClientID, handler.SessionID, options.fileName)
+	})
 	if err != nil {
 		return err
 	}
@@ -391,7 +846,9 @@ func downloadWebProxyPreConf(options trustedClientOptions) error {
 		return err
 	}
 
-	err = api.DownloadWebProxyConfig(options.trustedClientID, handler.SessionID, options.fileName)
+	err = downloadFile(options.fileName, func() error {
+		return api.DownloadWebProxyConfig(options.trustedClientID, handler.SessionID, options.fileName)
+	})
 	if err != nil {
 		return err
 	}
@@ -407,10 +864,154 @@ func downloadCarrierPreConf(options trustedClientOptions) error {
 		return err
 	}
 
-	err = api.DownloadCarrierConfig(options.trustedClientID, handler.SessionID, options.fileName)
+	err = downloadFile(options.fileName, func() error {
+		return api.DownloadCarrierConfig(options.trustedClientID, handler.SessionID, options.fileName)
+	})
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+//
+//
+func trustedClientExportCmd() *cobra.Command {
+	options := trustedClientOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export trusted client definitions to YAML file",
+		Long:  `Export trusted client definitions to YAML file, instance specific attributes and secrets are not exported`,
+		Example: `
+	privx-cli trusted-clients export [access flags] --type extender | webproxy | carrier --file <FILE-NAME>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return trustedClientExport(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.clientType, "type", "", "trusted client type")
+	flags.StringVar(&options.fileName, "file", "", "file name")
+	cmd.MarkFlagRequired("type")
+	cmd.MarkFlagRequired("file")
+
+	return cmd
+}
+
+func trustedClientExport(options trustedClientOptions) error {
+	api := userstore.New(curl())
+
+	clientType, err := options.trustedClientType()
+	if err != nil {
+		return err
+	}
+
+	res, err := api.TrustedClients()
+	if err != nil {
+		return err
+	}
+
+	var definitions []map[string]interface{}
+	err = remarshal(trustedClientListHelper(res, clientType), &definitions)
+	if err != nil {
+		return err
+	}
+
+	for _, definition := range definitions {
+		for _, field := range trustedClientInstanceFields {
+			delete(definition, field)
+		}
+	}
+
+	data, err := yaml.Marshal(definitions)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(options.fileName, data, 0600)
+}
+
+//
+//
+func trustedClientImportCmd() *cobra.Command {
+	options := trustedClientOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Recreate trusted clients from YAML file",
+		Long: `Recreate trusted clients from YAML file created by export. New registration secrets
+are generated by PrivX, use pre-config to download configuration of the new clients
+or --secret-out to save the secrets as YAML map of client name to secret. Secrets
+are never printed in the normal output.`,
+		Example: `
+	privx-cli trusted-clients import [access flags] --file <FILE-NAME>
+	privx-cli trusted-clients import [access flags] --file <FILE-NAME> --secret-out secrets.yaml
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return trustedClientImport(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.fileName, "file", "", "file name")
+	flags.StringVar(&options.secretOut, "secret-out", "", "write registration secrets to file, - for stdout")
+	cmd.MarkFlagRequired("file")
+
+	return cmd
+}
+
+func trustedClientImport(options trustedClientOptions) error {
+	data, err := ioutil.ReadFile(options.fileName)
+	if err != nil {
+		return err
+	}
+
+	var definitions []map[string]interface{}
+	if err := yaml.Unmarshal(stripBOM(data), &definitions); err != nil {
+		return err
+	}
+
+	api := userstore.New(curl())
+	created := []userstore.TrustedClient{}
+	secrets := map[string]string{}
+
+	for _, definition := range definitions {
+		var client userstore.TrustedClient
+		if err := remarshal(definition, &client); err != nil {
+			return err
+		}
+
+		id, err := api.CreateTrustedClient(client)
+		if err != nil {
+			return fmt.Errorf("trusted client %s: %w", client.Name, err)
+		}
+
+		client.ID = id
+		created = append(created, client)
+
+		if options.secretOut != "" {
+			if secrets[client.Name], err = trustedClientSecret(id); err != nil {
+				return err
+			}
+		}
+	}
+
+	if options.secretOut != "" {
+		data, err := yaml.Marshal(secrets)
+		if err != nil {
+			return err
+		}
+		if err := writeSecret(options.secretOut, data); err != nil {
+			return err
+		}
+		if options.secretOut == "-" {
+			return nil
+		}
+	}
+
+	return stdout(created)
+}
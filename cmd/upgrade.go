@@ -0,0 +1,302 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/api/licensemanager"
+	"github.com/SSHcom/privx-sdk-go/api/settings"
+	"github.com/SSHcom/privx-sdk-go/api/userstore"
+	"github.com/spf13/cobra"
+)
+
+type upgradeOptions struct {
+	targetVersion  string
+	settingsScopes []string
+}
+
+const (
+	checkPass = "pass"
+	checkWarn = "warn"
+	checkFail = "fail"
+)
+
+type preflightCheck struct {
+	Check   string `json:"check"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+type preflightReport struct {
+	CurrentVersion string           `json:"current_version"`
+	TargetVersion  string           `json:"target_version"`
+	Go             bool             `json:"go"`
+	Checks         []preflightCheck `json:"checks"`
+}
+
+func init() {
+	rootCmd.AddCommand(upgradeCmd())
+}
+
+//
+//
+func upgradeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "upgrade",
+		Short:        "Prepare PrivX upgrades",
+		Long:         `Prepare PrivX upgrades`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(upgradePreflightCmd())
+
+	return cmd
+}
+
+//
+//
+func upgradePreflightCmd() *cobra.Command {
+	options := upgradeOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "preflight",
+		Short: "Check readiness for PrivX upgrade",
+		Long: `Check server version, version consistency of nodes, deprecated settings in use,
+trusted client versions and license validity. Produces go/no-go report and
+exits non-zero on no-go.`,
+		Example: `
+	privx-cli upgrade preflight [access flags] --target-version 22.1
+	privx-cli upgrade preflight [access flags] --target-version 22.1 --settings-scope GLOBAL,AUTH
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return upgradePreflight(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.targetVersion, "target-version", "", "PrivX version to upgrade to, e.g. 22.1")
+	flags.StringSliceVar(&options.settingsScopes, "settings-scope", []string{"GLOBAL"}, "settings scopes checked for deprecated settings")
+	cmd.MarkFlagRequired("target-version")
+
+	return cmd
+}
+
+func upgradePreflight(options upgradeOptions) error {
+	report := preflightReport{TargetVersion: options.targetVersion, Checks: []preflightCheck{}}
+	check := func(name, status, format string, args ...interface{}) {
+		report.Checks = append(report.Checks, preflightCheck{name, status, fmt.Sprintf(format, args...)})
+	}
+
+	nodes, err := instanceNodes()
+	if err != nil {
+		return err
+	}
+
+	versions := map[string]bool{}
+	for _, node := range nodes {
+		for _, version := range node.Versions {
+			versions[version] = true
+		}
+	}
+	for version := range versions {
+		if report.CurrentVersion == "" || compareVersions(version, report.CurrentVersion) < 0 {
+			report.CurrentVersion = version
+		}
+	}
+
+	switch {
+	case report.CurrentVersion == "":
+		check("server-version", checkWarn, "server version is unknown")
+	case compareVersions(options.targetVersion, report.CurrentVersion) <= 0:
+		check("server-version", checkFail, "target version %s is not newer than %s", options.targetVersion, report.CurrentVersion)
+	default:
+		check("server-version", checkPass, "upgrade from %s to %s", report.CurrentVersion, options.targetVersion)
+	}
+
+	if len(versions) > 1 {
+		check("node-versions", checkFail, "nodes run mixed versions, finish previous upgrade first")
+	} else {
+		check("node-versions", checkPass, "%d node(s) on the same version", len(nodes))
+	}
+
+	deprecated := []string{}
+	for _, scope := range options.settingsScopes {
+		names, err := deprecatedSettings(strings.ToUpper(scope))
+		if err != nil {
+			return err
+		}
+		deprecated = append(deprecated, names...)
+	}
+	if len(deprecated) > 0 {
+		check("deprecations", checkWarn, "deprecated settings in use: %s", strings.Join(deprecated, ", "))
+	} else {
+		check("deprecations", checkPass, "no deprecated settings in use")
+	}
+
+	clients, err := userstore.New(curl()).TrustedClients()
+	if err != nil {
+		return err
+	}
+
+	var clientView []struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+	if err := remarshal(clients, &clientView); err != nil {
+		return err
+	}
+
+	outdated := []string{}
+	for _, client := range clientView {
+		if client.Version != "" && report.CurrentVersion != "" &&
+			compareVersions(client.Version, report.CurrentVersion) < 0 {
+			outdated = append(outdated, client.Name+" "+client.Version)
+		}
+	}
+	if len(outdated) > 0 {
+		check("trusted-clients", checkWarn, "trusted clients older than server: %s", strings.Join(outdated, ", "))
+	} else {
+		check("trusted-clients", checkPass, "%d trusted client(s) up to date", len(clientView))
+	}
+
+	license, err := licensemanager.New(curl()).License()
+	if err != nil {
+		return err
+	}
+
+	var licenseView struct {
+		IsValid    bool   `json:"is_valid"`
+		ExpiryDate string `json:"expiry_date"`
+	}
+	if err := remarshal(license, &licenseView); err != nil {
+		return err
+	}
+
+	expiry, _ := time.Parse(time.RFC3339, licenseView.ExpiryDate)
+	switch {
+	case !licenseView.IsValid:
+		check("license", checkFail, "license is not valid")
+	case !expiry.IsZero() && expiry.Before(time.Now().AddDate(0, 0, 30)):
+		check("license", checkWarn, "license expires at %s", licenseView.ExpiryDate)
+	default:
+		check("license", checkPass, "license is valid")
+	}
+
+	report.Go = true
+	for _, c := range report.Checks {
+		if c.Status == checkFail {
+			report.Go = false
+		}
+	}
+
+	if err := stdout(report); err != nil {
+		return err
+	}
+
+	if !report.Go {
+		return errors.New("upgrade preflight failed")
+	}
+
+	return nil
+}
+
+// deprecatedSettings lists settings of the scope which are set although
+// the schema of the scope marks them deprecated
+func deprecatedSettings(scope string) ([]string, error) {
+	api := settings.New(curl())
+
+	schema, err := api.ScopeSchema(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := api.ScopeSettings(scope, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var schemaView, currentView interface{}
+	if err := remarshal(schema, &schemaView); err != nil {
+		return nil, err
+	}
+	if err := remarshal(current, &currentView); err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	walkDeprecated(schemaView, currentView, scope, &names)
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// walkDeprecated follows the schema and the settings side by side, nested
+// properties of JSON schema are the settings of the object
+func walkDeprecated(schema, value interface{}, path string, names *[]string) {
+	node, ok := schema.(map[string]interface{})
+	if !ok || value == nil {
+		return
+	}
+
+	if deprecated, _ := node["deprecated"].(bool); deprecated {
+		if !reflect.ValueOf(value).IsZero() {
+			*names = append(*names, path)
+		}
+		return
+	}
+
+	if properties, ok := node["properties"].(map[string]interface{}); ok {
+		node = properties
+	}
+
+	values, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	for key, child := range node {
+		walkDeprecated(child, values[key], path+"."+key, names)
+	}
+}
+
+// compareVersions compares dot separated numeric versions, non numeric
+// suffixes are ignored
+func compareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		x, y := versionPart(as, i), versionPart(bs, i)
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func versionPart(parts []string, i int) int {
+	if i >= len(parts) {
+		return 0
+	}
+
+	digits := strings.TrimLeft(parts[i], "v")
+	if end := strings.IndexFunc(digits, func(r rune) bool { return r < '0' || r > '9' }); end >= 0 {
+		digits = digits[:end]
+	}
+
+	n, _ := strconv.Atoi(digits)
+	return n
+}
@@ -0,0 +1,189 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// usageRecord is a line of local usage log. Arguments and flag values
+// are never recorded.
+type usageRecord struct {
+	Time    time.Time `json:"time"`
+	Command string    `json:"command"`
+	Failed  bool      `json:"failed,omitempty"`
+}
+
+type usageSummary struct {
+	Command  string    `json:"command"`
+	Runs     int       `json:"runs"`
+	Failures int       `json:"failures"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+func init() {
+	rootCmd.AddCommand(usageReportCmd())
+}
+
+//
+//
+func usageReportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "usage-report",
+		Short: "Summarize local command usage",
+		Long: `Summarize which commands are run and how often. Usage is recorded only after
+opt-in with usage-report enable, to the local file ~/.privx-cli/usage.log.
+Nothing is sent anywhere.`,
+		Example: `
+	privx-cli usage-report enable
+	privx-cli usage-report
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return usageReport()
+		},
+	}
+
+	cmd.AddCommand(usageEnableCmd(true))
+	cmd.AddCommand(usageEnableCmd(false))
+
+	return cmd
+}
+
+//
+//
+func usageEnableCmd(enable bool) *cobra.Command {
+	use, short := "enable", "Start recording local command usage"
+	if !enable {
+		use, short = "disable", "Stop recording local command usage"
+	}
+
+	cmd := &cobra.Command{
+		Use:          use,
+		Short:        short,
+		Long:         short,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return usageEnable(enable)
+		},
+	}
+
+	return cmd
+}
+
+func usageEnable(enable bool) error {
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+
+	marker := filepath.Join(dir, "usage.enabled")
+	if !enable {
+		if err := os.Remove(marker); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	return writeFileAtomic(marker, []byte{})
+}
+
+func usageReport() error {
+	dir, err := stateDir()
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(filepath.Join(dir, "usage.log"))
+	if os.IsNotExist(err) {
+		return stdout([]usageSummary{})
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	summary := map[string]*usageSummary{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var record usageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			continue
+		}
+
+		s, ok := summary[record.Command]
+		if !ok {
+			s = &usageSummary{Command: record.Command}
+			summary[record.Command] = s
+		}
+		s.Runs++
+		if record.Failed {
+			s.Failures++
+		}
+		if record.Time.After(s.LastUsed) {
+			s.LastUsed = record.Time
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	report := []usageSummary{}
+	for _, s := range summary {
+		report = append(report, *s)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Runs != report[j].Runs {
+			return report[i].Runs > report[j].Runs
+		}
+		return report[i].Command < report[j].Command
+	})
+
+	return stdout(report)
+}
+
+// recordUsage appends executed command to local usage log if user
+// has opted in. Failures to record are silently ignored.
+func recordUsage(cmd *cobra.Command, err error) {
+	if cmd == nil {
+		return
+	}
+
+	dir, derr := stateDir()
+	if derr != nil {
+		return
+	}
+
+	if _, serr := os.Stat(filepath.Join(dir, "usage.enabled")); serr != nil {
+		return
+	}
+
+	line, merr := json.Marshal(usageRecord{
+		Time:    time.Now().UTC(),
+		Command: cmd.CommandPath(),
+		Failed:  err != nil,
+	})
+	if merr != nil {
+		return
+	}
+
+	file, ferr := os.OpenFile(filepath.Join(dir, "usage.log"), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if ferr != nil {
+		return
+	}
+	defer file.Close()
+
+	fmt.Fprintf(file, "%s\n", line)
+}
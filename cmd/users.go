@@ -26,6 +26,9 @@ type userOptions struct {
 	keywords       []string
 	userRoleGrant  []string
 	userRoleRevoke []string
+	emails         string
+	names          string
+	page           pagination
 }
 
 func init() {
@@ -40,9 +43,11 @@ func userListCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "users",
 		Short: "List and manage users",
-		Long:  `List and manage users`,
+		Long: `List and manage users. Users are searched from all sources, local PrivX users
+are created, updated and deleted with the corresponding subcommands.`,
 		Example: `
 	privx-cli users [access flags] --keywords <KEYWORD>,<KEYWORD>
+	privx-cli users [access flags] --keywords <KEYWORD> --source <SOURCE-ID>
 		`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -52,13 +57,21 @@ func userListCmd() *cobra.Command {
 
 	flags := cmd.Flags()
 	flags.StringArrayVarP(&options.keywords, "keywords", "", []string{}, "search keywords")
+	flags.StringArrayVarP(&options.sources, "source", "", []string{}, "the source ID where to search the user from")
+	options.page.register(cmd)
 
+	cmd.AddCommand(userSearchCmd())
+	cmd.AddCommand(localUserAliasCmd(localUserCreateCmd()))
+	cmd.AddCommand(localUserAliasCmd(localUserUpdateCmd()))
+	cmd.AddCommand(localUserAliasCmd(localUserDeleteCmd()))
 	cmd.AddCommand(userShowCmd())
 	cmd.AddCommand(userSettingShowCmd())
 	cmd.AddCommand(userSettingsUpdateCmd())
 	cmd.AddCommand(usersRolesCmd())
 	cmd.AddCommand(userMFACmd())
 	cmd.AddCommand(externalUserSearchCmd())
+	cmd.AddCommand(userResolveCmd())
+	cmd.AddCommand(userPurgeDataCmd())
 
 	return cmd
 }
@@ -66,12 +79,51 @@ func userListCmd() *cobra.Command {
 func userList(options userOptions) error {
 	api := rolestore.New(curl())
 
-	users, err := api.SearchUsers(strings.Join(options.keywords, ","), "")
+	users, err := api.SearchUsers(strings.Join(options.keywords, ","),
+		strings.Join(options.sources, ","))
 	if err != nil {
 		return err
 	}
 
-	return stdout(users)
+	page, err := options.page.Apply(users)
+	if err != nil {
+		return err
+	}
+
+	return stdout(page)
+}
+
+//
+//
+func userSearchCmd() *cobra.Command {
+	options := userOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "Search users by keyword or source",
+		Long:  `Search users by keyword or source`,
+		Example: `
+	privx-cli users list [access flags] --keywords <KEYWORD>,<KEYWORD>
+	privx-cli users list [access flags] --keywords <KEYWORD> --source <SOURCE-ID>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return userList(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringArrayVarP(&options.keywords, "keywords", "", []string{}, "search keywords")
+	flags.StringArrayVarP(&options.sources, "source", "", []string{}, "the source ID where to search the user from")
+	options.page.register(cmd)
+
+	return cmd
+}
+
+// localUserAliasCmd exposes local user command under users
+func localUserAliasCmd(cmd *cobra.Command) *cobra.Command {
+	cmd.Example = strings.ReplaceAll(cmd.Example, "privx-cli local-users", "privx-cli users")
+	return cmd
 }
 
 //
@@ -173,7 +225,7 @@ func userSettingsUpdateCmd() *cobra.Command {
 	flags.StringVar(&options.userID, "id", "", "user ID")
 	cmd.MarkFlagRequired("id")
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func userSettingsUpdate(options userOptions, args []string) error {
@@ -359,17 +411,124 @@ func externalUserSearch(options userOptions) error {
 	return stdout(users)
 }
 
+//
+//
+func userResolveCmd() *cobra.Command {
+	options := userOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "resolve",
+		Short: "Resolve user emails and names and return corresponding ID's",
+		Long: `Resolve user emails and usernames and return corresponding ID's. Values are separated
+by commas when using multiple values, see example. User matching in several sources
+is reported as ambiguous, use --source to select the source.`,
+		Example: `
+	privx-cli users resolve [access flags] --email <EMAIL>,<EMAIL>
+	privx-cli users resolve [access flags] --name <USERNAME> --source <SOURCE-ID>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return userResolve(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.emails, "email", "", "user email")
+	flags.StringVar(&options.names, "name", "", "username")
+	flags.StringArrayVarP(&options.sources, "source", "", []string{}, "the source ID where to resolve the user from")
+
+	return cmd
+}
+
+type resolvedUser struct {
+	Query    string `json:"query"`
+	ID       string `json:"id"`
+	Username string `json:"username"`
+	Email    string `json:"email,omitempty"`
+	Source   string `json:"source_id"`
+}
+
+func userResolve(options userOptions) error {
+	if options.emails == "" && options.names == "" {
+		return fmt.Errorf("either --email or --name is required")
+	}
+
+	queries := []struct{ value, field string }{}
+	for _, email := range strings.Split(options.emails, ",") {
+		if email = strings.TrimSpace(email); email != "" {
+			queries = append(queries, struct{ value, field string }{email, "email"})
+		}
+	}
+	for _, name := range strings.Split(options.names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			queries = append(queries, struct{ value, field string }{name, "username"})
+		}
+	}
+
+	api := rolestore.New(curl())
+	resolved := []resolvedUser{}
+
+	for _, query := range queries {
+		users, err := api.SearchUsers(query.value, strings.Join(options.sources, ","))
+		if err != nil {
+			return err
+		}
+
+		var candidates []resolvedUser
+		if err := remarshal(users, &candidates); err != nil {
+			return err
+		}
+
+		matches := []resolvedUser{}
+		for _, user := range candidates {
+			value := user.Username
+			if query.field == "email" {
+				value = user.Email
+			}
+			if strings.EqualFold(value, query.value) {
+				user.Query = query.value
+				matches = append(matches, user)
+			}
+		}
+
+		switch len(matches) {
+		case 0:
+			return fmt.Errorf("user not found: %s", query.value)
+		case 1:
+			resolved = append(resolved, matches[0])
+		default:
+			sources := []string{}
+			for _, user := range matches {
+				sources = append(sources, user.Source)
+			}
+			return fmt.Errorf("user %s is ambiguous, it exists in sources %s, use --source",
+				query.value, strings.Join(sources, ", "))
+		}
+	}
+
+	return stdout(resolved)
+}
+
+// decodeJSON reads JSON payload from file, - reads stdin
 func decodeJSON(name string, object interface{}) error {
-	file, err := os.Open(name)
-	if err != nil {
-		return err
+	file := os.Stdin
+	if name != "-" {
+		var err error
+		if file, err = os.Open(name); err != nil {
+			return err
+		}
+		defer file.Close()
 	}
-	defer file.Close()
 
 	data, err := ioutil.ReadAll(file)
 	if err != nil {
 		return err
 	}
+	data = stripBOM(data)
+
+	if dryRun {
+		return strictJSON(data, object)
+	}
 
 	err = json.Unmarshal(data, &object)
 	if err != nil {
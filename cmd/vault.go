@@ -11,8 +11,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/SSHcom/privx-sdk-go/api/authorizer"
+	"github.com/SSHcom/privx-sdk-go/api/monitor"
 	"github.com/SSHcom/privx-sdk-go/api/vault"
 	"github.com/spf13/cobra"
 )
@@ -24,6 +28,10 @@ type vaultOptions struct {
 	sortdir      string
 	vaultReadTo  []string
 	vaultWriteTo []string
+	since        string
+	accessGroup  string
+	allGroups    bool
+	dataOnly     bool
 	limit        int
 	offset       int
 }
@@ -38,11 +46,14 @@ func secretListCmd() *cobra.Command {
 	options := vaultOptions{}
 
 	cmd := &cobra.Command{
-		Use:   "secrets",
-		Short: "PrivX secrets",
-		Long:  `List and manage PrivX secrets`,
+		Use:     "secrets",
+		Aliases: []string{"vault"},
+		Short:   "PrivX secrets",
+		Long:    `List and manage PrivX secrets`,
 		Example: `
 	privx-cli secrets [access flags] --offset <OFFSET> --limit <LIMIT>
+	privx-cli vault list [access flags]
+	privx-cli secrets list [access flags] --access-group <ACCESS-GROUP>
 		`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -50,10 +61,9 @@ func secretListCmd() *cobra.Command {
 		},
 	}
 
-	flags := cmd.Flags()
-	flags.IntVar(&options.offset, "offset", 0, "where to start fetching the items")
-	flags.IntVar(&options.limit, "limit", 50, "number of items to return")
+	secretListFlags(cmd, &options)
 
+	cmd.AddCommand(secretListSubCmd())
 	cmd.AddCommand(secretShowCmd())
 	cmd.AddCommand(secretCreateCmd())
 	cmd.AddCommand(vaultUpdateCmd())
@@ -61,21 +71,147 @@ func secretListCmd() *cobra.Command {
 	cmd.AddCommand(secretMetadataShowCmd())
 	cmd.AddCommand(secretSearchCmd())
 	cmd.AddCommand(secretSchemasShowCmd())
+	cmd.AddCommand(secretAccessLogCmd())
+	cmd.AddCommand(secretLeaseCmd())
+	cmd.AddCommand(secretWatchCmd())
+	cmd.AddCommand(secretExecCmd())
+
+	return cmd
+}
+
+//
+//
+func secretListSubCmd() *cobra.Command {
+	options := vaultOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List secrets",
+		Long: `List secrets. Secrets are namespaced by access group as <ACCESS-GROUP>:<NAME>,
+only secrets of the access group given by --access-group or access_group of
+the profile are listed. Use --all-groups to list secrets of all groups.`,
+		Example: `
+	privx-cli secrets list [access flags] --offset <OFFSET> --limit <LIMIT>
+	privx-cli secrets list [access flags] --access-group <ACCESS-GROUP>
+	privx-cli secrets list [access flags] --all-groups
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return secretList(options)
+		},
+	}
+
+	secretListFlags(cmd, &options)
 
 	return cmd
 }
 
+func secretListFlags(cmd *cobra.Command, options *vaultOptions) {
+	flags := cmd.Flags()
+	flags.IntVar(&options.offset, "offset", 0, "where to start fetching the items")
+	flags.IntVar(&options.limit, "limit", 50, "number of items to return")
+	flags.StringVar(&options.accessGroup, "access-group", "", "access group namespace (default access_group of profile)")
+	flags.BoolVar(&options.allGroups, "all-groups", false, "list secrets of all access groups")
+}
+
 func secretList(options vaultOptions) error {
 	api := vault.New(curl())
 
-	secrets, err := api.Secrets(options.offset, options.limit)
+	namespace, err := secretNamespace(options)
 	if err != nil {
 		return err
 	}
 
+	if namespace == "" {
+		secrets, err := api.Secrets(options.offset, options.limit)
+		if err != nil {
+			return err
+		}
+
+		return stdout(secrets)
+	}
+
+	limit := 100
+	secrets := []map[string]interface{}{}
+	for offset := 0; ; offset += limit {
+		page, err := api.Secrets(offset, limit)
+		if err != nil {
+			return err
+		}
+
+		var items []map[string]interface{}
+		if err := remarshal(page, &items); err != nil {
+			return err
+		}
+
+		for _, secret := range items {
+			if strings.HasPrefix(fmt.Sprint(secret["name"]), namespace) {
+				secrets = append(secrets, secret)
+			}
+		}
+
+		if len(items) < limit {
+			break
+		}
+	}
+
+	if options.offset > len(secrets) {
+		options.offset = len(secrets)
+	}
+	secrets = secrets[options.offset:]
+	if options.limit < len(secrets) {
+		secrets = secrets[:options.limit]
+	}
+
 	return stdout(secrets)
 }
 
+// secretNamespace is the name prefix of secrets of the access group
+// given by flag or profile. Empty namespace means all secrets.
+func secretNamespace(options vaultOptions) (string, error) {
+	if options.allGroups {
+		return "", nil
+	}
+
+	group := options.accessGroup
+	if group == "" {
+		group = profileAttributes()["access_group"]
+	}
+	if group == "" {
+		return "", nil
+	}
+
+	name, err := accessGroupName(group)
+	if err != nil {
+		return "", err
+	}
+
+	return name + ":", nil
+}
+
+// accessGroupName resolves access group by ID or name
+func accessGroupName(ref string) (string, error) {
+	api := authorizer.New(curl())
+	limit := 100
+
+	for offset := 0; ; offset += limit {
+		groups, err := api.AccessGroups(offset, limit, "", "")
+		if err != nil {
+			return "", err
+		}
+
+		for _, group := range groups {
+			if group.ID == ref || strings.EqualFold(group.Name, ref) {
+				return group.Name, nil
+			}
+		}
+
+		if len(groups) < limit {
+			return "", fmt.Errorf("access group not found: %s", ref)
+		}
+	}
+}
+
 //
 //
 func secretShowCmd() *cobra.Command {
@@ -87,6 +223,7 @@ func secretShowCmd() *cobra.Command {
 		Long:  `Get a secret. Secret Name's are separated by commas when using multiple values, see example`,
 		Example: `
 	privx-cli secrets show [access flags] --name <SECRET-NAME>,<SECRET-NAME>
+	privx-cli secrets show [access flags] --name <SECRET-NAME> --data-only
 		`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -96,6 +233,7 @@ func secretShowCmd() *cobra.Command {
 
 	flags := cmd.Flags()
 	flags.StringVar(&options.secretName, "name", "", "secret name")
+	flags.BoolVar(&options.dataOnly, "data-only", false, "print only secret data")
 	cmd.MarkFlagRequired("name")
 
 	return cmd
@@ -113,6 +251,24 @@ func secretShow(options vaultOptions) error {
 		secrets = append(secrets, *secret)
 	}
 
+	if options.dataOnly {
+		data := []interface{}{}
+		for _, secret := range secrets {
+			var view struct {
+				Data interface{} `json:"data"`
+			}
+			if err := remarshal(secret, &view); err != nil {
+				return err
+			}
+			data = append(data, view.Data)
+		}
+
+		if len(data) == 1 {
+			return stdout(data[0])
+		}
+		return stdout(data)
+	}
+
 	return stdout(secrets)
 }
 
@@ -124,15 +280,20 @@ func secretCreateCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "create",
 		Short: "Create new secret",
-		Long:  `Create new secret`,
+		Long: `Create new secret. The secret is created in namespace of access group given by
+--access-group or access_group of the profile, its name becomes <ACCESS-GROUP>:<NAME>.`,
 		Example: `
+	privx-cli secrets create [access flags] --name <SECRET-NAME> --access-group <ACCESS-GROUP> JSON-FILE
+
 	privx-cli secrets create [access flags] --name <SECRET-NAME>
 		--allow-read-to <ROLE-ID>
 		--allow-write-to <ROLE-ID>
 		...
 		JSON-FILE
+
+	echo '{"password": "secret"}' | privx-cli secrets create [access flags] --name <SECRET-NAME> -
 		`,
-		Args:         cobra.ExactArgs(1),
+		Args:         cobra.MaximumNArgs(1),
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return secretCreate(args, options)
@@ -143,19 +304,28 @@ func secretCreateCmd() *cobra.Command {
 	flags.StringVar(&options.secretName, "name", "", "secret name")
 	flags.StringArrayVar(&options.vaultReadTo, "allow-read-to", []string{}, "read by role ID")
 	flags.StringArrayVar(&options.vaultWriteTo, "allow-write-to", []string{}, "write by role ID")
+	flags.StringVar(&options.accessGroup, "access-group", "", "access group namespace (default access_group of profile)")
 	cmd.MarkFlagRequired("name")
 	cmd.MarkFlagRequired("read-by")
 	cmd.MarkFlagRequired("write-by")
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func secretCreate(args []string, options vaultOptions) error {
-	secret, err := readJSON(args[0])
+	secret, err := readJSON(payloadFile(args))
 	if err != nil {
 		return err
 	}
 
+	namespace, err := secretNamespace(options)
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(options.secretName, namespace) {
+		options.secretName = namespace + options.secretName
+	}
+
 	api := vault.New(curl())
 	if err := api.CreateSecret(options.secretName, options.vaultReadTo,
 		options.vaultWriteTo, secret); err != nil {
@@ -182,8 +352,10 @@ func vaultUpdateCmd() *cobra.Command {
 		--allow-write-to <ROLE-ID>
 		...
 		JSON-FILE
+
+	privx-cli secrets update [access flags] --name <SECRET-NAME> < secret.json
 		`,
-		Args:         cobra.ExactArgs(1),
+		Args:         cobra.MaximumNArgs(1),
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return secretUpdate(options, args)
@@ -196,11 +368,11 @@ func vaultUpdateCmd() *cobra.Command {
 	flags.StringArrayVar(&options.vaultWriteTo, "allow-write-to", []string{}, "write by role ID")
 	cmd.MarkFlagRequired("name")
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func secretUpdate(options vaultOptions, args []string) error {
-	secret, err := readJSON(args[0])
+	secret, err := readJSON(payloadFile(args))
 	if err != nil {
 		return err
 	}
@@ -382,18 +554,137 @@ func secretSchemasShow() error {
 	return stdout(schemas)
 }
 
+//
+//
+func secretAccessLogCmd() *cobra.Command {
+	options := vaultOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "access-log",
+		Short: "Show who accessed a secret and when",
+		Long:  `Show audit events of reads and writes to a secret, newest first`,
+		Example: `
+	privx-cli secrets access-log [access flags] --name <SECRET-NAME>
+	privx-cli secrets access-log [access flags] --name <SECRET-NAME> --since 7d
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return secretAccessLog(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.secretName, "name", "", "secret name")
+	flags.StringVar(&options.since, "since", "", "show events after RFC3339 time or duration ago (24h, 7d)")
+	cmd.MarkFlagRequired("name")
+
+	return cmd
+}
+
+// secretAccessEvent is an audit event of secret read or write
+type secretAccessEvent struct {
+	Time   string      `json:"time"`
+	Event  string      `json:"event"`
+	UserID interface{} `json:"user_id,omitempty"`
+	User   interface{} `json:"username,omitempty"`
+	Source interface{} `json:"source_address,omitempty"`
+}
+
+func secretAccessLog(options vaultOptions) error {
+	api := monitor.New(curl())
+	limit := 100
+
+	var since time.Time
+	if options.since != "" {
+		var err error
+		if since, err = parseTimeFlag(options.since); err != nil {
+			return err
+		}
+	}
+
+	var searchObject monitor.AuditEventSearchObject
+	if err := remarshal(map[string]interface{}{"keywords": options.secretName}, &searchObject); err != nil {
+		return err
+	}
+
+	log := []secretAccessEvent{}
+	for offset := 0; ; offset += limit {
+		page, err := api.SearchAuditEvents(offset, limit, "timestamp", "DESC", false, &searchObject)
+		if err != nil {
+			return err
+		}
+
+		var events []map[string]interface{}
+		if err := remarshal(page, &events); err != nil {
+			return err
+		}
+
+		for _, event := range events {
+			name := fmt.Sprint(firstOf(event, "event", "event_name"))
+			if !strings.Contains(strings.ToUpper(name), "SECRET") {
+				continue
+			}
+			if secretName(event) != options.secretName {
+				continue
+			}
+
+			at := fmt.Sprint(firstOf(event, "timestamp", "created"))
+			if t, err := time.Parse(time.RFC3339, at); err == nil && t.Before(since) {
+				continue
+			}
+
+			log = append(log, secretAccessEvent{
+				Time:   at,
+				Event:  name,
+				UserID: firstOf(event, "user_id"),
+				User:   firstOf(event, "username", "user_name"),
+				Source: firstOf(event, "source_address", "remote_address"),
+			})
+		}
+
+		if len(events) < limit {
+			break
+		}
+	}
+
+	sort.SliceStable(log, func(i, j int) bool { return log[i].Time > log[j].Time })
+
+	return stdout(log)
+}
+
+// secretName looks up secret name from audit event, the name is
+// either at top level or in event payload
+func secretName(event map[string]interface{}) string {
+	for _, path := range []string{"secret_name", "name", "payload.secret_name", "payload.name"} {
+		if name, ok := jsonPath(event, path).(string); ok && name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// payloadFile is the file argument of command, stdin if omitted
+func payloadFile(args []string) string {
+	if len(args) == 0 {
+		return "-"
+	}
+	return args[0]
+}
+
 func readJSON(name string) (secret interface{}, err error) {
-	file, err := os.Open(name)
-	if err != nil {
-		return
+	file := os.Stdin
+	if name != "-" {
+		if file, err = os.Open(name); err != nil {
+			return
+		}
+		defer file.Close()
 	}
-	defer file.Close()
 
 	data, err := ioutil.ReadAll(file)
 	if err != nil {
 		return
 	}
 
-	err = json.Unmarshal(data, &secret)
+	err = json.Unmarshal(stripBOM(data), &secret)
 	return
 }
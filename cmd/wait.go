@@ -0,0 +1,86 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// waiting are flags of commands triggering asynchronous operation. With
+// --wait the command polls the operation until it reaches terminal state.
+type waiting struct {
+	enabled  bool
+	timeout  time.Duration
+	interval time.Duration
+}
+
+// register adds wait flags, the current values are the defaults
+func (w *waiting) register(cmd *cobra.Command, until string) {
+	if w.timeout == 0 {
+		w.timeout = 10 * time.Minute
+	}
+	if w.interval == 0 {
+		w.interval = 2 * time.Second
+	}
+
+	flags := cmd.Flags()
+	flags.BoolVar(&w.enabled, "wait", w.enabled, "wait until "+until)
+	flags.DurationVar(&w.timeout, "timeout", w.timeout, "maximum time to wait")
+	flags.DurationVar(&w.interval, "poll-interval", w.interval, "polling interval of --wait")
+}
+
+// poll calls check until it reports the operation done or failed. Without
+// --wait the operation is checked once. Operation still pending when the
+// time is up fails with ExitTimeout.
+func (w waiting) poll(operation string, check func() (done bool, err error)) error {
+	deadline := time.Now().Add(w.timeout)
+
+	for {
+		done, err := check()
+		if err != nil || done || !w.enabled {
+			return err
+		}
+
+		if time.Now().After(deadline) {
+			return waitTimeout(operation, w.timeout)
+		}
+
+		select {
+		case <-runContext.Done():
+			return runContext.Err()
+		case <-time.After(w.interval):
+		}
+	}
+}
+
+// pending tells if failed check of asynchronous operation is retried
+// with --wait. PrivX reports operation in progress as an error, rejected
+// request or missing resource does not change by waiting.
+func pending(err error) bool {
+	if errors.Is(err, errDryRun) {
+		return false
+	}
+
+	switch statusCode(err) {
+	case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound:
+		return false
+	}
+	return true
+}
+
+func waitTimeout(operation string, timeout time.Duration) error {
+	return &statusError{
+		code:    ExitTimeout,
+		State:   "timeout",
+		Message: fmt.Sprintf("%s is not finished in %s", operation, timeout),
+	}
+}
@@ -0,0 +1,140 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package cmd
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/SSHcom/privx-sdk-go/api/rolestore"
+	"github.com/spf13/cobra"
+)
+
+// whoamiPrincipal describes the authenticated principal of the CLI
+type whoamiPrincipal struct {
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username,omitempty"`
+	FullName  string    `json:"full_name,omitempty"`
+	Source    string    `json:"source,omitempty"`
+	Roles     []string  `json:"roles"`
+	Expires   time.Time `json:"expires"`
+	ExpiresIn string    `json:"expires_in"`
+	Instance  string    `json:"instance,omitempty"`
+	Profile   string    `json:"profile,omitempty"`
+	Version   string    `json:"version,omitempty"`
+}
+
+func init() {
+	rootCmd.AddCommand(whoamiCmd())
+}
+
+//
+//
+func whoamiCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "whoami",
+		Short: "Show the authenticated principal",
+		Long: `Show the authenticated principal, its roles and expiry of the access token,
+together with the PrivX instance and its version. Use it to check which
+credentials and profile the commands are run with.`,
+		Example: `
+	privx-cli whoami [access flags]
+	privx-cli @prod whoami
+		`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return whoami()
+		},
+	}
+
+	return cmd
+}
+
+func whoami() error {
+	claims, err := tokenClaims()
+	if err != nil {
+		return err
+	}
+
+	principal := whoamiPrincipal{Roles: []string{}, Profile: profile}
+	principal.UserID, _ = claims["sub"].(string)
+	if principal.UserID == "" {
+		return errors.New("access token does not identify the user")
+	}
+	principal.Username, _ = firstOf(claims, "preferred_username", "username", "name").(string)
+
+	if exp, ok := claims["exp"].(float64); ok {
+		principal.Expires = time.Unix(int64(exp), 0)
+		principal.ExpiresIn = time.Until(principal.Expires).Round(time.Second).String()
+	}
+
+	if urls := baseURLs(); len(urls) > 0 {
+		principal.Instance = urls[0]
+	}
+	if url := endpoint(); url != "" {
+		principal.Instance = url
+	}
+
+	// role-store and monitor may be restricted from the principal,
+	// the token alone identifies it
+	if user, err := rolestore.New(curl()).User(principal.UserID); err == nil {
+		var userView struct {
+			Username string              `json:"username"`
+			FullName string              `json:"full_name"`
+			Source   string              `json:"source_id"`
+			Roles    []rolestore.RoleRef `json:"roles"`
+		}
+		if err := remarshal(user, &userView); err != nil {
+			return err
+		}
+
+		principal.Username = firstNonEmpty(userView.Username, principal.Username)
+		principal.FullName, principal.Source = userView.FullName, userView.Source
+		for _, role := range userView.Roles {
+			principal.Roles = append(principal.Roles, role.Name)
+		}
+		sort.Strings(principal.Roles)
+	} else {
+		info("user %s: %s", principal.UserID, err)
+	}
+
+	if nodes, err := instanceNodes(); err == nil {
+		versions := map[string]bool{}
+		for _, node := range nodes {
+			for _, version := range node.Versions {
+				versions[version] = true
+			}
+		}
+		principal.Version = strings.Join(sortedKeys(versions), ", ")
+	} else {
+		info("instance version: %s", err)
+	}
+
+	return stdout(principal)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := []string{}
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
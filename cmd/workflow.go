@@ -15,9 +15,12 @@ import (
 )
 
 type workflowOptions struct {
-	workflowID string
-	limit      int
-	offset     int
+	workflowID   string
+	approverRole string
+	targetRole   string
+	request      string
+	limit        int
+	offset       int
 }
 
 func init() {
@@ -46,6 +49,7 @@ func workflowListCmd() *cobra.Command {
 	flags.IntVar(&options.offset, "offset", 0, "where to start fetching the items")
 	flags.IntVar(&options.limit, "limit", 50, "number of items to return")
 
+	cmd.AddCommand(workflowListSubCmd())
 	cmd.AddCommand(workflowCreateCmd())
 	cmd.AddCommand(workflowShowCmd())
 	cmd.AddCommand(workflowDeleteCmd())
@@ -53,6 +57,8 @@ func workflowListCmd() *cobra.Command {
 	cmd.AddCommand(workflowSettingListCmd())
 	cmd.AddCommand(workflowSettingsUpdateCmd())
 	cmd.AddCommand(testEmailNotificationCmd())
+	cmd.AddCommand(workflowFindCmd())
+	cmd.AddCommand(workflowTestCmd())
 
 	return cmd
 }
@@ -68,6 +74,31 @@ func workflowList(options workflowOptions) error {
 	return stdout(workflows)
 }
 
+//
+//
+func workflowListSubCmd() *cobra.Command {
+	options := workflowOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List workflows",
+		Long:  `List PrivX workflows`,
+		Example: `
+	privx-cli workflows list [access flags] --offset <OFFSET> --limit <LIMIT>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return workflowList(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.IntVar(&options.offset, "offset", 0, "where to start fetching the items")
+	flags.IntVar(&options.limit, "limit", 50, "number of items to return")
+
+	return cmd
+}
+
 //
 //
 func workflowCreateCmd() *cobra.Command {
@@ -85,7 +116,7 @@ func workflowCreateCmd() *cobra.Command {
 		},
 	}
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func workflowCreate(cmd *cobra.Command, args []string) error {
@@ -208,7 +239,7 @@ func workflowUpdateCmd() *cobra.Command {
 	flags.StringVar(&options.workflowID, "id", "", "unique workflow ID")
 	cmd.MarkFlagRequired("id")
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func workflowUpdate(options workflowOptions, args []string) error {
@@ -220,6 +251,15 @@ func workflowUpdate(options workflowOptions, args []string) error {
 		return err
 	}
 
+	current, err := api.Workflow(options.workflowID)
+	if err != nil {
+		return err
+	}
+
+	if err := confirmUpdate(current, &updateWorkflow); err != nil {
+		return err
+	}
+
 	err = api.UpdateWorkflow(options.workflowID, &updateWorkflow)
 	if err != nil {
 		return err
@@ -275,7 +315,7 @@ func workflowSettingsUpdateCmd() *cobra.Command {
 		},
 	}
 
-	return cmd
+	return bulkCmd(cmd)
 }
 
 func workflowSettingsUpdate(cmd *cobra.Command, args []string) error {
@@ -331,3 +371,274 @@ func testEmailNotification(cmd *cobra.Command, args []string) error {
 
 	return stdout(testResult)
 }
+
+//
+//
+func workflowFindCmd() *cobra.Command {
+	options := workflowOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "find",
+		Short: "Find workflows referencing a role",
+		Long: `Find workflows referencing a role as approver in any workflow step or as target role.
+Role ID's are separated by commas when using multiple values, see example.`,
+		Example: `
+	privx-cli workflows find [access flags] --approver-role <ROLE-ID>
+	privx-cli workflows find [access flags] --target-role <ROLE-ID>,<ROLE-ID>
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if options.approverRole == "" && options.targetRole == "" {
+				return fmt.Errorf("either --approver-role or --target-role is required")
+			}
+			return workflowFind(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.approverRole, "approver-role", "", "role ID used by workflow approvers")
+	flags.StringVar(&options.targetRole, "target-role", "", "role ID granted by workflow")
+
+	return cmd
+}
+
+// workflowReference is a place where workflow refers a role
+type workflowReference struct {
+	WorkflowID string `json:"workflow_id"`
+	Workflow   string `json:"workflow"`
+	RoleID     string `json:"role_id"`
+	RoleName   string `json:"role_name,omitempty"`
+	Usage      string `json:"usage"`
+}
+
+type workflowRoleView struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func workflowFind(options workflowOptions) error {
+	approvers := map[string]bool{}
+	if options.approverRole != "" {
+		for _, id := range strings.Split(options.approverRole, ",") {
+			approvers[id] = true
+		}
+	}
+
+	targets := map[string]bool{}
+	if options.targetRole != "" {
+		for _, id := range strings.Split(options.targetRole, ",") {
+			targets[id] = true
+		}
+	}
+
+	workflows, err := allWorkflows()
+	if err != nil {
+		return err
+	}
+
+	references := []workflowReference{}
+	for _, wf := range workflows {
+		ref := func(role workflowRoleView, usage string) {
+			references = append(references, workflowReference{
+				WorkflowID: wf.ID,
+				Workflow:   wf.Name,
+				RoleID:     role.ID,
+				RoleName:   role.Name,
+				Usage:      usage,
+			})
+		}
+
+		for i, step := range wf.Steps {
+			for _, approver := range step.Approvers {
+				if approvers[approver.Role.ID] {
+					ref(approver.Role, fmt.Sprintf("approver in step %d", i+1))
+				}
+			}
+		}
+
+		for _, role := range wf.TargetRoles {
+			if targets[role.ID] {
+				ref(role, "target role")
+			}
+		}
+	}
+
+	return stdout(references)
+}
+
+// workflowView is a minimal view of workflow used for local evaluation
+type workflowView struct {
+	ID                        string             `json:"id"`
+	Name                      string             `json:"name"`
+	Action                    string             `json:"action"`
+	GrantTypes                []string           `json:"grant_types"`
+	MaxTimeRestrictedDuration int                `json:"max_time_restricted_duration"`
+	RequesterRoles            []workflowRoleView `json:"requester_roles"`
+	TargetRoles               []workflowRoleView `json:"target_roles"`
+	Steps                     []struct {
+		Name      string `json:"name"`
+		Match     string `json:"match"`
+		Approvers []struct {
+			Role workflowRoleView `json:"role"`
+		} `json:"approvers"`
+	} `json:"steps"`
+}
+
+func allWorkflows() ([]workflowView, error) {
+	api := workflow.New(curl())
+	limit := 100
+	workflows := []workflowView{}
+
+	for offset := 0; ; offset += limit {
+		page, err := api.Workflows(offset, limit)
+		if err != nil {
+			return nil, err
+		}
+
+		var view []workflowView
+		if err := remarshal(page, &view); err != nil {
+			return nil, err
+		}
+
+		workflows = append(workflows, view...)
+		if len(view) < limit {
+			return workflows, nil
+		}
+	}
+}
+
+//
+//
+func workflowTestCmd() *cobra.Command {
+	options := workflowOptions{}
+
+	cmd := &cobra.Command{
+		Use:   "test",
+		Short: "Dry-run access request through workflows",
+		Long: `Dry-run hypothetical access request through configured workflows. Reports
+which workflow would handle the request and whether it would be approved
+without approvers. Nothing is submitted to PrivX. The request file has fields
+requester_roles (role IDs), target_role, action (GRANT or REVOKE),
+grant_type (PERMANENT, TIME_RESTRICTED or FLOATING) and duration in hours.`,
+		Example: `
+	privx-cli workflows test [access flags] --request sample.json
+		`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return workflowTest(options)
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.StringVar(&options.request, "request", "", "JSON file of hypothetical access request")
+	cmd.MarkFlagRequired("request")
+
+	return cmd
+}
+
+// workflowTestRequest is a hypothetical access request
+type workflowTestRequest struct {
+	RequesterRoles []string `json:"requester_roles"`
+	TargetRole     string   `json:"target_role"`
+	Action         string   `json:"action"`
+	GrantType      string   `json:"grant_type"`
+	Duration       int      `json:"duration"`
+}
+
+type workflowCandidate struct {
+	WorkflowID  string   `json:"workflow_id"`
+	Workflow    string   `json:"workflow"`
+	Matched     bool     `json:"matched"`
+	AutoApprove bool     `json:"auto_approve"`
+	Steps       int      `json:"steps"`
+	Reasons     []string `json:"reasons,omitempty"`
+}
+
+type workflowTestResult struct {
+	Match       *workflowCandidate  `json:"match"`
+	AutoApprove bool                `json:"auto_approve"`
+	Candidates  []workflowCandidate `json:"candidates"`
+}
+
+func workflowTest(options workflowOptions) error {
+	var req workflowTestRequest
+	if err := decodeJSON(options.request, &req); err != nil {
+		return err
+	}
+	if req.Action == "" {
+		req.Action = "GRANT"
+	}
+
+	workflows, err := allWorkflows()
+	if err != nil {
+		return err
+	}
+
+	result := workflowTestResult{Candidates: []workflowCandidate{}}
+	for _, wf := range workflows {
+		candidate := evalWorkflow(wf, req)
+		result.Candidates = append(result.Candidates, candidate)
+		if candidate.Matched && result.Match == nil {
+			match := candidate
+			result.Match = &match
+			result.AutoApprove = candidate.AutoApprove
+		}
+	}
+
+	return stdout(result)
+}
+
+// evalWorkflow checks workflow against request, reasons explain
+// why the workflow does not match
+func evalWorkflow(wf workflowView, req workflowTestRequest) workflowCandidate {
+	candidate := workflowCandidate{
+		WorkflowID: wf.ID,
+		Workflow:   wf.Name,
+		Steps:      len(wf.Steps),
+		Reasons:    []string{},
+	}
+
+	if wf.Action != "" && !strings.EqualFold(wf.Action, req.Action) {
+		candidate.Reasons = append(candidate.Reasons, "action "+wf.Action+" does not match")
+	}
+
+	if len(wf.TargetRoles) > 0 && !hasWorkflowRole(wf.TargetRoles, []string{req.TargetRole}) {
+		candidate.Reasons = append(candidate.Reasons, "target role is not handled")
+	}
+
+	if len(wf.RequesterRoles) > 0 && !hasWorkflowRole(wf.RequesterRoles, req.RequesterRoles) {
+		candidate.Reasons = append(candidate.Reasons, "requester has none of requester roles")
+	}
+
+	if req.GrantType != "" && len(wf.GrantTypes) > 0 {
+		allowed := false
+		for _, grant := range wf.GrantTypes {
+			allowed = allowed || strings.EqualFold(grant, req.GrantType)
+		}
+		if !allowed {
+			candidate.Reasons = append(candidate.Reasons, "grant type "+req.GrantType+" is not allowed")
+		}
+	}
+
+	if strings.EqualFold(req.GrantType, "TIME_RESTRICTED") && wf.MaxTimeRestrictedDuration > 0 &&
+		req.Duration > wf.MaxTimeRestrictedDuration {
+		candidate.Reasons = append(candidate.Reasons,
+			fmt.Sprintf("duration exceeds maximum of %d hours", wf.MaxTimeRestrictedDuration))
+	}
+
+	candidate.Matched = len(candidate.Reasons) == 0
+	candidate.AutoApprove = candidate.Matched && len(wf.Steps) == 0
+
+	return candidate
+}
+
+func hasWorkflowRole(roles []workflowRoleView, ids []string) bool {
+	for _, role := range roles {
+		for _, id := range ids {
+			if role.ID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -5,4 +5,5 @@ go 1.16
 require (
 	github.com/SSHcom/privx-sdk-go v0.6.0
 	github.com/spf13/cobra v1.2.0
+	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b
 )
@@ -0,0 +1,139 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package privxops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrDryRun is returned by operations that only show the change, it is
+// not a failure of bulk application
+var ErrDryRun = errors.New("dry run, no changes were made")
+
+// BulkResult is the outcome of applying one resource file
+type BulkResult struct {
+	File   string      `json:"file"`
+	Status string      `json:"status"`
+	Error  string      `json:"error,omitempty"`
+	Output interface{} `json:"output,omitempty"`
+}
+
+// RunBulk applies each file in order, failures do not stop the run.
+// Remaining files are skipped when context is done. Output of applying
+// the file is reported in its result.
+func RunBulk(ctx context.Context, files []string, apply func(file string) (interface{}, error)) (results []BulkResult, failed int) {
+	results = []BulkResult{}
+
+	for _, file := range files {
+		if ctx.Err() != nil {
+			break
+		}
+
+		output, err := apply(file)
+		result := BulkResult{File: file, Status: "ok", Output: output}
+		if errors.Is(err, ErrDryRun) {
+			result.Status = "dry-run"
+			err = nil
+		}
+		if err != nil {
+			result.Status = "failed"
+			result.Error = err.Error()
+			failed++
+		}
+		results = append(results, result)
+	}
+
+	return results, failed
+}
+
+// ItemResult is the outcome of applying operation to one resource ID
+type ItemResult struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// RunParallel applies operation to each ID with bounded number of workers,
+// failures do not stop the run. Results are in order of IDs, IDs not started
+// before context is done are skipped.
+func RunParallel(ctx context.Context, ids []string, workers int, apply func(id string) error) (results []ItemResult, failed int) {
+	results = make([]ItemResult, len(ids))
+	queue := make(chan int)
+	wg := sync.WaitGroup{}
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				err := apply(results[i].ID)
+				results[i].Status = "ok"
+				if errors.Is(err, ErrDryRun) {
+					results[i].Status = "dry-run"
+					err = nil
+				}
+				if err != nil {
+					results[i].Status = "failed"
+					results[i].Error = err.Error()
+				}
+			}
+		}()
+	}
+
+	for i, id := range ids {
+		results[i] = ItemResult{ID: id, Status: "skipped"}
+		if ctx.Err() == nil {
+			queue <- i
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	for _, result := range results {
+		if result.Status == "failed" {
+			failed++
+		}
+	}
+
+	return results, failed
+}
+
+// BulkFiles lists JSON files of directory, or files matching glob
+// patterns, in name order
+func BulkFiles(dir string, patterns []string) ([]string, error) {
+	if dir != "" {
+		if len(patterns) > 0 {
+			return nil, fmt.Errorf("either --dir or files are required, not both")
+		}
+		patterns = []string{filepath.Join(dir, "*.json")}
+	}
+
+	files := []string{}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", pattern, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			matches = []string{pattern}
+		}
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files found: %s", strings.Join(patterns, " "))
+	}
+
+	return files, nil
+}
@@ -0,0 +1,163 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package privxops
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	colorReset = "\033[0m"
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorCyan  = "\033[36m"
+)
+
+// DiffLines renders object as indented JSON with sorted keys so that
+// equal objects produce equal lines
+func DiffLines(object interface{}) ([]string, error) {
+	var doc interface{}
+	if err := Remarshal(object, &doc); err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.Split(string(data), "\n"), nil
+}
+
+// DiffLine is line of hunk, the operation is ' ', '-' or '+'
+type DiffLine struct {
+	Op   byte
+	Text string
+}
+
+// DiffHunk is a group of changed lines with context
+type DiffHunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Lines              []DiffLine
+}
+
+// UnifiedDiff computes line diff using longest common subsequence and
+// groups changes into hunks with given context
+func UnifiedDiff(a, b []string, context int) []DiffHunk {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	type edit struct {
+		DiffLine
+		ai, bi int
+	}
+
+	edits := []edit{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{DiffLine{' ', a[i]}, i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{DiffLine{'-', a[i]}, i, j})
+			i++
+		default:
+			edits = append(edits, edit{DiffLine{'+', b[j]}, i, j})
+			j++
+		}
+	}
+
+	hunks := []DiffHunk{}
+	for k := 0; k < len(edits); {
+		if edits[k].Op == ' ' {
+			k++
+			continue
+		}
+
+		start := k - context
+		if start < 0 {
+			start = 0
+		}
+
+		// extend hunk while changes are closer than two contexts
+		end, equal := k, 0
+		for end < len(edits) && equal <= 2*context {
+			if edits[end].Op == ' ' {
+				equal++
+			} else {
+				equal = 0
+			}
+			end++
+		}
+		end -= equal - context
+		if equal <= context {
+			end = len(edits)
+		}
+
+		hunk := DiffHunk{OldStart: edits[start].ai + 1, NewStart: edits[start].bi + 1}
+		for _, e := range edits[start:end] {
+			hunk.Lines = append(hunk.Lines, e.DiffLine)
+			if e.Op != '+' {
+				hunk.OldLines++
+			}
+			if e.Op != '-' {
+				hunk.NewLines++
+			}
+		}
+		hunks = append(hunks, hunk)
+		k = end
+	}
+
+	return hunks
+}
+
+// WriteDiff prints hunks in unified format, optionally with ANSI colors
+func WriteDiff(w io.Writer, hunks []DiffHunk, color bool) {
+	paint := func(c, s string) string {
+		if !color {
+			return s
+		}
+		return c + s + colorReset
+	}
+
+	fmt.Fprintln(w, paint(colorRed, "--- current"))
+	fmt.Fprintln(w, paint(colorGreen, "+++ updated"))
+	for _, hunk := range hunks {
+		fmt.Fprintln(w, paint(colorCyan, fmt.Sprintf("@@ -%d,%d +%d,%d @@",
+			hunk.OldStart, hunk.OldLines, hunk.NewStart, hunk.NewLines)))
+		for _, line := range hunk.Lines {
+			text := string(line.Op) + line.Text
+			switch line.Op {
+			case '-':
+				text = paint(colorRed, text)
+			case '+':
+				text = paint(colorGreen, text)
+			}
+			fmt.Fprintln(w, text)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+// Package privxops implements higher-level operations of privx-cli for
+// Go programs: walking paginated lists, paging and sorting of lists,
+// JMESPath queries, diffs of resources, table output and bulk application
+// of resource files. The operations work on SDK types and on their
+// generic JSON view, they do not depend on the command line.
+package privxops
+
+import "encoding/json"
+
+// Remarshal converts object through JSON, e.g. SDK type to generic view
+func Remarshal(in, out interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, out)
+}
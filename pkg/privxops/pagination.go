@@ -0,0 +1,144 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package privxops
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// PageSize is number of items fetched per request when walking all pages
+const PageSize = 100
+
+// Page selects page of list sorted by JSON attribute of its items, for
+// APIs returning the whole list
+type Page struct {
+	Offset  int
+	Limit   int
+	SortKey string
+	SortDir string
+	All     bool
+}
+
+// Apply sorts list and cuts the page of it, list keeps its type
+func (p Page) Apply(items interface{}) (interface{}, error) {
+	list := reflect.ValueOf(items)
+	if list.Kind() != reflect.Slice {
+		return items, nil
+	}
+
+	if p.SortKey != "" {
+		if err := sortItems(list, p.SortKey, strings.EqualFold(p.SortDir, "DESC")); err != nil {
+			return nil, err
+		}
+	} else if strings.EqualFold(p.SortDir, "DESC") {
+		swap := reflect.Swapper(list.Interface())
+		for i, j := 0, list.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+
+	if p.All {
+		return items, nil
+	}
+
+	start := p.Offset
+	if start > list.Len() {
+		start = list.Len()
+	}
+	end := list.Len()
+	if p.Limit > 0 && start+p.Limit < end {
+		end = start + p.Limit
+	}
+
+	return list.Slice(start, end).Interface(), nil
+}
+
+// sortItems sorts list by JSON attribute of its items
+func sortItems(list reflect.Value, key string, desc bool) error {
+	keys := make([]string, list.Len())
+	for i := range keys {
+		var object interface{}
+		if err := Remarshal(list.Index(i).Interface(), &object); err != nil {
+			return err
+		}
+		if value := JSONPath(object, key); value != nil {
+			keys[i] = fmt.Sprint(value)
+		}
+	}
+
+	// keys are sorted along with the items
+	index := make([]int, len(keys))
+	for i := range index {
+		index[i] = i
+	}
+	sort.SliceStable(index, func(i, j int) bool {
+		if desc {
+			return keys[index[i]] > keys[index[j]]
+		}
+		return keys[index[i]] < keys[index[j]]
+	})
+
+	sorted := reflect.MakeSlice(list.Type(), list.Len(), list.Len())
+	for i, from := range index {
+		sorted.Index(i).Set(list.Index(from))
+	}
+	reflect.Copy(list, sorted)
+
+	return nil
+}
+
+// AllPages walks pages of paginated API and concatenates them, the
+// result has the type of pages. Walking stops when context is done.
+func AllPages(ctx context.Context, fetch func(offset, limit int) (interface{}, error)) (interface{}, error) {
+	var all reflect.Value
+
+	for offset := 0; ; offset += PageSize {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		page, err := fetch(offset, PageSize)
+		if err != nil {
+			return nil, err
+		}
+
+		items := reflect.ValueOf(page)
+		if items.Kind() != reflect.Slice {
+			return nil, fmt.Errorf("paginated response is not a list: %T", page)
+		}
+
+		if !all.IsValid() {
+			all = reflect.MakeSlice(items.Type(), 0, items.Len())
+		}
+		all = reflect.AppendSlice(all, items)
+
+		if items.Len() < PageSize {
+			return all.Interface(), nil
+		}
+	}
+}
+
+// JSONPath selects attribute of generic JSON object by dotted path
+func JSONPath(object interface{}, path string) interface{} {
+	if path == "" {
+		return object
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		node, ok := object.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		object = node[key]
+	}
+
+	return object
+}
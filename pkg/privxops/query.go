@@ -0,0 +1,910 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package privxops
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Query evaluates JMESPath expression over generic JSON document. The
+// grammar is supported except expression references (&expr), the
+// functions are length, contains, starts_with, ends_with, keys, values,
+// join, sort, to_string and not_null.
+func Query(doc interface{}, expr string) (interface{}, error) {
+	tokens, err := lexQuery(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &queryParser{tokens: tokens}
+	node, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return node.eval(doc)
+}
+
+type queryToken struct {
+	kind  string
+	text  string
+	value interface{}
+	pos   int
+}
+
+// queryBindingPower of token kinds, tokens below 10 end projections
+var queryBindingPower = map[string]int{
+	"eof": 0, "ident": 0, "quoted": 0, "literal": 0, "]": 0, ")": 0, ",": 0, "}": 0,
+	"number": 0, "@": 0, ":": 0,
+	"|": 1, "||": 2, "&&": 3,
+	"==": 5, "!=": 5, "<": 5, "<=": 5, ">": 5, ">=": 5,
+	"[]": 9, "*": 20, "[?": 21, ".": 40, "!": 45, "{": 50, "[": 55, "(": 60,
+}
+
+func lexQuery(expr string) ([]queryToken, error) {
+	tokens := []queryToken{}
+	runes := []rune(expr)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+		emit := func(kind string, n int) {
+			tokens = append(tokens, queryToken{kind: kind, text: string(runes[start : start+n]), pos: start})
+			i += n
+		}
+		next := func(offset int) rune {
+			if i+offset < len(runes) {
+				return runes[i+offset]
+			}
+			return 0
+		}
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '_' || unicode.IsLetter(r):
+			for i < len(runes) && (runes[i] == '_' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, queryToken{kind: "ident", text: string(runes[start:i]), pos: start})
+		case r == '-' || unicode.IsDigit(r):
+			i++
+			for i < len(runes) && unicode.IsDigit(runes[i]) {
+				i++
+			}
+			n, err := strconv.Atoi(string(runes[start:i]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid number at %d", start)
+			}
+			tokens = append(tokens, queryToken{kind: "number", text: string(runes[start:i]), value: n, pos: start})
+		case r == '"':
+			text, end, err := lexDelimited(runes, i, '"')
+			if err != nil {
+				return nil, err
+			}
+			var name string
+			if err := json.Unmarshal([]byte(`"`+text+`"`), &name); err != nil {
+				return nil, fmt.Errorf("invalid quoted identifier at %d", start)
+			}
+			tokens = append(tokens, queryToken{kind: "quoted", text: name, pos: start})
+			i = end
+		case r == '\'':
+			text, end, err := lexDelimited(runes, i, '\'')
+			if err != nil {
+				return nil, err
+			}
+			text = strings.ReplaceAll(text, `\'`, `'`)
+			tokens = append(tokens, queryToken{kind: "literal", text: text, value: text, pos: start})
+			i = end
+		case r == '`':
+			text, end, err := lexDelimited(runes, i, '`')
+			if err != nil {
+				return nil, err
+			}
+			var value interface{}
+			if err := json.Unmarshal([]byte(strings.ReplaceAll(text, "\\`", "`")), &value); err != nil {
+				return nil, fmt.Errorf("invalid JSON literal at %d", start)
+			}
+			tokens = append(tokens, queryToken{kind: "literal", text: text, value: value, pos: start})
+			i = end
+		case r == '[' && next(1) == ']':
+			emit("[]", 2)
+		case r == '[' && next(1) == '?':
+			emit("[?", 2)
+		case r == '|' && next(1) == '|':
+			emit("||", 2)
+		case r == '&' && next(1) == '&':
+			emit("&&", 2)
+		case (r == '=' || r == '!' || r == '<' || r == '>') && next(1) == '=':
+			emit(string(runes[i:i+2]), 2)
+		case strings.ContainsRune(".*[]{}(),:|@!<>", r):
+			emit(string(r), 1)
+		default:
+			return nil, fmt.Errorf("unexpected character %q at %d", r, start)
+		}
+	}
+
+	return append(tokens, queryToken{kind: "eof", pos: len(runes)}), nil
+}
+
+// lexDelimited reads text up to closing delimiter, escaped delimiters
+// are kept escaped
+func lexDelimited(runes []rune, start int, delim rune) (string, int, error) {
+	for i := start + 1; i < len(runes); i++ {
+		switch runes[i] {
+		case '\\':
+			i++
+		case delim:
+			return string(runes[start+1 : i]), i + 1, nil
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated %c at %d", delim, start)
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) parse() (queryNode, error) {
+	node, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.current(); tok.kind != "eof" {
+		return nil, fmt.Errorf("unexpected %q at %d", tok.text, tok.pos)
+	}
+	return node, nil
+}
+
+func (p *queryParser) current() queryToken {
+	return p.tokens[p.pos]
+}
+
+func (p *queryParser) lookahead(n int) string {
+	if p.pos+n < len(p.tokens) {
+		return p.tokens[p.pos+n].kind
+	}
+	return "eof"
+}
+
+func (p *queryParser) advance() queryToken {
+	tok := p.tokens[p.pos]
+	if p.pos < len(p.tokens)-1 {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *queryParser) match(kind string) error {
+	if tok := p.current(); tok.kind != kind {
+		return fmt.Errorf("expected %q, found %q at %d", kind, tok.text, tok.pos)
+	}
+	p.advance()
+	return nil
+}
+
+func (p *queryParser) expression(bp int) (queryNode, error) {
+	left, err := p.nud(p.advance())
+	if err != nil {
+		return nil, err
+	}
+
+	for bp < queryBindingPower[p.current().kind] {
+		if left, err = p.led(p.advance(), left); err != nil {
+			return nil, err
+		}
+	}
+
+	return left, nil
+}
+
+func (p *queryParser) nud(tok queryToken) (queryNode, error) {
+	switch tok.kind {
+	case "literal":
+		return queryLiteral{tok.value}, nil
+	case "ident":
+		return queryField{tok.text}, nil
+	case "quoted":
+		if p.current().kind == "(" {
+			return nil, fmt.Errorf("function call needs unquoted name at %d", tok.pos)
+		}
+		return queryField{tok.text}, nil
+	case "@":
+		return queryCurrent{}, nil
+	case "*":
+		right, err := p.projectionRHS(queryBindingPower["*"])
+		if err != nil {
+			return nil, err
+		}
+		return queryValueProjection{queryCurrent{}, right}, nil
+	case "[?":
+		return p.filter(queryCurrent{})
+	case "[]":
+		right, err := p.projectionRHS(queryBindingPower["[]"])
+		if err != nil {
+			return nil, err
+		}
+		return queryProjection{queryFlatten{queryCurrent{}}, right}, nil
+	case "!":
+		expr, err := p.expression(queryBindingPower["!"])
+		if err != nil {
+			return nil, err
+		}
+		return queryNot{expr}, nil
+	case "(":
+		expr, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		return expr, p.match(")")
+	case "{":
+		return p.multiselectHash()
+	case "[":
+		switch {
+		case p.current().kind == "number" || p.current().kind == ":":
+			index, err := p.index()
+			if err != nil {
+				return nil, err
+			}
+			return p.projectIfSlice(queryCurrent{}, index)
+		case p.current().kind == "*" && p.lookahead(1) == "]":
+			p.advance()
+			p.advance()
+			right, err := p.projectionRHS(queryBindingPower["*"])
+			if err != nil {
+				return nil, err
+			}
+			return queryProjection{queryCurrent{}, right}, nil
+		}
+		return p.multiselectList()
+	}
+
+	return nil, fmt.Errorf("unexpected %q at %d", tok.text, tok.pos)
+}
+
+func (p *queryParser) led(tok queryToken, left queryNode) (queryNode, error) {
+	switch tok.kind {
+	case ".":
+		if p.current().kind != "*" {
+			right, err := p.dotRHS(queryBindingPower["."])
+			if err != nil {
+				return nil, err
+			}
+			return querySubexpression{left, right}, nil
+		}
+		p.advance()
+		right, err := p.projectionRHS(queryBindingPower["."])
+		if err != nil {
+			return nil, err
+		}
+		return queryValueProjection{left, right}, nil
+	case "|", "||", "&&", "==", "!=", "<", "<=", ">", ">=":
+		right, err := p.expression(queryBindingPower[tok.kind])
+		if err != nil {
+			return nil, err
+		}
+		return queryBinary{tok.kind, left, right}, nil
+	case "(":
+		field, ok := left.(queryField)
+		if !ok {
+			return nil, fmt.Errorf("invalid function call at %d", tok.pos)
+		}
+		args := []queryNode{}
+		for p.current().kind != ")" {
+			arg, err := p.expression(0)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			if p.current().kind == "," {
+				p.advance()
+			}
+		}
+		p.advance()
+		return queryFunction{field.name, args}, nil
+	case "[?":
+		return p.filter(left)
+	case "[]":
+		right, err := p.projectionRHS(queryBindingPower["[]"])
+		if err != nil {
+			return nil, err
+		}
+		return queryProjection{queryFlatten{left}, right}, nil
+	case "[":
+		if p.current().kind == "number" || p.current().kind == ":" {
+			index, err := p.index()
+			if err != nil {
+				return nil, err
+			}
+			return p.projectIfSlice(left, index)
+		}
+		if err := p.match("*"); err != nil {
+			return nil, err
+		}
+		if err := p.match("]"); err != nil {
+			return nil, err
+		}
+		right, err := p.projectionRHS(queryBindingPower["*"])
+		if err != nil {
+			return nil, err
+		}
+		return queryProjection{left, right}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected %q at %d", tok.text, tok.pos)
+}
+
+func (p *queryParser) filter(left queryNode) (queryNode, error) {
+	cond, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.match("]"); err != nil {
+		return nil, err
+	}
+
+	var right queryNode = queryCurrent{}
+	if p.current().kind != "[]" {
+		if right, err = p.projectionRHS(queryBindingPower["[?"]); err != nil {
+			return nil, err
+		}
+	}
+
+	return queryFilter{left, right, cond}, nil
+}
+
+func (p *queryParser) projectionRHS(bp int) (queryNode, error) {
+	switch tok := p.current(); {
+	case queryBindingPower[tok.kind] < 10:
+		return queryCurrent{}, nil
+	case tok.kind == "[" || tok.kind == "[?":
+		return p.expression(bp)
+	case tok.kind == ".":
+		p.advance()
+		return p.dotRHS(bp)
+	default:
+		return nil, fmt.Errorf("unexpected %q at %d", tok.text, tok.pos)
+	}
+}
+
+func (p *queryParser) dotRHS(bp int) (queryNode, error) {
+	switch tok := p.current(); tok.kind {
+	case "ident", "quoted", "*":
+		return p.expression(bp)
+	case "[":
+		p.advance()
+		return p.multiselectList()
+	case "{":
+		p.advance()
+		return p.multiselectHash()
+	default:
+		return nil, fmt.Errorf("unexpected %q at %d", tok.text, tok.pos)
+	}
+}
+
+// index parses [n] or [start:stop:step] after the bracket
+func (p *queryParser) index() (queryNode, error) {
+	if p.current().kind == "number" && p.lookahead(1) == "]" {
+		n := p.advance().value.(int)
+		p.advance()
+		return queryIndex{n}, nil
+	}
+
+	parts := [3]*int{}
+	for i := 0; ; {
+		switch tok := p.advance(); tok.kind {
+		case "number":
+			n := tok.value.(int)
+			if i == 2 && n == 0 {
+				return nil, fmt.Errorf("slice step cannot be 0 at %d", tok.pos)
+			}
+			parts[i] = &n
+		case ":":
+			if i++; i > 2 {
+				return nil, fmt.Errorf("too many colons in slice at %d", tok.pos)
+			}
+		case "]":
+			return querySlice{parts}, nil
+		default:
+			return nil, fmt.Errorf("unexpected %q in slice at %d", tok.text, tok.pos)
+		}
+	}
+}
+
+func (p *queryParser) multiselectList() (queryNode, error) {
+	items := []queryNode{}
+	for {
+		item, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if p.current().kind == "]" {
+			p.advance()
+			return queryMultiList{items}, nil
+		}
+		if err := p.match(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *queryParser) multiselectHash() (queryNode, error) {
+	hash := queryMultiHash{}
+	for {
+		key := p.advance()
+		if key.kind != "ident" && key.kind != "quoted" {
+			return nil, fmt.Errorf("expected key, found %q at %d", key.text, key.pos)
+		}
+		if err := p.match(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		hash.keys = append(hash.keys, key.text)
+		hash.values = append(hash.values, value)
+		if p.current().kind == "}" {
+			p.advance()
+			return hash, nil
+		}
+		if err := p.match(","); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// projectIfSlice makes slice a projection over the sliced items
+func (p *queryParser) projectIfSlice(left, index queryNode) (queryNode, error) {
+	expr := querySubexpression{left, index}
+	if _, ok := index.(querySlice); !ok {
+		return expr, nil
+	}
+
+	right, err := p.projectionRHS(queryBindingPower["*"])
+	if err != nil {
+		return nil, err
+	}
+	return queryProjection{expr, right}, nil
+}
+
+type queryNode interface {
+	eval(value interface{}) (interface{}, error)
+}
+
+type (
+	queryLiteral         struct{ value interface{} }
+	queryCurrent         struct{}
+	queryField           struct{ name string }
+	queryIndex           struct{ n int }
+	querySlice           struct{ parts [3]*int }
+	queryNot             struct{ expr queryNode }
+	queryFlatten         struct{ expr queryNode }
+	queryMultiList       struct{ items []queryNode }
+	querySubexpression   struct{ left, right queryNode }
+	queryProjection      struct{ left, right queryNode }
+	queryValueProjection struct{ left, right queryNode }
+	queryFilter          struct{ left, right, cond queryNode }
+	queryBinary          struct {
+		op          string
+		left, right queryNode
+	}
+	queryMultiHash struct {
+		keys   []string
+		values []queryNode
+	}
+	queryFunction struct {
+		name string
+		args []queryNode
+	}
+)
+
+func (n queryLiteral) eval(value interface{}) (interface{}, error) {
+	return n.value, nil
+}
+
+func (n queryCurrent) eval(value interface{}) (interface{}, error) {
+	return value, nil
+}
+
+func (n queryField) eval(value interface{}) (interface{}, error) {
+	if object, ok := value.(map[string]interface{}); ok {
+		return object[n.name], nil
+	}
+	return nil, nil
+}
+
+func (n queryIndex) eval(value interface{}) (interface{}, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, nil
+	}
+	i := n.n
+	if i < 0 {
+		i += len(list)
+	}
+	if i < 0 || i >= len(list) {
+		return nil, nil
+	}
+	return list[i], nil
+}
+
+func (n querySlice) eval(value interface{}) (interface{}, error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	step := 1
+	if n.parts[2] != nil {
+		step = *n.parts[2]
+	}
+
+	bound := func(part *int, def int) int {
+		if part == nil {
+			return def
+		}
+		i := *part
+		if i < 0 {
+			i += len(list)
+		}
+		if i < 0 {
+			if step < 0 {
+				return -1
+			}
+			return 0
+		}
+		if i >= len(list) {
+			if step < 0 {
+				return len(list) - 1
+			}
+			return len(list)
+		}
+		return i
+	}
+
+	result := []interface{}{}
+	if step > 0 {
+		for i := bound(n.parts[0], 0); i < bound(n.parts[1], len(list)); i += step {
+			result = append(result, list[i])
+		}
+	} else {
+		for i := bound(n.parts[0], len(list)-1); i > bound(n.parts[1], -1); i += step {
+			result = append(result, list[i])
+		}
+	}
+	return result, nil
+}
+
+func (n queryNot) eval(value interface{}) (interface{}, error) {
+	v, err := n.expr.eval(value)
+	return !queryTruthy(v), err
+}
+
+func (n queryFlatten) eval(value interface{}) (interface{}, error) {
+	v, err := n.expr.eval(value)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	result := []interface{}{}
+	for _, item := range list {
+		if sub, ok := item.([]interface{}); ok {
+			result = append(result, sub...)
+		} else {
+			result = append(result, item)
+		}
+	}
+	return result, nil
+}
+
+func (n queryMultiList) eval(value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	result := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		v, err := item.eval(value)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = v
+	}
+	return result, nil
+}
+
+func (n queryMultiHash) eval(value interface{}) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	result := map[string]interface{}{}
+	for i, key := range n.keys {
+		v, err := n.values[i].eval(value)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = v
+	}
+	return result, nil
+}
+
+func (n querySubexpression) eval(value interface{}) (interface{}, error) {
+	left, err := n.left.eval(value)
+	if err != nil {
+		return nil, err
+	}
+	return n.right.eval(left)
+}
+
+func (n queryProjection) eval(value interface{}) (interface{}, error) {
+	left, err := n.left.eval(value)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := left.([]interface{})
+	if !ok {
+		return nil, nil
+	}
+	return queryProject(list, n.right, nil)
+}
+
+func (n queryValueProjection) eval(value interface{}) (interface{}, error) {
+	left, err := n.left.eval(value)
+	if err != nil {
+		return nil, err
+	}
+	object, ok := left.(map[string]interface{})
+	if !ok {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	list := make([]interface{}, len(keys))
+	for i, key := range keys {
+		list[i] = object[key]
+	}
+	return queryProject(list, n.right, nil)
+}
+
+func (n queryFilter) eval(value interface{}) (interface{}, error) {
+	left, err := n.left.eval(value)
+	if err != nil {
+		return nil, err
+	}
+	list, ok := left.([]interface{})
+	if !ok {
+		return nil, nil
+	}
+	return queryProject(list, n.right, n.cond)
+}
+
+// queryProject applies expression to items passing the condition,
+// null results are dropped
+func queryProject(list []interface{}, expr, cond queryNode) (interface{}, error) {
+	result := []interface{}{}
+	for _, item := range list {
+		if cond != nil {
+			ok, err := cond.eval(item)
+			if err != nil {
+				return nil, err
+			}
+			if !queryTruthy(ok) {
+				continue
+			}
+		}
+
+		v, err := expr.eval(item)
+		if err != nil {
+			return nil, err
+		}
+		if v != nil {
+			result = append(result, v)
+		}
+	}
+	return result, nil
+}
+
+func (n queryBinary) eval(value interface{}) (interface{}, error) {
+	left, err := n.left.eval(value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "|":
+		return n.right.eval(left)
+	case "||":
+		if queryTruthy(left) {
+			return left, nil
+		}
+		return n.right.eval(value)
+	case "&&":
+		if !queryTruthy(left) {
+			return left, nil
+		}
+		return n.right.eval(value)
+	}
+
+	right, err := n.right.eval(value)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return reflect.DeepEqual(left, right), nil
+	case "!=":
+		return !reflect.DeepEqual(left, right), nil
+	}
+
+	a, aok := left.(float64)
+	b, bok := right.(float64)
+	if !aok || !bok {
+		return nil, nil
+	}
+	switch n.op {
+	case "<":
+		return a < b, nil
+	case "<=":
+		return a <= b, nil
+	case ">":
+		return a > b, nil
+	}
+	return a >= b, nil
+}
+
+func (n queryFunction) eval(value interface{}) (interface{}, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		v, err := arg.eval(value)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+
+	arity := map[string]int{
+		"length": 1, "contains": 2, "starts_with": 2, "ends_with": 2,
+		"keys": 1, "values": 1, "join": 2, "sort": 1, "to_string": 1,
+	}
+	if want, ok := arity[n.name]; ok && want != len(args) {
+		return nil, fmt.Errorf("%s() takes %d arguments", n.name, want)
+	}
+
+	switch n.name {
+	case "length":
+		switch v := args[0].(type) {
+		case string:
+			return float64(len([]rune(v))), nil
+		case []interface{}:
+			return float64(len(v)), nil
+		case map[string]interface{}:
+			return float64(len(v)), nil
+		}
+		return nil, fmt.Errorf("length() of %T", args[0])
+	case "contains":
+		switch v := args[0].(type) {
+		case string:
+			s, ok := args[1].(string)
+			return ok && strings.Contains(v, s), nil
+		case []interface{}:
+			for _, item := range v {
+				if reflect.DeepEqual(item, args[1]) {
+					return true, nil
+				}
+			}
+			return false, nil
+		}
+		return nil, fmt.Errorf("contains() of %T", args[0])
+	case "starts_with", "ends_with":
+		s, ok1 := args[0].(string)
+		affix, ok2 := args[1].(string)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("%s() takes strings", n.name)
+		}
+		if n.name == "starts_with" {
+			return strings.HasPrefix(s, affix), nil
+		}
+		return strings.HasSuffix(s, affix), nil
+	case "keys", "values":
+		object, ok := args[0].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s() takes object", n.name)
+		}
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		result := make([]interface{}, len(keys))
+		for i, key := range keys {
+			if n.name == "keys" {
+				result[i] = key
+			} else {
+				result[i] = object[key]
+			}
+		}
+		return result, nil
+	case "join":
+		glue, ok1 := args[0].(string)
+		list, ok2 := args[1].([]interface{})
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("join() takes string and list of strings")
+		}
+		parts := make([]string, len(list))
+		for i, item := range list {
+			if parts[i], ok1 = item.(string); !ok1 {
+				return nil, fmt.Errorf("join() takes string and list of strings")
+			}
+		}
+		return strings.Join(parts, glue), nil
+	case "sort":
+		list, ok := args[0].([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("sort() takes list")
+		}
+		sorted := append([]interface{}{}, list...)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			a, aok := sorted[i].(float64)
+			b, bok := sorted[j].(float64)
+			if aok && bok {
+				return a < b
+			}
+			return fmt.Sprint(sorted[i]) < fmt.Sprint(sorted[j])
+		})
+		return sorted, nil
+	case "to_string":
+		if s, ok := args[0].(string); ok {
+			return s, nil
+		}
+		data, err := json.Marshal(args[0])
+		return string(data), err
+	case "not_null":
+		for _, arg := range args {
+			if arg != nil {
+				return arg, nil
+			}
+		}
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("unknown function: %s()", n.name)
+}
+
+// queryTruthy is false for null, false and empty values
+func queryTruthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	return true
+}
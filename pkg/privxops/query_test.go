@@ -0,0 +1,142 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package privxops
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const queryDoc = `{
+	"items": [
+		{"id": "1", "name": "admin", "members": 3, "tags": ["prod", "eu"], "owner": {"name": "alice"}},
+		{"id": "2", "name": "dev", "members": 0, "tags": ["dev"], "owner": null},
+		{"id": "3", "name": "ops", "members": 12, "tags": [], "owner": {"name": "bob"}}
+	],
+	"count": 3,
+	"with space": "quoted"
+}`
+
+func queryTestDoc(t *testing.T) interface{} {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(queryDoc), &doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+func queryJSON(t *testing.T, value string) interface{} {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(value), &doc); err != nil {
+		t.Fatalf("invalid expected value %s: %v", value, err)
+	}
+	return doc
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		want string
+	}{
+		// identifiers and sub-expressions
+		{"identifier", "count", `3`},
+		{"quoted identifier", `"with space"`, `"quoted"`},
+		{"missing field", "missing", `null`},
+		{"sub-expression", "items[0].owner.name", `"alice"`},
+		{"sub-expression of null", "items[1].owner.name", `null`},
+		{"negative index", "items[-1].name", `"ops"`},
+		{"index out of range", "items[10]", `null`},
+		{"current node", "@.count", `3`},
+
+		// projections
+		{"list projection", "items[*].name", `["admin", "dev", "ops"]`},
+		{"projection skips null", "items[*].owner.name", `["alice", "bob"]`},
+		{"flatten", "items[].tags[]", `["prod", "eu", "dev"]`},
+		{"object projection", "items[0].owner.*", `["alice"]`},
+		{"slice", "items[1:].id", `["2", "3"]`},
+		{"slice step", "items[::2].id", `["1", "3"]`},
+		{"reverse slice", "items[::-1].id", `["3", "2", "1"]`},
+		{"multi-select list", "items[*].[id, name]", `[["1", "admin"], ["2", "dev"], ["3", "ops"]]`},
+		{"multi-select hash", "items[0].{id: id, owner: owner.name}", `{"id": "1", "owner": "alice"}`},
+
+		// filters
+		{"filter equals", "items[?name == 'dev'].id", `["2"]`},
+		{"filter number", "items[?members > `2`].name", `["admin", "ops"]`},
+		{"filter and", "items[?members > `0` && owner.name == 'bob'].id", `["3"]`},
+		{"filter or", "items[?name == 'dev' || name == 'ops'].id", `["2", "3"]`},
+		{"filter not", "items[?!owner].id", `["2"]`},
+		{"filter function", "items[?contains(tags, 'prod')].name", `["admin"]`},
+		{"filter no match", "items[?name == 'none']", `[]`},
+
+		// pipes stop projections
+		{"pipe", "items[*].name | [0]", `"admin"`},
+		{"projection without pipe", "items[*].name[0]", `[]`},
+		{"pipe to function", "items[?members > `0`] | length(@)", `2`},
+
+		// functions
+		{"length", "length(items)", `3`},
+		{"keys", "sort(keys(items[0].owner))", `["name"]`},
+		{"join", "join(', ', items[*].name)", `"admin, dev, ops"`},
+		{"sort", "sort(items[*].name)", `["admin", "dev", "ops"]`},
+		{"starts_with", "items[?starts_with(name, 'd')].id", `["2"]`},
+		{"ends_with", "items[?ends_with(name, 's')].id", `["3"]`},
+		{"to_string", "to_string(count)", `"3"`},
+		{"not_null", "not_null(missing, items[0].name)", `"admin"`},
+
+		// literals
+		{"raw string", "'text'", `"text"`},
+		{"JSON literal", "`{\"a\": 1}`.a", `1`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := Query(queryTestDoc(t), test.expr)
+			if err != nil {
+				t.Fatalf("Query(%q) failed: %v", test.expr, err)
+			}
+
+			if want := queryJSON(t, test.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("Query(%q) = %#v, want %#v", test.expr, got, want)
+			}
+		})
+	}
+}
+
+func TestQueryError(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"items[", `expected "*", found "" at 6`},
+		{"items[0", `unexpected "" in slice at 7`},
+		{"items.", `unexpected "" at 6`},
+		{"items[?name == 'dev'", `expected "]", found "" at 20`},
+		{"count $", `unexpected character '$' at 6`},
+		{"'open", `unterminated ' at 0`},
+		{`"open`, `unterminated " at 0`},
+		{"items[1:2:3:4]", `too many colons in slice at 11`},
+		{"items[::0]", `slice step cannot be 0 at 8`},
+		{"{1: id}", `expected key, found "1" at 1`},
+		{"unknown(items)", `unknown function: unknown()`},
+		{"length(items, count)", `length() takes 1 arguments`},
+		{"length(count)", `length() of float64`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expr, func(t *testing.T) {
+			_, err := Query(queryTestDoc(t), test.expr)
+			if err == nil {
+				t.Fatalf("Query(%q) succeeded, want error %q", test.expr, test.want)
+			}
+			if err.Error() != test.want {
+				t.Errorf("Query(%q) error = %q, want %q", test.expr, err, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+//
+// Copyright (c) 2021 SSH Communications Security Inc.
+//
+// All rights reserved.
+//
+
+package privxops
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// Table renders rows as aligned columns with upper case titles
+func Table(header []string, rows [][]string) []byte {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+
+	titles := make([]string, len(header))
+	for i, key := range header {
+		titles[i] = strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+	}
+	fmt.Fprintln(w, strings.Join(titles, "\t"))
+
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+
+	return buf.Bytes()
+}
+
+// CSV renders rows as CSV with header
+func CSV(header []string, rows [][]string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	if err := w.WriteAll(rows); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
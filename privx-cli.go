@@ -7,7 +7,6 @@
 package main
 
 import (
-	"fmt"
 	"os"
 
 	"github.com/SSHcom/privx-cli/cmd"
@@ -16,7 +15,7 @@ import (
 //
 func main() {
 	if err := cmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		cmd.WriteError(os.Stderr, err)
+		os.Exit(cmd.ExitCode(err))
 	}
 }